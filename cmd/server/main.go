@@ -9,10 +9,12 @@ import (
 	"bro-chat/internal/interfaces/handlers"
 	"bro-chat/internal/interfaces/middleware"
 	"bro-chat/internal/usecases"
+	"bro-chat/pkg/linkpreview"
 	"bro-chat/pkg/services"
 	"bro-chat/pkg/websocket"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,6 +33,15 @@ func main() {
 	userRepo := mongoRepo.NewUserRepository(db)
 	chatRepo := mongoRepo.NewChatRepository(db)
 	messageRepo := mongoRepo.NewMessageRepository(db)
+	scheduledMessageRepo := mongoRepo.NewScheduledMessageRepository(db)
+	starredMessageRepo := mongoRepo.NewStarredMessageRepository(db)
+	pinnedMessageRepo := mongoRepo.NewPinnedMessageRepository(db)
+	threadRepo := mongoRepo.NewThreadRepository(db)
+	pollRepo := mongoRepo.NewPollRepository(db)
+	linkPreviewRepo := mongoRepo.NewLinkPreviewRepository(db)
+	draftRepo := mongoRepo.NewDraftRepository(db)
+	chatClearRepo := mongoRepo.NewChatClearRepository(db)
+	broadcastRepo := mongoRepo.NewBroadcastRepository(db)
 	groupRepository := dbRepo.NewGroupRepository(db)
 	// Initialize new auth repositories
 	magicLinkRepo := mongoRepo.NewMagicLinkRepository(db)
@@ -44,12 +55,38 @@ func main() {
 	// Initialize services
 	fileUploadService := services.NewFileUploadService()
 	emailService := services.NewEmailService()
+	linkPreviewOptions := linkpreview.DefaultOptions()
+	linkPreviewOptions.Timeout = cfg.LinkPreviewTimeout
+	linkPreviewFetcher := linkpreview.NewHTTPFetcher(linkPreviewOptions)
 
 	// Initialize use cases
 	userUsecase := usecases.NewUserUsecase(userRepo)
-	chatUsecase := usecases.NewChatUsecase(chatRepo, userRepo)
-	messageUsecase := usecases.NewMessageUsecase(messageRepo, chatRepo, userRepo, hub)
-	groupUsecase := usecases.NewGroupUsecase(groupRepository, userRepository)
+	chatUsecase := usecases.NewChatUsecase(chatRepo, userRepo, draftRepo, chatClearRepo)
+	linkPreviewUsecase := usecases.NewLinkPreviewUsecase(linkPreviewRepo, linkPreviewFetcher, fileUploadService)
+	messageUsecase := usecases.NewMessageUsecase(
+		messageRepo,
+		chatRepo,
+		userRepo,
+		groupRepository,
+		starredMessageRepo,
+		pinnedMessageRepo,
+		threadRepo,
+		pollRepo,
+		draftRepo,
+		chatClearRepo,
+		linkPreviewUsecase,
+		fileUploadService,
+		hub,
+		cfg.MessageEditWindow,
+		cfg.MessageDeleteWindow,
+		cfg.MaxPinnedMessages,
+		cfg.ReactionAllowlist,
+	)
+	scheduledMessageUsecase := usecases.NewScheduledMessageUsecase(scheduledMessageRepo, chatRepo, messageUsecase)
+	groupUsecase := usecases.NewGroupUsecase(groupRepository, userRepository, messageUsecase)
+	broadcastUsecase := usecases.NewBroadcastUsecase(broadcastRepo, chatRepo, userRepo, messageRepo, messageUsecase)
+	chatTransferUsecase := usecases.NewChatTransferUsecase(messageRepo, chatRepo, userRepo, chatClearRepo, fileUploadService, messageUsecase)
+	syncUsecase := usecases.NewSyncUsecase(messageRepo, chatRepo, userRepo, groupRepository, chatClearRepo, messageUsecase)
 	// Initialize new auth usecase
 	authUsecase := usecases.NewAuthUsecase(
 		userRepo,
@@ -61,13 +98,24 @@ func main() {
 		cfg.FrontendURL, // Add this to config
 	)
 
+	// Live location updates arrive over the WebSocket
+	hub.SetLiveLocationHandler(messageUsecase)
+	hub.SetDraftHandler(messageUsecase)
+	hub.SetReceiptHandler(messageUsecase)
+	hub.SetSyncHandler(syncUsecase)
+
+	// Start background workers
+	go messageUsecase.RunExpiryReaper(time.Minute)
+	go scheduledMessageUsecase.RunDispatcher(10 * time.Second)
+
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authUsecase, userUsecase)
 	userHandler := handlers.NewUserHandler(userUsecase)
 	chatHandler := handlers.NewChatHandler(chatUsecase)
-	messageHandler := handlers.NewMessageHandler(messageUsecase, fileUploadService)
+	messageHandler := handlers.NewMessageHandler(messageUsecase, scheduledMessageUsecase, chatTransferUsecase, fileUploadService)
 	wsHandler := handlers.NewWebSocketHandler(hub, messageUsecase)
 	groupHandler := handlers.NewGroupHandler(groupUsecase)
+	broadcastHandler := handlers.NewBroadcastHandler(broadcastUsecase)
 	// Setup Gin router
 	r := gin.Default()
 	r.Use(middleware.CORS())
@@ -146,6 +194,7 @@ func main() {
 			users.GET("/profile", userHandler.GetProfile)
 			users.PUT("/profile", userHandler.UpdateProfile)
 			users.GET("/search", userHandler.SearchUsers)
+			users.PUT("/privacy", userHandler.UpdatePrivacy)
 		}
 
 		// Chat routes
@@ -154,6 +203,24 @@ func main() {
 			chats.POST("", chatHandler.CreateChat)
 			chats.GET("", chatHandler.GetUserChats)
 			chats.GET("/:chatId", chatHandler.GetChat)
+			chats.GET("/:chatId/pins", messageHandler.GetPinnedMessages)
+			chats.GET("/:chatId/draft", messageHandler.GetDraft)
+			chats.PUT("/:chatId/draft", messageHandler.SaveDraft)
+			chats.DELETE("/:chatId/draft", messageHandler.DeleteDraft)
+			chats.GET("/:chatId/export", messageHandler.ExportChat)
+			chats.POST("/import", messageHandler.ImportChat)
+		}
+
+		// Broadcast list routes
+		broadcasts := api.Group("/broadcasts")
+		{
+			broadcasts.POST("", broadcastHandler.CreateList)
+			broadcasts.GET("", broadcastHandler.GetLists)
+			broadcasts.GET("/:listId", broadcastHandler.GetList)
+			broadcasts.PUT("/:listId", broadcastHandler.UpdateList)
+			broadcasts.DELETE("/:listId", broadcastHandler.DeleteList)
+			broadcasts.POST("/:listId/send", broadcastHandler.Send)
+			broadcasts.GET("/:listId/sends", broadcastHandler.GetSends)
 		}
 
 		// Message routes
@@ -163,8 +230,10 @@ func main() {
 			messages.POST("", messageHandler.SendMessage)
 			messages.GET("/chat/:chatId", messageHandler.GetChatMessages)
 			messages.GET("/:messageId", messageHandler.GetMessage)
+			messages.GET("/:messageId/thread", messageHandler.GetThread)
 
 			// Message status
+			messages.GET("/:messageId/info", messageHandler.GetMessageInfo)
 			messages.PUT("/:messageId/read", messageHandler.MarkAsRead)
 			messages.PUT("/read-multiple", messageHandler.MarkMultipleAsRead)
 			messages.GET("/chat/:chatId/unread-count", messageHandler.GetUnreadCount)
@@ -177,11 +246,51 @@ func main() {
 			// Message reactions
 			messages.POST("/reactions", messageHandler.AddReaction)
 			messages.DELETE("/:messageId/reactions", messageHandler.RemoveReaction)
+			messages.GET("/:messageId/reactions", messageHandler.GetMessageReactions)
+
+			// Starred messages
+			messages.GET("/starred", messageHandler.GetStarredMessages)
+			messages.POST("/:messageId/star", messageHandler.StarMessage)
+			messages.DELETE("/:messageId/star", messageHandler.UnstarMessage)
+
+			// Polls
+			messages.GET("/:messageId/poll", messageHandler.GetPollResults)
+			messages.POST("/:messageId/poll/vote", messageHandler.VotePoll)
+			messages.DELETE("/:messageId/poll/vote", messageHandler.RetractPollVote)
+			messages.POST("/:messageId/poll/close", messageHandler.ClosePoll)
+
+			// Live location
+			messages.POST("/:messageId/live-location/stop", messageHandler.StopLiveLocation)
+
+			// Search across all chats
+			messages.GET("/search", messageHandler.SearchAllMessages)
+
+			// Contact cards
+			messages.POST("/contacts/import", messageHandler.ImportVCard)
+			messages.GET("/:messageId/vcard", messageHandler.ExportVCard)
+
+			// Mentions
+			messages.GET("/mentions", messageHandler.GetMentions)
+
+			// Pinned messages
+			messages.POST("/:messageId/pin", messageHandler.PinMessage)
+			messages.DELETE("/:messageId/pin", messageHandler.UnpinMessage)
 
 			// Message management
 			messages.POST("/forward", messageHandler.ForwardMessages)
 			messages.DELETE("/delete", messageHandler.DeleteMessage)
+			messages.DELETE("/delete-multiple", messageHandler.DeleteMessages)
+			messages.POST("/chat/:chatId/clear", messageHandler.ClearChat)
 			messages.PUT("/:messageId/edit", messageHandler.EditMessage)
+			messages.GET("/:messageId/history", messageHandler.GetEditHistory)
+
+			// Scheduled messages
+			messages.GET("/chat/:chatId/scheduled", messageHandler.GetScheduledMessages)
+			messages.PUT("/scheduled/:scheduledId", messageHandler.UpdateScheduledMessage)
+			messages.DELETE("/scheduled/:scheduledId", messageHandler.CancelScheduledMessage)
+
+			// Disappearing messages
+			messages.PUT("/chat/:chatId/disappearing", messageHandler.SetDisappearingTimer)
 
 			// Search
 			messages.GET("/chat/:chatId/search", messageHandler.SearchMessages)
@@ -234,31 +343,71 @@ func main() {
 					"GET /api/users/profile": "Get user profile",
 					"PUT /api/users/profile": "Update user profile",
 					"GET /api/users/search":  "Search users",
+					"PUT /api/users/privacy": "Update privacy settings (hideReadReceipts)",
+				},
+				"broadcasts": map[string]string{
+					"POST /api/broadcasts":              "Create a broadcast list",
+					"GET /api/broadcasts":               "Get my broadcast lists",
+					"GET /api/broadcasts/:listId":       "Get a broadcast list",
+					"PUT /api/broadcasts/:listId":       "Rename a list or replace its recipients",
+					"DELETE /api/broadcasts/:listId":    "Delete a broadcast list",
+					"POST /api/broadcasts/:listId/send": "Send a message to each recipient as a direct message",
+					"GET /api/broadcasts/:listId/sends": "Send history with delivery and read counts",
 				},
 				"chats": map[string]string{
-					"POST /api/chats":        "Create new chat",
-					"GET /api/chats":         "Get user chats",
-					"GET /api/chats/:chatId": "Get specific chat",
+					"POST /api/chats":                 "Create new chat",
+					"GET /api/chats":                  "Get user chats",
+					"GET /api/chats/:chatId":          "Get specific chat",
+					"GET /api/chats/:chatId/pins":     "Get pinned messages",
+					"GET /api/chats/:chatId/draft":    "Get my draft for the chat",
+					"PUT /api/chats/:chatId/draft":    "Save my draft (content, replyToId); empty clears it",
+					"DELETE /api/chats/:chatId/draft": "Discard my draft",
+					"POST /api/chats/import":          "Import a WhatsApp .txt or .zip export (multipart; dryRun=true to preview)",
+					"GET /api/chats/:chatId/export":   "Export chat as txt, zip (with media) or json; ?format=, ?tz=",
 				},
 				"messages": map[string]string{
-					"POST /api/messages":                          "Send text message",
-					"POST /api/messages/media":                    "Send media message with file upload",
-					"POST /api/messages/upload":                   "Upload file only",
-					"GET /api/messages/chat/:chatId":              "Get chat messages",
-					"GET /api/messages/:messageId":                "Get specific message",
-					"PUT /api/messages/:messageId/read":           "Mark message as read",
-					"PUT /api/messages/read-multiple":             "Mark multiple messages as read",
-					"GET /api/messages/chat/:chatId/unread-count": "Get unread message count",
-					"GET /api/messages/chat/:chatId/media":        "Get media messages",
-					"GET /api/messages/chat/:chatId/search":       "Search messages in chat",
-					"POST /api/messages/reactions":                "Add reaction to message",
-					"DELETE /api/messages/:messageId/reactions":   "Remove reaction from message",
-					"POST /api/messages/forward":                  "Forward messages",
-					"DELETE /api/messages/delete":                 "Delete message",
-					"PUT /api/messages/:messageId/edit":           "Edit message",
+					"POST /api/messages":                               "Send text message (or schedule it with sendAt); retries with the same clientMessageId return the original",
+					"GET /api/messages/chat/:chatId/scheduled":         "Get pending scheduled messages",
+					"PUT /api/messages/scheduled/:scheduledId":         "Edit a pending scheduled message",
+					"DELETE /api/messages/scheduled/:scheduledId":      "Cancel a pending scheduled message",
+					"POST /api/messages/media":                         "Send media message with file upload (optional clientMessageId)",
+					"POST /api/messages/upload":                        "Upload file only",
+					"GET /api/messages/chat/:chatId":                   "Get chat messages (before/after/around cursors)",
+					"GET /api/messages/:messageId":                     "Get specific message",
+					"GET /api/messages/:messageId/thread":              "Get a message and all replies below it (after cursor)",
+					"GET /api/messages/:messageId/info":                "Delivered/read times per recipient (sender only)",
+					"PUT /api/messages/:messageId/read":                "Mark message as read",
+					"PUT /api/messages/read-multiple":                  "Mark multiple messages as read",
+					"GET /api/messages/chat/:chatId/unread-count":      "Get unread message count",
+					"GET /api/messages/chat/:chatId/media":             "Get media messages",
+					"GET /api/messages/search":                         "Search all my chats (q, chatId, senderId, type, from, to, hasMedia, hasLink, limit/offset)",
+					"GET /api/messages/chat/:chatId/search":            "Search messages in chat",
+					"POST /api/messages/reactions":                     "Add reaction to message (any single emoji, several per user)",
+					"DELETE /api/messages/:messageId/reactions":        "Remove reaction from message (?reaction= for one, otherwise all of yours)",
+					"GET /api/messages/:messageId/reactions":           "Who reacted with what, grouped by emoji (?reaction=&limit=&offset=)",
+					"GET /api/messages/starred":                        "Get starred messages (chatId filter, limit/offset)",
+					"POST /api/messages/:messageId/star":               "Star message",
+					"DELETE /api/messages/:messageId/star":             "Unstar message",
+					"GET /api/messages/:messageId/poll":                "Get poll results",
+					"POST /api/messages/:messageId/poll/vote":          "Vote in a poll or change the vote",
+					"DELETE /api/messages/:messageId/poll/vote":        "Retract poll vote",
+					"POST /api/messages/:messageId/poll/close":         "Close a poll (creator only)",
+					"POST /api/messages/:messageId/live-location/stop": "Stop sharing live location",
+					"POST /api/messages/contacts/import":               "Import a .vcf file as contact messages (multipart file + chatId)",
+					"GET /api/messages/:messageId/vcard":               "Download a contact message as .vcf (version=3.0|4.0)",
+					"GET /api/messages/mentions":                       "Get messages that mention me (limit/offset)",
+					"POST /api/messages/:messageId/pin":                "Pin message (optional duration 24h/7d/30d)",
+					"DELETE /api/messages/:messageId/pin":              "Unpin message",
+					"POST /api/messages/forward":                       "Forward messages",
+					"DELETE /api/messages/delete":                      "Delete message",
+					"DELETE /api/messages/delete-multiple":             "Delete up to 100 messages of one chat (chatId, messageIds, deleteForMe)",
+					"POST /api/messages/chat/:chatId/clear":            "Clear chat for me only (optional keepStarred)",
+					"PUT /api/messages/:messageId/edit":                "Edit message",
+					"GET /api/messages/:messageId/history":             "Get message edit history",
+					"PUT /api/messages/chat/:chatId/disappearing":      "Set disappearing messages timer for a direct chat",
 				},
 				"websocket": map[string]string{
-					"GET /api/ws": "WebSocket connection for real-time features",
+					"GET /api/ws": "WebSocket connection for real-time features (send location_update to stream live location, sync after connecting to receive missed events; chat_cleared syncs clears between your devices)",
 				},
 			},
 			"auth_flow": map[string]interface{}{
@@ -0,0 +1,81 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MaxBroadcastRecipients = 256
+	MaxBroadcastNameLength = 100
+)
+
+// BroadcastList is a saved set of recipients. Sending to it delivers a
+// separate direct message to each one, so recipients never see each other
+// and replies come back privately.
+type BroadcastList struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	OwnerID    primitive.ObjectID   `bson:"owner_id" json:"ownerId"`
+	Name       string               `bson:"name" json:"name"`
+	Recipients []primitive.ObjectID `bson:"recipients" json:"recipients"`
+	CreatedAt  time.Time            `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time            `bson:"updated_at" json:"updatedAt"`
+}
+
+// BroadcastSend records one message sent to a list and the direct message
+// it became for each recipient.
+type BroadcastSend struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ListID     primitive.ObjectID  `bson:"list_id" json:"listId"`
+	OwnerID    primitive.ObjectID  `bson:"owner_id" json:"ownerId"`
+	Type       MessageType         `bson:"type" json:"type"`
+	Content    string              `bson:"content" json:"content"`
+	Deliveries []BroadcastDelivery `bson:"deliveries" json:"deliveries"`
+	Stats      *BroadcastSendStats `bson:"-" json:"stats,omitempty"`
+	CreatedAt  time.Time           `bson:"created_at" json:"createdAt"`
+}
+
+type BroadcastDelivery struct {
+	RecipientID primitive.ObjectID  `bson:"recipient_id" json:"recipientId"`
+	ChatID      primitive.ObjectID  `bson:"chat_id,omitempty" json:"chatId,omitempty"`
+	MessageID   *primitive.ObjectID `bson:"message_id,omitempty" json:"messageId,omitempty"`
+	Error       string              `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// BroadcastSendStats sums receipts across every recipient of a send. Read
+// counts as delivered too; recipients who hide read receipts never count
+// as read.
+type BroadcastSendStats struct {
+	Recipients int `json:"recipients"`
+	Sent       int `json:"sent"`
+	Failed     int `json:"failed"`
+	Delivered  int `json:"delivered"`
+	Read       int `json:"read"`
+}
+
+// Request structures
+type CreateBroadcastListRequest struct {
+	Name       string               `json:"name" binding:"required"`
+	Recipients []primitive.ObjectID `json:"recipients" binding:"required"`
+}
+
+type UpdateBroadcastListRequest struct {
+	Name       *string               `json:"name,omitempty"`
+	Recipients *[]primitive.ObjectID `json:"recipients,omitempty"`
+}
+
+// BroadcastMessageRequest is a SendMessageRequest without a chat; polls and
+// replies make no sense across separate chats.
+type BroadcastMessageRequest struct {
+	Type       MessageType      `json:"type" binding:"required"`
+	Content    string           `json:"content"`
+	MediaURL   string           `json:"mediaUrl,omitempty"`
+	MediaType  string           `json:"mediaType,omitempty"`
+	FileName   string           `json:"fileName,omitempty"`
+	FileSize   int64            `json:"fileSize,omitempty"`
+	Duration   int              `json:"duration,omitempty"`
+	Dimensions *MediaDimensions `json:"dimensions,omitempty"`
+	Location   *LocationRequest `json:"location,omitempty"`
+	Contact    *ContactCard     `json:"contact,omitempty"`
+}
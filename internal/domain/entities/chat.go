@@ -33,6 +33,12 @@ type Chat struct {
 	MutedUntil *time.Time           `bson:"muted_until,omitempty" json:"mutedUntil,omitempty"`
 	IsArchived bool                 `bson:"is_archived" json:"isArchived"`
 
+	DisappearingTime int `bson:"disappearing_time,omitempty" json:"disappearingTime,omitempty"` // Direct chats, in seconds
+
+	// The requesting user's unsent draft; never stored on the chat
+	Draft        *MessageDraft `bson:"-" json:"draft,omitempty"`
+	DraftPreview string        `bson:"-" json:"draftPreview,omitempty"` // "Draft: ..." for the chat list
+
 }
 
 type CreateChatRequest struct {
@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatClear marks a chat as cleared by one user. Messages created up to
+// ClearedAt are hidden from that user only, apart from the starred ones
+// kept at the time. History imported after the clear stays visible even
+// though it is dated earlier. Clearing again moves the marker forward.
+type ChatClear struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID   `bson:"user_id" json:"userId"`
+	ChatID         primitive.ObjectID   `bson:"chat_id" json:"chatId"`
+	ClearedAt      time.Time            `bson:"cleared_at" json:"clearedAt"`
+	KeptMessageIDs []primitive.ObjectID `bson:"kept_message_ids,omitempty" json:"keptMessageIds,omitempty"`
+}
+
+type ClearChatRequest struct {
+	KeepStarred bool `json:"keepStarred"`
+}
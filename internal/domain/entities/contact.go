@@ -0,0 +1,29 @@
+package entities
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MaxContactFields     = 20 // Phones or emails per card
+	MaxContactsPerImport = 50 // Cards turned into messages from one .vcf
+	MaxVCardSize         = 1 << 20
+)
+
+// ContactCard is a shared contact. UserID is filled in by the server when a
+// phone number or e-mail belongs to a registered user, so clients can offer
+// to message them.
+type ContactCard struct {
+	Name         string              `bson:"name" json:"name"`
+	FirstName    string              `bson:"first_name,omitempty" json:"firstName,omitempty"`
+	LastName     string              `bson:"last_name,omitempty" json:"lastName,omitempty"`
+	Organization string              `bson:"organization,omitempty" json:"organization,omitempty"`
+	Phones       []ContactField      `bson:"phones,omitempty" json:"phones,omitempty"`
+	Emails       []ContactField      `bson:"emails,omitempty" json:"emails,omitempty"`
+	UserID       *primitive.ObjectID `bson:"user_id,omitempty" json:"userId,omitempty"`
+}
+
+type ContactField struct {
+	Value string `bson:"value" json:"value"`
+	Type  string `bson:"type,omitempty" json:"type,omitempty"` // cell, work, home...
+}
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MaxDraftLength     = 16 << 10 // Bytes; drafts are synced over the WebSocket
+	DraftPreviewPrefix = "Draft: "
+)
+
+// MessageDraft is a half-typed message, kept per user and chat so it follows
+// the user between devices.
+type MessageDraft struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID  `bson:"user_id" json:"userId"`
+	ChatID    primitive.ObjectID  `bson:"chat_id" json:"chatId"`
+	Content   string              `bson:"content" json:"content"`
+	ReplyToID *primitive.ObjectID `bson:"reply_to_id,omitempty" json:"replyToId,omitempty"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updatedAt"`
+}
+
+// SaveDraftRequest replaces the draft. An empty content with no reply
+// clears it.
+type SaveDraftRequest struct {
+	Content   string              `json:"content"`
+	ReplyToID *primitive.ObjectID `json:"replyToId,omitempty"`
+}
@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportFormat string
+
+const (
+	ExportText ExportFormat = "txt"  // WhatsApp-style transcript
+	ExportZip  ExportFormat = "zip"  // Transcript plus the media it references
+	ExportJSON ExportFormat = "json" // Structured archive
+)
+
+type ChatExportRequest struct {
+	Format   ExportFormat `form:"format"`
+	Timezone string       `form:"tz"` // IANA name for transcript timestamps; defaults to UTC
+}
+
+// ChatArchive heads a JSON export. The messages follow it in the same
+// object, written one at a time so large chats are never held in memory.
+type ChatArchive struct {
+	ChatID       primitive.ObjectID   `json:"chatId"`
+	Type         ChatType             `json:"type"`
+	Name         string               `json:"name"`
+	Participants []ArchiveParticipant `json:"participants"`
+	ExportedBy   primitive.ObjectID   `json:"exportedBy"`
+	ExportedAt   time.Time            `json:"exportedAt"`
+}
+
+type ArchiveParticipant struct {
+	UserID   primitive.ObjectID `json:"userId"`
+	Username string             `json:"username"`
+}
+
+// ArchivedMessage is a message as the exporting user sees it. Other users'
+// receipts are left out.
+type ArchivedMessage struct {
+	ID         primitive.ObjectID  `json:"id"`
+	SenderID   primitive.ObjectID  `json:"senderId"`
+	SenderName string              `json:"senderName,omitempty"`
+	Type       MessageType         `json:"type"`
+	Content    string              `json:"content"`
+	MediaURL   string              `json:"mediaUrl,omitempty"`
+	MediaType  string              `json:"mediaType,omitempty"`
+	FileName   string              `json:"fileName,omitempty"`
+	FileSize   int64               `json:"fileSize,omitempty"`
+	Duration   int                 `json:"duration,omitempty"`
+	Poll       *Poll               `json:"poll,omitempty"`
+	Location   *Location           `json:"location,omitempty"`
+	Contact    *ContactCard        `json:"contact,omitempty"`
+	ReplyToID  *primitive.ObjectID `json:"replyToId,omitempty"`
+	Reactions  []MessageReaction   `json:"reactions,omitempty"`
+	Forwarded  bool                `json:"forwarded,omitempty"`
+	EditedAt   *time.Time          `json:"editedAt,omitempty"`
+	EditCount  int                 `json:"editCount,omitempty"`
+	IsDeleted  bool                `json:"isDeleted,omitempty"`
+	DeletedAt  *time.Time          `json:"deletedAt,omitempty"`
+	CreatedAt  time.Time           `json:"createdAt"`
+}
@@ -46,8 +46,12 @@ type GroupSettings struct {
 	WhoCanSendMessages   string `bson:"who_can_send_messages" json:"whoCanSendMessages"`
 	WhoCanEditInfo       string `bson:"who_can_edit_info" json:"whoCanEditInfo"`
 	WhoCanAddMembers     string `bson:"who_can_add_members" json:"whoCanAddMembers"`
+	WhoCanPinMessages    string `bson:"who_can_pin_messages,omitempty" json:"whoCanPinMessages,omitempty"`
 	DisappearingMessages bool   `bson:"disappearing_messages" json:"disappearingMessages"`
 	DisappearingTime     int    `bson:"disappearing_time,omitempty" json:"disappearingTime,omitempty"`
+
+	// Emoji members may react with; empty allows any
+	AllowedReactions []ReactionType `bson:"allowed_reactions,omitempty" json:"allowedReactions,omitempty"`
 }
 
 type GroupMember struct {
@@ -115,8 +119,12 @@ type UpdateGroupSettingsRequest struct {
 	WhoCanSendMessages   string `json:"whoCanSendMessages,omitempty"`
 	WhoCanEditInfo       string `json:"whoCanEditInfo,omitempty"`
 	WhoCanAddMembers     string `json:"whoCanAddMembers,omitempty"`
+	WhoCanPinMessages    string `json:"whoCanPinMessages,omitempty"`
 	DisappearingMessages bool   `json:"disappearingMessages"`
 	DisappearingTime     *int   `json:"disappearingTime,omitempty"`
+
+	// Replaces the reaction allowlist when present; an empty list allows any
+	AllowedReactions *[]ReactionType `json:"allowedReactions,omitempty"`
 }
 
 type AddMembersRequest struct {
@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MaxChatImportSize     = 2 << 30  // Upload, media included
+	MaxTranscriptSize     = 64 << 20 // The .txt inside
+	MaxImportedMessages   = 200000
+	MaxImportedSenders    = 256
+	DefaultImportChatName = "Imported chat"
+)
+
+// ChatImportRequest imports a WhatsApp export into ChatID, or into a new
+// group chat named Name when ChatID is nil.
+type ChatImportRequest struct {
+	ChatID    *primitive.ObjectID
+	Name      string
+	Timezone  string                        // IANA name of the exporting phone's zone; defaults to UTC
+	DateOrder string                        // "dmy", "mdy" or "ymd"; detected when empty
+	Senders   map[string]primitive.ObjectID // Transcript name to user, overriding matching; only the importer in an existing chat
+	DryRun    bool                          // Report what would be imported without writing
+}
+
+type ChatImportReport struct {
+	DryRun         bool                `json:"dryRun"`
+	ChatID         *primitive.ObjectID `json:"chatId,omitempty"` // Not set by a dry run into a new chat
+	DateOrder      string              `json:"dateOrder"`
+	Messages       int                 `json:"messages"`
+	FirstMessageAt *time.Time          `json:"firstMessageAt,omitempty"`
+	LastMessageAt  *time.Time          `json:"lastMessageAt,omitempty"`
+	Senders        []ImportSender      `json:"senders"`
+	Media          int                 `json:"media"`                  // Attachments found in the archive
+	MissingMedia   []string            `json:"missingMedia,omitempty"` // Referenced but absent or rejected
+	OmittedMedia   int                 `json:"omittedMedia"`           // Left out of the export by WhatsApp
+}
+
+// ImportSender is a name from the transcript and who it was mapped to.
+// Messages from unmapped names are posted by the importing user with the
+// name kept as a placeholder. Importing into an existing chat maps names to
+// the importer only.
+type ImportSender struct {
+	Name      string              `json:"name"`
+	UserID    *primitive.ObjectID `json:"userId,omitempty"`
+	MatchedBy string              `json:"matchedBy,omitempty"` // "mapping", "participant" or "phone"
+	Messages  int                 `json:"messages"`
+}
@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LinkPreview is attached to a text message once the first link in it has
+// been fetched. URL is the link as written in the message.
+type LinkPreview struct {
+	URL          string `bson:"url" json:"url"`
+	Title        string `bson:"title,omitempty" json:"title,omitempty"`
+	Description  string `bson:"description,omitempty" json:"description,omitempty"`
+	ImageURL     string `bson:"image_url,omitempty" json:"imageUrl,omitempty"`
+	ThumbnailURL string `bson:"thumbnail_url,omitempty" json:"thumbnailUrl,omitempty"` // Our stored copy of the image
+	SiteName     string `bson:"site_name,omitempty" json:"siteName,omitempty"`
+}
+
+// CachedLinkPreview is a fetched (or failed) preview. Preview is nil when the
+// page could not be used; Unreachable marks connection failures, which pause
+// fetching from the same host and port until the entry expires.
+type CachedLinkPreview struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL         string             `bson:"url" json:"url"`
+	Host        string             `bson:"host" json:"host"` // Lowercase host:port`
+	Preview     *LinkPreview       `bson:"preview,omitempty" json:"preview,omitempty"`
+	Unreachable bool               `bson:"unreachable,omitempty" json:"unreachable,omitempty"`
+	FetchedAt   time.Time          `bson:"fetched_at" json:"fetchedAt"`
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expiresAt"`
+}
@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"time"
+)
+
+const MaxPlaceNameLength = 256
+
+// LiveLocationDurations are the sharing periods a sender can choose from.
+var LiveLocationDurations = map[int]bool{
+	15 * 60:     true, // 15 minutes
+	60 * 60:     true, // 1 hour
+	8 * 60 * 60: true, // 8 hours
+}
+
+type Location struct {
+	Latitude  float64       `bson:"latitude" json:"latitude"`
+	Longitude float64       `bson:"longitude" json:"longitude"`
+	Accuracy  float64       `bson:"accuracy,omitempty" json:"accuracy,omitempty"` // Metres
+	PlaceName string        `bson:"place_name,omitempty" json:"placeName,omitempty"`
+	Address   string        `bson:"address,omitempty" json:"address,omitempty"`
+	Live      *LiveLocation `bson:"live,omitempty" json:"live,omitempty"`
+}
+
+// LiveLocation marks a location message whose position keeps updating until
+// the session expires or the sender ends it.
+type LiveLocation struct {
+	IsActive  bool       `bson:"is_active" json:"isActive"`
+	StartedAt time.Time  `bson:"started_at" json:"startedAt"`
+	ExpiresAt time.Time  `bson:"expires_at" json:"expiresAt"`
+	UpdatedAt time.Time  `bson:"updated_at" json:"updatedAt"`
+	EndedAt   *time.Time `bson:"ended_at,omitempty" json:"endedAt,omitempty"`
+}
+
+type LocationRequest struct {
+	Latitude     float64 `bson:"latitude" json:"latitude"`
+	Longitude    float64 `bson:"longitude" json:"longitude"`
+	Accuracy     float64 `bson:"accuracy,omitempty" json:"accuracy,omitempty"`
+	PlaceName    string  `bson:"place_name,omitempty" json:"placeName,omitempty"`
+	Address      string  `bson:"address,omitempty" json:"address,omitempty"`
+	LiveDuration int     `bson:"live_duration,omitempty" json:"liveDuration,omitempty"` // Seconds; zero for a static location
+}
@@ -17,8 +17,19 @@ const (
 	DocumentMessage MessageType = "document"
 	LocationMessage MessageType = "location"
 	ContactMessage  MessageType = "contact"
+	PollMessage     MessageType = "poll"
+	SystemMessage   MessageType = "system" // Server-generated timeline notices
 )
 
+// MaxClientMessageIDLength bounds the ID a client attaches to a send.
+const MaxClientMessageIDLength = 64
+
+// MaxBulkDeleteMessages bounds the messages deleted in one request.
+const MaxBulkDeleteMessages = 100
+
+// DeletedMessagePlaceholder replaces the content of messages deleted for everyone.
+const DeletedMessagePlaceholder = "This message was deleted"
+
 type MessageStatus string
 
 const (
@@ -28,6 +39,9 @@ const (
 	MessageFailed    MessageStatus = "failed"    // Message failed to send
 )
 
+// ReactionType is a single emoji. Any emoji is accepted unless the
+// deployment or group limits reactions to an allowlist; these are the
+// defaults clients offer.
 type ReactionType string
 
 const (
@@ -46,6 +60,9 @@ type Message struct {
 	Type     MessageType        `bson:"type" json:"type"`
 	Content  string             `bson:"content" json:"content"`
 
+	// Unique per sender and chat when set
+	ClientMessageID string `bson:"client_message_id,omitempty" json:"clientMessageId,omitempty"`
+
 	// Media and file information
 	MediaURL     string           `bson:"media_url,omitempty" json:"mediaUrl,omitempty"`
 	MediaType    string           `bson:"media_type,omitempty" json:"mediaType,omitempty"`
@@ -55,10 +72,33 @@ type Message struct {
 	Duration     int              `bson:"duration,omitempty" json:"duration,omitempty"` // For audio/video in seconds
 	Dimensions   *MediaDimensions `bson:"dimensions,omitempty" json:"dimensions,omitempty"`
 
+	// Poll and location messages
+	Poll     *Poll        `bson:"poll,omitempty" json:"poll,omitempty"`
+	Location *Location    `bson:"location,omitempty" json:"location,omitempty"`
+	Contact  *ContactCard `bson:"contact,omitempty" json:"contact,omitempty"`
+
+	// Markup in text and captions, parsed so clients render it alike.
+	// Entities index PlainText, which is Content without the markup; both
+	// are empty when the content has no formatting.
+	PlainText string       `bson:"plain_text,omitempty" json:"plainText,omitempty"`
+	Entities  []TextEntity `bson:"entities,omitempty" json:"entities,omitempty"`
+
+	// Filled in asynchronously for text messages containing a link
+	LinkPreview *LinkPreview `bson:"link_preview,omitempty" json:"linkPreview,omitempty"`
+
 	// Message features
-	ReplyToID     *primitive.ObjectID `bson:"reply_to_id,omitempty" json:"replyToId,omitempty"`
-	ForwardedFrom *primitive.ObjectID `bson:"forwarded_from,omitempty" json:"forwardedFrom,omitempty"`
-	IsForwarded   bool                `bson:"is_forwarded" json:"isForwarded"`
+	ReplyToID     *primitive.ObjectID  `bson:"reply_to_id,omitempty" json:"replyToId,omitempty"`
+	Mentions      []primitive.ObjectID `bson:"mentions,omitempty" json:"mentions,omitempty"`      // Participants @mentioned in the content
+	ThreadPath    []primitive.ObjectID `bson:"thread_path,omitempty" json:"threadPath,omitempty"` // Ancestors of a reply, thread root first
+	ReplyCount    int                  `bson:"reply_count,omitempty" json:"replyCount"`           // Direct and indirect replies
+	ForwardedFrom *primitive.ObjectID  `bson:"forwarded_from,omitempty" json:"forwardedFrom,omitempty"`
+	IsForwarded   bool                 `bson:"is_forwarded" json:"isForwarded"`
+
+	// Set on imported history, which clients mark as imported. CreatedAt
+	// keeps the original time, so ImportedAt records when the message
+	// actually arrived here.
+	ImportedSender string     `bson:"imported_sender,omitempty" json:"importedSender,omitempty"` // When the original sender has no account here
+	ImportedAt     *time.Time `bson:"imported_at,omitempty" json:"importedAt,omitempty"`
 
 	// Status and delivery
 	Status      MessageStatus  `bson:"status" json:"status"`
@@ -70,14 +110,25 @@ type Message struct {
 
 	// Metadata
 	EditedAt   *time.Time           `bson:"edited_at,omitempty" json:"editedAt,omitempty"`
+	EditCount  int                  `bson:"edit_count,omitempty" json:"editCount,omitempty"` // Current revision number
 	DeletedAt  *time.Time           `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+	DeletedBy  *primitive.ObjectID  `bson:"deleted_by,omitempty" json:"deletedBy,omitempty"`
 	DeletedFor []primitive.ObjectID `bson:"deleted_for,omitempty" json:"deletedFor,omitempty"`
 	IsDeleted  bool                 `bson:"is_deleted" json:"isDeleted"`
+	ExpiresAt  *time.Time           `bson:"expires_at,omitempty" json:"expiresAt,omitempty"` // Disappearing messages
 
 	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updatedAt"`
 }
 
+// TextEntity styles a span of a message's PlainText. Offset and Length
+// count UTF-16 code units.
+type TextEntity struct {
+	Type   string `bson:"type" json:"type"` // bold, italic, strikethrough, code, pre, quote, bullet_list, numbered_list
+	Offset int    `bson:"offset" json:"offset"`
+	Length int    `bson:"length" json:"length"`
+}
+
 type MediaDimensions struct {
 	Width  int `bson:"width" json:"width"`
 	Height int `bson:"height" json:"height"`
@@ -99,6 +150,16 @@ type MessageReaction struct {
 	AddedAt  time.Time          `bson:"added_at" json:"addedAt"`
 }
 
+// MessageRevision is one version of a message's content. Revision 0 is the
+// original text; each edit adds the next number.
+type MessageRevision struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MessageID primitive.ObjectID `bson:"message_id" json:"messageId"`
+	Revision  int                `bson:"revision" json:"revision"`
+	Content   string             `bson:"content" json:"content"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"` // When this version was written
+}
+
 // Request structures
 type SendMessageRequest struct {
 	ChatID     primitive.ObjectID  `json:"chatId" binding:"required"`
@@ -111,6 +172,14 @@ type SendMessageRequest struct {
 	Duration   int                 `json:"duration,omitempty"`
 	Dimensions *MediaDimensions    `json:"dimensions,omitempty"`
 	ReplyToID  *primitive.ObjectID `json:"replyToId,omitempty"`
+	Poll       *CreatePollRequest  `json:"poll,omitempty"`     // Required for poll messages
+	Location   *LocationRequest    `json:"location,omitempty"` // Required for location messages
+	Contact    *ContactCard        `json:"contact,omitempty"`  // Required for contact messages
+	SendAt     *time.Time          `json:"sendAt,omitempty"`   // Schedule for later delivery
+
+	// Set by the client so a retried send returns the original message
+	// instead of posting it again
+	ClientMessageID string `json:"clientMessageId,omitempty"`
 }
 
 type MessageReactionRequest struct {
@@ -118,16 +187,50 @@ type MessageReactionRequest struct {
 	Reaction  ReactionType       `json:"reaction" binding:"required"`
 }
 
+// ReactionGroup is everyone who reacted to a message with one emoji, a
+// page at a time.
+type ReactionGroup struct {
+	Reaction ReactionType    `json:"reaction"`
+	Count    int             `json:"count"`
+	Users    []*ReactionUser `json:"users"`
+	HasMore  bool            `json:"hasMore"`
+}
+
+type ReactionUser struct {
+	UserID   primitive.ObjectID `json:"userId"`
+	Username string             `json:"username"`
+	Avatar   string             `json:"avatar,omitempty"`
+	AddedAt  time.Time          `json:"addedAt"`
+}
+
+type MessageReactionsResponse struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	Total     int                `json:"total"`
+	Groups    []*ReactionGroup   `json:"groups"` // Most used first
+}
+
 type ForwardMessageRequest struct {
 	MessageIDs []primitive.ObjectID `json:"messageIds" binding:"required"`
 	ToChatIDs  []primitive.ObjectID `json:"toChatIds" binding:"required"`
 }
 
+type SetDisappearingTimerRequest struct {
+	DisappearingTime int `json:"disappearingTime"` // in seconds, 0 to turn off
+}
+
 type DeleteMessageRequest struct {
 	MessageID   primitive.ObjectID `json:"messageId" binding:"required"`
 	DeleteForMe bool               `json:"deleteForMe"`
 }
 
+// DeleteMessagesRequest deletes several messages of one chat at once.
+// Deleting for everyone goes ahead only if every message allows it.
+type DeleteMessagesRequest struct {
+	ChatID      primitive.ObjectID   `json:"chatId" binding:"required"`
+	MessageIDs  []primitive.ObjectID `json:"messageIds" binding:"required"`
+	DeleteForMe bool                 `json:"deleteForMe"`
+}
+
 // Response structures
 type MessageResponse struct {
 	*Message
@@ -135,9 +238,93 @@ type MessageResponse struct {
 	ReplyToMessage *Message             `json:"replyToMessage,omitempty"`
 	IsDelivered    bool                 `json:"isDelivered"`
 	IsRead         bool                 `json:"isRead"`
+	IsStarred      bool                 `json:"isStarred"`               // Private to the requesting user
+	UnreadReplies  int                  `json:"unreadReplies,omitempty"` // Set on thread roots the user follows
 	ReactionCount  map[ReactionType]int `json:"reactionCount"`
 }
 
+// Pagination structures
+type MessagePageRequest struct {
+	Before string              // Cursor: return messages older than this one
+	After  string              // Cursor: return messages newer than this one
+	Around *primitive.ObjectID // Message ID to centre the window on
+	Limit  int
+}
+
+type MessageCursor struct {
+	ID        primitive.ObjectID
+	CreatedAt time.Time
+}
+
+type MessagePage struct {
+	Messages   []*MessageResponse `json:"messages"`
+	NextCursor string             `json:"nextCursor,omitempty"` // Older messages
+	PrevCursor string             `json:"prevCursor,omitempty"` // Newer messages
+	HasMore    bool               `json:"hasMore"`
+}
+
+// MessageInfo breaks a message's delivery down by recipient. Recipients who
+// hide read receipts appear under Delivered (or Pending) with no ReadAt.
+type MessageInfo struct {
+	MessageID primitive.ObjectID      `json:"messageId"`
+	ChatID    primitive.ObjectID      `json:"chatId"`
+	SentAt    time.Time               `json:"sentAt"`
+	Read      []*MessageRecipientInfo `json:"read"`
+	Delivered []*MessageRecipientInfo `json:"delivered"` // Delivered but not (visibly) read
+	Pending   []*MessageRecipientInfo `json:"pending"`
+}
+
+type MessageRecipientInfo struct {
+	UserID      primitive.ObjectID `json:"userId"`
+	Username    string             `json:"username"`
+	FirstName   string             `json:"firstName"`
+	LastName    string             `json:"lastName"`
+	Avatar      string             `json:"avatar,omitempty"`
+	DeliveredAt *time.Time         `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time         `json:"readAt,omitempty"`
+}
+
+// MessageSearchRequest searches every chat the user is in. Query may be
+// empty when at least one filter is set.
+type MessageSearchRequest struct {
+	Query    string
+	ChatID   *primitive.ObjectID
+	SenderID *primitive.ObjectID
+	Type     MessageType
+	From     *time.Time // Inclusive
+	To       *time.Time // Exclusive
+	HasMedia bool
+	HasLink  bool
+	Limit    int
+	Offset   int
+}
+
+// TextRange marks part of a string, in UTF-16 code units as used by
+// JavaScript strings.
+type TextRange struct {
+	Offset int `json:"offset"`
+	Length int `json:"length"`
+}
+
+type MessageSearchResult struct {
+	*MessageResponse
+	Score      float64     `json:"score,omitempty"` // Text relevance; 0 without a query
+	Snippet    string      `json:"snippet,omitempty"`
+	Highlights []TextRange `json:"highlights,omitempty"` // Matches within Snippet
+}
+
+type MessageSearchPage struct {
+	Results    []*MessageSearchResult `json:"results"`
+	HasMore    bool                   `json:"hasMore"`
+	NextOffset int                    `json:"nextOffset,omitempty"`
+}
+
+type MessageEditHistory struct {
+	MessageID       primitive.ObjectID `json:"messageId"`
+	CurrentRevision int                `json:"currentRevision"`
+	Revisions       []MessageRevision  `json:"revisions"` // Oldest first, current last
+}
+
 type MessageStatusUpdate struct {
 	MessageID primitive.ObjectID `json:"messageId"`
 	Status    MessageStatus      `json:"status"`
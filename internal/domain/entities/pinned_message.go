@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pin durations offered to users; an empty duration pins until unpinned.
+var PinDurations = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+type PinnedMessage struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID    primitive.ObjectID `bson:"chat_id" json:"chatId"`
+	MessageID primitive.ObjectID `bson:"message_id" json:"messageId"`
+	PinnedBy  primitive.ObjectID `bson:"pinned_by" json:"pinnedBy"`
+	PinnedAt  time.Time          `bson:"pinned_at" json:"pinnedAt"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+}
+
+type PinnedMessageResponse struct {
+	*PinnedMessage
+	Message *MessageResponse `json:"message"`
+}
+
+type PinMessageRequest struct {
+	Duration string `json:"duration,omitempty"` // "24h", "7d", "30d" or empty
+}
@@ -0,0 +1,67 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	MinPollOptions = 2
+	MaxPollOptions = 12
+)
+
+// Poll is stored on a poll message. Votes live in their own collection and
+// results are always computed on the server.
+type Poll struct {
+	Question       string       `bson:"question" json:"question"`
+	Options        []PollOption `bson:"options" json:"options"`
+	MultipleChoice bool         `bson:"multiple_choice" json:"multipleChoice"`
+	Anonymous      bool         `bson:"anonymous" json:"anonymous"` // Hide who voted for what
+	IsClosed       bool         `bson:"is_closed" json:"isClosed"`
+	ClosedAt       *time.Time   `bson:"closed_at,omitempty" json:"closedAt,omitempty"`
+}
+
+type PollOption struct {
+	ID   string `bson:"id" json:"id"`
+	Text string `bson:"text" json:"text"`
+}
+
+type PollVote struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MessageID primitive.ObjectID `bson:"message_id" json:"messageId"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"userId"`
+	OptionIDs []string           `bson:"option_ids" json:"optionIds"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// ========== Request/Response Types ==========
+
+type CreatePollRequest struct {
+	Question       string   `bson:"question" json:"question"`
+	Options        []string `bson:"options" json:"options"`
+	MultipleChoice bool     `bson:"multiple_choice" json:"multipleChoice"`
+	Anonymous      bool     `bson:"anonymous" json:"anonymous"`
+}
+
+type PollVoteRequest struct {
+	OptionIDs []string `json:"optionIds" binding:"required"`
+}
+
+type PollResults struct {
+	MessageID   primitive.ObjectID `json:"messageId"`
+	ChatID      primitive.ObjectID `json:"chatId"`
+	Question    string             `json:"question"`
+	Options     []PollOptionResult `json:"options"`
+	TotalVoters int                `json:"totalVoters"`
+	Anonymous   bool               `json:"anonymous"`
+	IsClosed    bool               `json:"isClosed"`
+	MyVotes     []string           `json:"myVotes,omitempty"` // Only in per-user responses
+}
+
+type PollOptionResult struct {
+	ID     string               `json:"id"`
+	Text   string               `json:"text"`
+	Votes  int                  `json:"votes"`
+	Voters []primitive.ObjectID `json:"voters,omitempty"` // Omitted for anonymous polls
+}
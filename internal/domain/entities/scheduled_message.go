@@ -0,0 +1,57 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledPending   ScheduledMessageStatus = "pending"   // Waiting for its send time
+	ScheduledSending   ScheduledMessageStatus = "sending"   // Claimed by a dispatcher
+	ScheduledSent      ScheduledMessageStatus = "sent"      // Delivered through SendMessage
+	ScheduledCancelled ScheduledMessageStatus = "cancelled" // Cancelled by the sender
+	ScheduledFailed    ScheduledMessageStatus = "failed"    // Could not be delivered
+)
+
+type ScheduledMessage struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID   primitive.ObjectID `bson:"chat_id" json:"chatId"`
+	SenderID primitive.ObjectID `bson:"sender_id" json:"senderId"`
+	Type     MessageType        `bson:"type" json:"type"`
+	Content  string             `bson:"content" json:"content"`
+
+	// Carried over to the delivered message so retries stay idempotent
+	ClientMessageID string `bson:"client_message_id,omitempty" json:"clientMessageId,omitempty"`
+
+	// Media and file information
+	MediaURL   string              `bson:"media_url,omitempty" json:"mediaUrl,omitempty"`
+	MediaType  string              `bson:"media_type,omitempty" json:"mediaType,omitempty"`
+	FileName   string              `bson:"file_name,omitempty" json:"fileName,omitempty"`
+	FileSize   int64               `bson:"file_size,omitempty" json:"fileSize,omitempty"`
+	Duration   int                 `bson:"duration,omitempty" json:"duration,omitempty"`
+	Dimensions *MediaDimensions    `bson:"dimensions,omitempty" json:"dimensions,omitempty"`
+	ReplyToID  *primitive.ObjectID `bson:"reply_to_id,omitempty" json:"replyToId,omitempty"`
+	Poll       *CreatePollRequest  `bson:"poll,omitempty" json:"poll,omitempty"`
+	Location   *LocationRequest    `bson:"location,omitempty" json:"location,omitempty"`
+	Contact    *ContactCard        `bson:"contact,omitempty" json:"contact,omitempty"`
+
+	// Dispatch state
+	SendAt      time.Time              `bson:"send_at" json:"sendAt"`
+	Status      ScheduledMessageStatus `bson:"status" json:"status"`
+	LockedUntil *time.Time             `bson:"locked_until,omitempty" json:"-"`
+	MessageID   *primitive.ObjectID    `bson:"message_id,omitempty" json:"messageId,omitempty"`
+	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`
+	SentAt      *time.Time             `bson:"sent_at,omitempty" json:"sentAt,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+// Request structures
+type UpdateScheduledMessageRequest struct {
+	Content *string    `json:"content,omitempty"`
+	SendAt  *time.Time `json:"sendAt,omitempty"`
+}
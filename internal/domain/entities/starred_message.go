@@ -0,0 +1,22 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StarredMessage is a private bookmark; stars are never shared with other
+// chat participants.
+type StarredMessage struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"userId"`
+	MessageID primitive.ObjectID `bson:"message_id" json:"messageId"`
+	ChatID    primitive.ObjectID `bson:"chat_id" json:"chatId"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+type StarredMessageResponse struct {
+	*MessageResponse
+	StarredAt time.Time `json:"starredAt"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ThreadSubscription tracks a participant of a reply thread so they can be
+// told about replies they haven't seen yet. The thread root author and
+// everyone who replied are subscribed.
+type ThreadSubscription struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RootID      primitive.ObjectID `bson:"root_id" json:"rootId"`
+	ChatID      primitive.ObjectID `bson:"chat_id" json:"chatId"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"userId"`
+	UnreadCount int                `bson:"unread_count" json:"unreadCount"`
+	LastReplyAt *time.Time         `bson:"last_reply_at,omitempty" json:"lastReplyAt,omitempty"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+type ThreadPageRequest struct {
+	After string // Cursor: return replies newer than this one
+	Limit int
+}
+
+// ThreadPage holds a thread root and one page of its replies, oldest first.
+type ThreadPage struct {
+	Root       *MessageResponse   `json:"root"`
+	Replies    []*MessageResponse `json:"replies"`
+	NextCursor string             `json:"nextCursor,omitempty"` // Pass as "after" for the next page
+	HasMore    bool               `json:"hasMore"`
+}
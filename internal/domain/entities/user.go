@@ -22,10 +22,17 @@ type User struct {
 	VerifiedAt  *time.Time         `bson:"verified_at,omitempty" json:"verifiedAt,omitempty"`    // NEW
 	LoginMethod string             `bson:"login_method" json:"loginMethod"`                      // NEW - "magic_link", "password", "qr"
 	LastLoginAt *time.Time         `bson:"last_login_at,omitempty" json:"lastLoginAt,omitempty"` // NEW
+	Privacy     PrivacySettings    `bson:"privacy" json:"privacy"`
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
 }
 
+// PrivacySettings are off by default, so existing users share everything
+// until they opt out.
+type PrivacySettings struct {
+	HideReadReceipts bool `bson:"hide_read_receipts" json:"hideReadReceipts"` // Senders see delivered, never read
+}
+
 // Updated request structures
 type UserRegisterRequest struct {
 	Username  string `json:"username" binding:"required,min=3,max=30"`
@@ -51,6 +58,10 @@ type UserUpdateRequest struct {
 	Username  string `json:"username"`
 }
 
+type UpdatePrivacyRequest struct {
+	HideReadReceipts *bool `json:"hideReadReceipts"`
+}
+
 // Magic link user creation
 type MagicLinkUserRequest struct {
 	Email     string `json:"email" binding:"required,email"`
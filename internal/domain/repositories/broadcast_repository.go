@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BroadcastRepository interface {
+	// Lists
+	CreateList(ctx context.Context, list *entities.BroadcastList) error
+	GetList(ctx context.Context, listID, ownerID primitive.ObjectID) (*entities.BroadcastList, error)
+	GetOwnerLists(ctx context.Context, ownerID primitive.ObjectID) ([]*entities.BroadcastList, error)
+	UpdateList(ctx context.Context, list *entities.BroadcastList) error
+	DeleteList(ctx context.Context, listID, ownerID primitive.ObjectID) (bool, error)
+
+	// Sends
+	CreateSend(ctx context.Context, send *entities.BroadcastSend) error
+	GetSends(ctx context.Context, listID primitive.ObjectID, limit, offset int) ([]*entities.BroadcastSend, error)
+}
@@ -0,0 +1,14 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChatClearRepository interface {
+	Save(ctx context.Context, marker *entities.ChatClear) error
+	Get(ctx context.Context, userID, chatID primitive.ObjectID) (*entities.ChatClear, error) // nil when never cleared
+	GetForChats(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID) ([]*entities.ChatClear, error)
+}
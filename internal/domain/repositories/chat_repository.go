@@ -11,7 +11,9 @@ type ChatRepository interface {
 	Create(ctx context.Context, chat *entities.Chat) error
 	GetByID(ctx context.Context, id primitive.ObjectID) (*entities.Chat, error)
 	GetUserChats(ctx context.Context, userID primitive.ObjectID) ([]*entities.Chat, error)
+	GetDirectChat(ctx context.Context, userID, otherUserID primitive.ObjectID) (*entities.Chat, error)
 	UpdateLastMessage(ctx context.Context, chatID primitive.ObjectID, message *entities.Message) error
 	AddParticipant(ctx context.Context, chatID, userID primitive.ObjectID) error
 	RemoveParticipant(ctx context.Context, chatID, userID primitive.ObjectID) error
+	UpdateDisappearingTime(ctx context.Context, chatID primitive.ObjectID, seconds int) error
 }
@@ -0,0 +1,15 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DraftRepository interface {
+	Save(ctx context.Context, draft *entities.MessageDraft) error
+	Get(ctx context.Context, userID, chatID primitive.ObjectID) (*entities.MessageDraft, error)
+	Delete(ctx context.Context, userID, chatID primitive.ObjectID) (bool, error)
+	GetUserDrafts(ctx context.Context, userID primitive.ObjectID) ([]*entities.MessageDraft, error)
+}
@@ -4,6 +4,7 @@ package repositories
 import (
 	"bro-chat/internal/domain/entities"
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -45,6 +46,7 @@ type GroupRepository interface {
 	// ========== Activity Logging ==========
 	LogActivity(ctx context.Context, activity *entities.GroupActivity) error
 	GetGroupActivities(ctx context.Context, groupID primitive.ObjectID, limit int) ([]entities.GroupActivity, error)
+	GetMembershipChanges(ctx context.Context, groupIDs []primitive.ObjectID, userID primitive.ObjectID, since time.Time, limit int) ([]entities.GroupActivity, error)
 
 	// ========== Group Creation ==========
 	CreateGroup(ctx context.Context, group *entities.GroupInfo) error
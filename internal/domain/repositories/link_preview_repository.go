@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+	"time"
+)
+
+type LinkPreviewRepository interface {
+	// Get returns the unexpired cache entry for a URL, or nil if there is none
+	Get(ctx context.Context, url string, now time.Time) (*entities.CachedLinkPreview, error)
+	Save(ctx context.Context, entry *entities.CachedLinkPreview) error
+
+	// IsHostUnreachable reports whether a recent fetch from the host and
+	// port failed to connect
+	IsHostUnreachable(ctx context.Context, host string, now time.Time) (bool, error)
+}
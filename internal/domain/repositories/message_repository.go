@@ -8,11 +8,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Reads that take a ChatClear (or several, for reads across chats) leave
+// out the messages it hides; nil hides nothing.
 type MessageRepository interface {
 	// Basic CRUD operations
 	Create(ctx context.Context, message *entities.Message) error
+	CreateImported(ctx context.Context, messages []*entities.Message) error // Keeps each CreatedAt
+	CreateIdempotent(ctx context.Context, message *entities.Message) (*entities.Message, error)
+	GetByClientMessageID(ctx context.Context, chatID, senderID primitive.ObjectID, clientMessageID string) (*entities.Message, error)
 	GetByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error)
-	GetChatMessages(ctx context.Context, chatID primitive.ObjectID, limit, offset int) ([]*entities.Message, error)
+	GetByIDIncludingDeleted(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) // Also finds tombstones
+	GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entities.Message, error)
+	GetByIDsIncludingDeleted(ctx context.Context, ids []primitive.ObjectID) ([]*entities.Message, error) // Also finds tombstones
+	GetChatMessages(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, cursor *entities.MessageCursor, direction PageDirection, limit int) ([]*entities.Message, error)
 	Update(ctx context.Context, message *entities.Message) error
 	Delete(ctx context.Context, messageID primitive.ObjectID) error
 
@@ -20,29 +28,80 @@ type MessageRepository interface {
 	UpdateStatus(ctx context.Context, messageID primitive.ObjectID, status entities.MessageStatus) error
 	MarkAsDelivered(ctx context.Context, messageID, userID primitive.ObjectID) error
 	MarkAsRead(ctx context.Context, messageID, userID primitive.ObjectID) error
+	MarkMultipleAsDelivered(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) error
 	MarkMultipleAsRead(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) error
+	AdvanceStatus(ctx context.Context, messageID primitive.ObjectID, status entities.MessageStatus) (bool, error)
 
 	// Reactions
-	AddReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType) error
-	RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID) error
+	AddReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType, maxPerUser int) (bool, error) // Reports whether the reaction was added
+	RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType) error
 	GetMessageReactions(ctx context.Context, messageID primitive.ObjectID) ([]entities.MessageReaction, error)
 
 	// Message features
 	GetRepliedMessage(ctx context.Context, messageID primitive.ObjectID) (*entities.Message, error)
 	ForwardMessages(ctx context.Context, messageIDs []primitive.ObjectID, toChatIDs []primitive.ObjectID, senderID primitive.ObjectID) error
 
+	// Live location
+	UpdateLiveLocation(ctx context.Context, messageID, senderID primitive.ObjectID, latitude, longitude, accuracy float64, now time.Time) (*entities.Message, error)
+	EndLiveLocation(ctx context.Context, messageID primitive.ObjectID, now time.Time) (bool, error)
+	GetExpiredLiveLocations(ctx context.Context, now time.Time, limit int) ([]*entities.Message, error)
+
+	// Link previews
+	SetLinkPreview(ctx context.Context, messageID primitive.ObjectID, content string, preview *entities.LinkPreview) (*entities.Message, error)
+
+	// Threads
+	GetThreadReplies(ctx context.Context, rootID primitive.ObjectID, cursor *entities.MessageCursor, limit int) ([]*entities.Message, error)
+	IncrementReplyCount(ctx context.Context, messageIDs []primitive.ObjectID, delta int) error
+
 	// Deletion and editing
-	SoftDeleteMessage(ctx context.Context, messageID, userID primitive.ObjectID, deleteForEveryone bool) error
-	EditMessage(ctx context.Context, messageID primitive.ObjectID, newContent string) error
+	SoftDeleteMessages(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID, deleteForEveryone bool) error
+	EditMessage(ctx context.Context, messageID primitive.ObjectID, newContent, plainText string, textEntities []entities.TextEntity, mentions []primitive.ObjectID) (*entities.Message, error)
+	GetMessageRevisions(ctx context.Context, messageID primitive.ObjectID) ([]entities.MessageRevision, error)
 
 	// Search and filtering
-	SearchMessagesInChat(ctx context.Context, chatID primitive.ObjectID, query string, limit int) ([]*entities.Message, error)
-	GetMediaMessages(ctx context.Context, chatID primitive.ObjectID, mediaType entities.MessageType, limit, offset int) ([]*entities.Message, error)
+	SearchMessagesInChat(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, query string, limit int) ([]*entities.Message, error)
+	SearchMessages(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, clears []*entities.ChatClear, req *entities.MessageSearchRequest) ([]*ScoredMessage, error)
+	GetMediaMessages(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, mediaType entities.MessageType, limit, offset int) ([]*entities.Message, error)
+	GetMentions(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, clears []*entities.ChatClear, limit, offset int) ([]*entities.Message, error)
 
 	// Analytics and stats
-	GetUnreadMessageCount(ctx context.Context, chatID, userID primitive.ObjectID) (int64, error)
+	GetUnreadMessageCount(ctx context.Context, chatID, userID primitive.ObjectID, cleared *entities.ChatClear) (int64, error)
 	GetLastMessage(ctx context.Context, chatID primitive.ObjectID) (*entities.Message, error)
 	GetMessageStats(ctx context.Context, chatID primitive.ObjectID) (*MessageStats, error)
+
+	// Disappearing messages
+	GetExpiredMessages(ctx context.Context, before time.Time, limit int) ([]*entities.Message, error)
+	IsMediaReferenced(ctx context.Context, mediaURL string, excludeID primitive.ObjectID) (bool, error)
+
+	// Offline sync
+	GetChangedMessages(ctx context.Context, points []ChatSyncPoint, after *SyncCursor, until time.Time, limit int) ([]*entities.Message, error)
+}
+
+// PageDirection selects which side of a cursor GetChatMessages reads from.
+type PageDirection int
+
+const (
+	PageOlder PageDirection = iota // Messages created before the cursor
+	PageNewer                      // Messages created after the cursor
+)
+
+// ScoredMessage is a search hit with its text relevance score.
+type ScoredMessage struct {
+	*entities.Message
+	Score float64
+}
+
+// ChatSyncPoint asks for the changes to a chat made after Since.
+type ChatSyncPoint struct {
+	ChatID primitive.ObjectID
+	Since  time.Time
+}
+
+// SyncCursor is the position reached in a stream of changes ordered by
+// (updated_at, _id).
+type SyncCursor struct {
+	UpdatedAt time.Time
+	ID        primitive.ObjectID
 }
 
 type MessageStats struct {
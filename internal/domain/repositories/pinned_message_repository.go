@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PinnedMessageRepository interface {
+	// Basic operations
+	Pin(ctx context.Context, pin *entities.PinnedMessage) (bool, error) // Reports whether the pin is new or had expired
+	Unpin(ctx context.Context, chatID, messageID primitive.ObjectID) (bool, error)
+	GetChatPins(ctx context.Context, chatID primitive.ObjectID, now time.Time) ([]*entities.PinnedMessage, error)
+	IsPinned(ctx context.Context, chatID, messageID primitive.ObjectID, now time.Time) (bool, error)
+	CountPinsUpTo(ctx context.Context, chatID, pinID primitive.ObjectID, now time.Time) (int64, error)
+
+	// Cleanup
+	GetExpiredPins(ctx context.Context, before time.Time, limit int) ([]*entities.PinnedMessage, error)
+	DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error
+}
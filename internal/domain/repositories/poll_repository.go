@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PollRepository interface {
+	// Votes
+	SetVote(ctx context.Context, messageID, userID primitive.ObjectID, optionIDs []string) error
+	RemoveVote(ctx context.Context, messageID, userID primitive.ObjectID) (bool, error)
+	GetVotes(ctx context.Context, messageID primitive.ObjectID) ([]*entities.PollVote, error)
+
+	// Poll state
+	ClosePoll(ctx context.Context, messageID primitive.ObjectID) (bool, error)
+
+	// Cleanup when messages go away
+	DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error
+}
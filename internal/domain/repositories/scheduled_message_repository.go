@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ScheduledMessageRepository interface {
+	// Basic CRUD operations
+	Create(ctx context.Context, scheduled *entities.ScheduledMessage) error
+	CreateIdempotent(ctx context.Context, scheduled *entities.ScheduledMessage) (*entities.ScheduledMessage, error) // Returns the earlier one on a repeated client message ID
+	GetByClientMessageID(ctx context.Context, chatID, senderID primitive.ObjectID, clientMessageID string) (*entities.ScheduledMessage, error)
+	GetByID(ctx context.Context, id primitive.ObjectID) (*entities.ScheduledMessage, error)
+	GetPendingForChat(ctx context.Context, chatID, senderID primitive.ObjectID) ([]*entities.ScheduledMessage, error)
+	UpdatePending(ctx context.Context, id primitive.ObjectID, content *string, sendAt *time.Time) (bool, error)
+	Cancel(ctx context.Context, id primitive.ObjectID) (bool, error)
+
+	// Dispatching
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*entities.ScheduledMessage, error)
+	MarkSent(ctx context.Context, id, messageID primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, reason string) error
+	RequeueStale(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StarredMessageRepository interface {
+	// Per-user stars
+	Star(ctx context.Context, star *entities.StarredMessage) error
+	Unstar(ctx context.Context, userID, messageID primitive.ObjectID) error
+	UnstarMessages(ctx context.Context, userID primitive.ObjectID, messageIDs []primitive.ObjectID) error
+	UnstarChat(ctx context.Context, userID, chatID primitive.ObjectID) error
+	GetStarred(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, limit, offset int) ([]*entities.StarredMessage, error) // No limit when zero
+	GetStarredIDs(ctx context.Context, userID primitive.ObjectID, messageIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error)
+
+	// Cleanup when messages go away
+	DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ThreadRepository interface {
+	// Subscriptions
+	Subscribe(ctx context.Context, rootID, chatID, userID primitive.ObjectID) error
+	RecordReply(ctx context.Context, rootID, senderID primitive.ObjectID, repliedAt time.Time) ([]*entities.ThreadSubscription, error)
+	MarkRead(ctx context.Context, rootID, userID primitive.ObjectID) error
+	GetUnreadCounts(ctx context.Context, userID primitive.ObjectID, rootIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error)
+}
@@ -3,6 +3,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +24,15 @@ type Config struct {
 	SMTPPassword string
 	FromEmail    string
 	FromName     string
+
+	// Messaging policy
+	MessageEditWindow   time.Duration // Zero lets senders edit at any time
+	MessageDeleteWindow time.Duration // Zero lets senders delete for everyone at any time
+	MaxPinnedMessages   int
+	ReactionAllowlist   []string // Empty allows any single emoji
+
+	// Link previews
+	LinkPreviewTimeout time.Duration
 }
 
 func Load() *Config {
@@ -40,6 +52,15 @@ func Load() *Config {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		FromEmail:    getEnv("FROM_EMAIL", "noreply@whatsapp-clone.com"),
 		FromName:     getEnv("FROM_NAME", "WhatsApp Clone"),
+
+		// Messaging policy
+		MessageEditWindow:   getEnvDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+		MessageDeleteWindow: getEnvDuration("MESSAGE_DELETE_WINDOW", 24*time.Hour),
+		MaxPinnedMessages:   getEnvInt("MAX_PINNED_MESSAGES", 3),
+		ReactionAllowlist:   getEnvList("REACTION_ALLOWLIST"),
+
+		// Link previews
+		LinkPreviewTimeout: getEnvDuration("LINK_PREVIEW_TIMEOUT", 5*time.Second),
 	}
 }
 
@@ -49,3 +70,32 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated value, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type broadcastRepository struct {
+	listCollection *mongo.Collection
+	sendCollection *mongo.Collection
+}
+
+func NewBroadcastRepository(db *mongo.Database) repositories.BroadcastRepository {
+	repo := &broadcastRepository{
+		listCollection: db.Collection("broadcast_lists"),
+		sendCollection: db.Collection("broadcast_sends"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *broadcastRepository) createIndexes() {
+	ctx := context.Background()
+
+	// Index for the owner's lists
+	r.listCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"owner_id", 1},
+			{"updated_at", -1},
+		},
+	})
+
+	// Index for a list's send history
+	r.sendCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"list_id", 1},
+			{"created_at", -1},
+		},
+	})
+}
+
+// ========== Lists ==========
+
+func (r *broadcastRepository) CreateList(ctx context.Context, list *entities.BroadcastList) error {
+	list.ID = primitive.NewObjectID()
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+
+	_, err := r.listCollection.InsertOne(ctx, list)
+	return err
+}
+
+func (r *broadcastRepository) GetList(ctx context.Context, listID, ownerID primitive.ObjectID) (*entities.BroadcastList, error) {
+	var list entities.BroadcastList
+	err := r.listCollection.FindOne(ctx, bson.M{"_id": listID, "owner_id": ownerID}).Decode(&list)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *broadcastRepository) GetOwnerLists(ctx context.Context, ownerID primitive.ObjectID) ([]*entities.BroadcastList, error) {
+	opts := options.Find().SetSort(bson.D{{"updated_at", -1}})
+
+	cursor, err := r.listCollection.Find(ctx, bson.M{"owner_id": ownerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var lists []*entities.BroadcastList
+	for cursor.Next(ctx) {
+		var list entities.BroadcastList
+		if err := cursor.Decode(&list); err != nil {
+			continue
+		}
+		lists = append(lists, &list)
+	}
+
+	return lists, nil
+}
+
+func (r *broadcastRepository) UpdateList(ctx context.Context, list *entities.BroadcastList) error {
+	list.UpdatedAt = time.Now()
+
+	_, err := r.listCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": list.ID, "owner_id": list.OwnerID},
+		bson.M{"$set": bson.M{
+			"name":       list.Name,
+			"recipients": list.Recipients,
+			"updated_at": list.UpdatedAt,
+		}},
+	)
+	return err
+}
+
+// DeleteList removes the list and its send history. The direct messages
+// already sent stay in their chats.
+func (r *broadcastRepository) DeleteList(ctx context.Context, listID, ownerID primitive.ObjectID) (bool, error) {
+	result, err := r.listCollection.DeleteOne(ctx, bson.M{"_id": listID, "owner_id": ownerID})
+	if err != nil {
+		return false, err
+	}
+	if result.DeletedCount == 0 {
+		return false, nil
+	}
+
+	_, err = r.sendCollection.DeleteMany(ctx, bson.M{"list_id": listID})
+	return true, err
+}
+
+// ========== Sends ==========
+
+func (r *broadcastRepository) CreateSend(ctx context.Context, send *entities.BroadcastSend) error {
+	send.ID = primitive.NewObjectID()
+	send.CreatedAt = time.Now()
+
+	_, err := r.sendCollection.InsertOne(ctx, send)
+	return err
+}
+
+func (r *broadcastRepository) GetSends(ctx context.Context, listID primitive.ObjectID, limit, offset int) ([]*entities.BroadcastSend, error) {
+	opts := options.Find().
+		SetSort(bson.D{{"created_at", -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.sendCollection.Find(ctx, bson.M{"list_id": listID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sends []*entities.BroadcastSend
+	for cursor.Next(ctx) {
+		var send entities.BroadcastSend
+		if err := cursor.Decode(&send); err != nil {
+			continue
+		}
+		sends = append(sends, &send)
+	}
+
+	return sends, nil
+}
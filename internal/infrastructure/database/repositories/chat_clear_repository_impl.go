@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type chatClearRepository struct {
+	collection *mongo.Collection
+}
+
+func NewChatClearRepository(db *mongo.Database) repositories.ChatClearRepository {
+	repo := &chatClearRepository{
+		collection: db.Collection("chat_clears"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *chatClearRepository) createIndexes() {
+	ctx := context.Background()
+
+	// One marker per user and chat; also serves the chat list lookup
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"user_id", 1},
+			{"chat_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// Save replaces the user's marker for the chat.
+func (r *chatClearRepository) Save(ctx context.Context, marker *entities.ChatClear) error {
+	set := bson.M{
+		"cleared_at": marker.ClearedAt,
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	if len(marker.KeptMessageIDs) > 0 {
+		set["kept_message_ids"] = marker.KeptMessageIDs
+	} else {
+		update["$unset"] = bson.M{"kept_message_ids": ""}
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	return r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": marker.UserID, "chat_id": marker.ChatID},
+		update,
+		opts,
+	).Decode(marker)
+}
+
+func (r *chatClearRepository) Get(ctx context.Context, userID, chatID primitive.ObjectID) (*entities.ChatClear, error) {
+	var marker entities.ChatClear
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "chat_id": chatID}).Decode(&marker)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &marker, nil
+}
+
+func (r *chatClearRepository) GetForChats(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID) ([]*entities.ChatClear, error) {
+	if len(chatIDs) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID, "chat_id": bson.M{"$in": chatIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var clears []*entities.ChatClear
+	for cursor.Next(ctx) {
+		var marker entities.ChatClear
+		if err := cursor.Decode(&marker); err != nil {
+			continue
+		}
+		clears = append(clears, &marker)
+	}
+
+	return clears, nil
+}
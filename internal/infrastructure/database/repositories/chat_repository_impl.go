@@ -64,6 +64,26 @@ func (r *chatRepository) GetUserChats(ctx context.Context, userID primitive.Obje
 	return chats, nil
 }
 
+// GetDirectChat finds the direct chat between two users. It returns nil
+// without an error when they have none.
+func (r *chatRepository) GetDirectChat(ctx context.Context, userID, otherUserID primitive.ObjectID) (*entities.Chat, error) {
+	var chat entities.Chat
+	err := r.collection.FindOne(ctx, bson.M{
+		"type": entities.DirectChat,
+		"participants": bson.M{
+			"$all":  []primitive.ObjectID{userID, otherUserID},
+			"$size": 2,
+		},
+	}).Decode(&chat)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
 func (r *chatRepository) UpdateLastMessage(ctx context.Context, chatID primitive.ObjectID, message *entities.Message) error {
 	_, err := r.collection.UpdateOne(
 		ctx,
@@ -101,3 +121,17 @@ func (r *chatRepository) RemoveParticipant(ctx context.Context, chatID, userID p
 	)
 	return err
 }
+
+func (r *chatRepository) UpdateDisappearingTime(ctx context.Context, chatID primitive.ObjectID, seconds int) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": chatID},
+		bson.M{
+			"$set": bson.M{
+				"disappearing_time": seconds,
+				"updated_at":        time.Now(),
+			},
+		},
+	)
+	return err
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type draftRepository struct {
+	collection *mongo.Collection
+}
+
+func NewDraftRepository(db *mongo.Database) repositories.DraftRepository {
+	repo := &draftRepository{
+		collection: db.Collection("message_drafts"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *draftRepository) createIndexes() {
+	ctx := context.Background()
+
+	// One draft per user and chat; also serves the chat list lookup
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"user_id", 1},
+			{"chat_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+func (r *draftRepository) Save(ctx context.Context, draft *entities.MessageDraft) error {
+	set := bson.M{
+		"content":    draft.Content,
+		"updated_at": draft.UpdatedAt,
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	if draft.ReplyToID != nil {
+		set["reply_to_id"] = *draft.ReplyToID
+	} else {
+		update["$unset"] = bson.M{"reply_to_id": ""}
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	return r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"user_id": draft.UserID, "chat_id": draft.ChatID},
+		update,
+		opts,
+	).Decode(draft)
+}
+
+func (r *draftRepository) Get(ctx context.Context, userID, chatID primitive.ObjectID) (*entities.MessageDraft, error) {
+	var draft entities.MessageDraft
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "chat_id": chatID}).Decode(&draft)
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+func (r *draftRepository) Delete(ctx context.Context, userID, chatID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "chat_id": chatID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *draftRepository) GetUserDrafts(ctx context.Context, userID primitive.ObjectID) ([]*entities.MessageDraft, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var drafts []*entities.MessageDraft
+	for cursor.Next(ctx) {
+		var draft entities.MessageDraft
+		if err := cursor.Decode(&draft); err != nil {
+			continue
+		}
+		drafts = append(drafts, &draft)
+	}
+
+	return drafts, nil
+}
@@ -105,10 +105,16 @@ func (r *groupRepository) UpdateGroupSettings(ctx context.Context, groupID primi
 	if req.WhoCanAddMembers != "" {
 		update["settings.who_can_add_members"] = req.WhoCanAddMembers
 	}
+	if req.WhoCanPinMessages != "" {
+		update["settings.who_can_pin_messages"] = req.WhoCanPinMessages
+	}
 	update["settings.disappearing_messages"] = req.DisappearingMessages
 	if req.DisappearingTime != nil {
 		update["settings.disappearing_time"] = *req.DisappearingTime
 	}
+	if req.AllowedReactions != nil {
+		update["settings.allowed_reactions"] = *req.AllowedReactions
+	}
 	update["updated_at"] = time.Now()
 
 	_, err := r.collection.UpdateOne(
@@ -462,6 +468,32 @@ func (r *groupRepository) GetGroupActivities(ctx context.Context, groupID primit
 	return activities, err
 }
 
+// GetMembershipChanges returns member activity after since, oldest first,
+// for the given groups and for any group the user was removed from.
+func (r *groupRepository) GetMembershipChanges(ctx context.Context, groupIDs []primitive.ObjectID, userID primitive.ObjectID, since time.Time, limit int) ([]entities.GroupActivity, error) {
+	filter := bson.M{
+		"type": bson.M{"$in": []string{
+			"member_added", "member_removed", "member_left", "member_joined", "member_role_changed",
+		}},
+		"created_at": bson.M{"$gt": since},
+		"$or": []bson.M{
+			{"group_id": bson.M{"$in": groupIDs}},
+			{"target_user_id": userID},
+		},
+	}
+
+	opts := options.Find().SetSort(bson.D{{"created_at", 1}}).SetLimit(int64(limit))
+	cursor, err := r.activityCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var activities []entities.GroupActivity
+	err = cursor.All(ctx, &activities)
+	return activities, err
+}
+
 // ========== Group Creation ==========
 
 func (r *groupRepository) CreateGroup(ctx context.Context, group *entities.GroupInfo) error {
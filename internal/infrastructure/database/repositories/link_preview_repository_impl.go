@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type linkPreviewRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLinkPreviewRepository(db *mongo.Database) repositories.LinkPreviewRepository {
+	repo := &linkPreviewRepository{
+		collection: db.Collection("link_previews"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *linkPreviewRepository) createIndexes() {
+	ctx := context.Background()
+
+	// One cache entry per URL
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"url", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	// Index for per-host failure lookups
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"host", 1}, {"unreachable", 1}},
+	})
+
+	// TTL index for automatic expiration
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"expires_at", 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+}
+
+func (r *linkPreviewRepository) Get(ctx context.Context, url string, now time.Time) (*entities.CachedLinkPreview, error) {
+	var entry entities.CachedLinkPreview
+	err := r.collection.FindOne(ctx, bson.M{
+		"url":        url,
+		"expires_at": bson.M{"$gt": now},
+	}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *linkPreviewRepository) Save(ctx context.Context, entry *entities.CachedLinkPreview) error {
+	set := bson.M{
+		"host":        entry.Host,
+		"unreachable": entry.Unreachable,
+		"fetched_at":  entry.FetchedAt,
+		"expires_at":  entry.ExpiresAt,
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+	if entry.Preview != nil {
+		set["preview"] = entry.Preview
+	} else {
+		update["$unset"] = bson.M{"preview": ""}
+	}
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"url": entry.URL},
+		update,
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *linkPreviewRepository) IsHostUnreachable(ctx context.Context, host string, now time.Time) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{
+		"host":        host,
+		"unreachable": true,
+		"expires_at":  bson.M{"$gt": now},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bro-chat/internal/domain/entities"
 	"bro-chat/internal/domain/repositories"
 	"context"
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -13,12 +14,14 @@ import (
 )
 
 type messageRepository struct {
-	collection *mongo.Collection
+	collection         *mongo.Collection
+	revisionCollection *mongo.Collection
 }
 
 func NewMessageRepository(db *mongo.Database) repositories.MessageRepository {
 	repo := &messageRepository{
-		collection: db.Collection("messages"),
+		collection:         db.Collection("messages"),
+		revisionCollection: db.Collection("message_revisions"),
 	}
 
 	// Create indexes for better performance
@@ -30,11 +33,14 @@ func NewMessageRepository(db *mongo.Database) repositories.MessageRepository {
 func (r *messageRepository) createIndexes() {
 	ctx := context.Background()
 
-	// Compound index for chat messages with timestamp
+	// Compound index for chat message pages, keyed on (created_at, _id)
+	// like their cursors. It replaces the earlier one without _id.
+	r.collection.Indexes().DropOne(ctx, "chat_id_1_created_at_-1")
 	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{
 			{"chat_id", 1},
 			{"created_at", -1},
+			{"_id", -1},
 		},
 	})
 
@@ -46,11 +52,20 @@ func (r *messageRepository) createIndexes() {
 		},
 	})
 
-	// Text search index for message content
+	// Text search index for message content (which holds media captions),
+	// its markup-free form and file names. A collection can only have one
+	// text index, so drop the earlier ones first.
+	r.collection.Indexes().DropOne(ctx, "content_text")
+	r.collection.Indexes().DropOne(ctx, "message_search")
 	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{
 			{"content", "text"},
+			{"plain_text", "text"},
+			{"file_name", "text"},
 		},
+		Options: options.Index().
+			SetName("message_text_search").
+			SetWeights(bson.D{{"content", 10}, {"plain_text", 10}, {"file_name", 5}}),
 	})
 
 	// Index for media messages
@@ -61,6 +76,78 @@ func (r *messageRepository) createIndexes() {
 			{"created_at", -1},
 		},
 	})
+
+	// Index for edit history lookups
+	r.revisionCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"message_id", 1},
+			{"revision", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+
+	// Index for the disappearing messages reaper
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"expires_at", 1}},
+		Options: options.Index().SetSparse(true),
+	})
+
+	// Sparse index for ending live location sessions at expiry
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"location.live.is_active", 1},
+			{"location.live.expires_at", 1},
+		},
+		Options: options.Index().SetSparse(true),
+	})
+
+	// Multikey index for the "mentions me" feed
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"mentions", 1},
+			{"created_at", -1},
+		},
+	})
+
+	// Partial index for imported history that arrived after a clear
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"imported_at", 1},
+		},
+		Options: options.Index().
+			SetPartialFilterExpression(bson.M{"imported_at": bson.M{"$exists": true}}),
+	})
+
+	// Multikey index for loading every reply below a thread root
+	r.collection.Indexes().DropOne(ctx, "thread_path_1_created_at_1")
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"thread_path", 1},
+			{"created_at", 1},
+			{"_id", 1},
+		},
+	})
+
+	// Makes sends with a client message ID idempotent per sender and chat
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"sender_id", 1},
+			{"client_message_id", 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"client_message_id": bson.M{"$type": "string"}}),
+	})
+
+	// Index for streaming changes to reconnecting clients
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"updated_at", 1},
+		},
+	})
 }
 
 func (r *messageRepository) Create(ctx context.Context, message *entities.Message) error {
@@ -73,6 +160,68 @@ func (r *messageRepository) Create(ctx context.Context, message *entities.Messag
 	return err
 }
 
+// CreateIdempotent creates a message carrying a client message ID. If the
+// sender already used that ID in the chat, nothing is inserted and the
+// earlier message is returned instead; otherwise it returns nil.
+func (r *messageRepository) CreateIdempotent(ctx context.Context, message *entities.Message) (*entities.Message, error) {
+	err := r.Create(ctx, message)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// Lost a race with a concurrent retry
+	existing, findErr := r.GetByClientMessageID(ctx, message.ChatID, message.SenderID, message.ClientMessageID)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if existing == nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// GetByClientMessageID finds a message by the ID its sender's client gave
+// it, tombstones included. It returns nil without an error when there is
+// none.
+func (r *messageRepository) GetByClientMessageID(ctx context.Context, chatID, senderID primitive.ObjectID, clientMessageID string) (*entities.Message, error) {
+	var message entities.Message
+	err := r.collection.FindOne(ctx, bson.M{
+		"chat_id":           chatID,
+		"sender_id":         senderID,
+		"client_message_id": clientMessageID,
+	}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// CreateImported inserts history brought in from elsewhere. Unlike Create
+// it keeps each message's CreatedAt and Status.
+func (r *messageRepository) CreateImported(ctx context.Context, messages []*entities.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	documents := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		message.ID = primitive.NewObjectID()
+		message.UpdatedAt = now
+		message.ImportedAt = &now
+		documents = append(documents, message)
+	}
+
+	_, err := r.collection.InsertMany(ctx, documents)
+	return err
+}
+
 func (r *messageRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) {
 	var message entities.Message
 	err := r.collection.FindOne(ctx, bson.M{"_id": id, "is_deleted": bson.M{"$ne": true}}).Decode(&message)
@@ -82,16 +231,229 @@ func (r *messageRepository) GetByID(ctx context.Context, id primitive.ObjectID)
 	return &message, nil
 }
 
-func (r *messageRepository) GetChatMessages(ctx context.Context, chatID primitive.ObjectID, limit, offset int) ([]*entities.Message, error) {
+func (r *messageRepository) GetByIDIncludingDeleted(ctx context.Context, id primitive.ObjectID) (*entities.Message, error) {
+	var message entities.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *messageRepository) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entities.Message, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "is_deleted": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) GetByIDsIncludingDeleted(ctx context.Context, ids []primitive.ObjectID) ([]*entities.Message, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) GetChatMessages(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, cursor *entities.MessageCursor, direction repositories.PageDirection, limit int) ([]*entities.Message, error) {
+	// Messages deleted for everyone stay in the timeline as tombstones
 	filter := bson.M{
-		"chat_id":    chatID,
-		"is_deleted": bson.M{"$ne": true},
+		"chat_id": chatID,
+	}
+	if cleared != nil {
+		filter["$and"] = bson.A{visibleAfterClear(cleared)}
+	}
+
+	// Keyset pagination on (created_at, _id) so that new messages arriving
+	// while a client scrolls never shift the pages it has already seen
+	sortOrder := -1
+	comparison := "$lt"
+	if direction == repositories.PageNewer {
+		sortOrder = 1
+		comparison = "$gt"
+	}
+
+	if cursor != nil {
+		filter["$or"] = []bson.M{
+			{"created_at": bson.M{comparison: cursor.CreatedAt}},
+			{"created_at": cursor.CreatedAt, "_id": bson.M{comparison: cursor.ID}},
+		}
 	}
 
 	opts := options.Find().
-		SetSort(bson.D{{"created_at", -1}}).
-		SetLimit(int64(limit)).
-		SetSkip(int64(offset))
+		SetSort(bson.D{{"created_at", sortOrder}, {"_id", sortOrder}}).
+		SetLimit(int64(limit))
+
+	cursorResult, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursorResult.Close(ctx)
+
+	var messages []*entities.Message
+	for cursorResult.Next(ctx) {
+		var message entities.Message
+		if err := cursorResult.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	// Always hand back newest first, whichever direction we read in
+	if direction == repositories.PageNewer {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
+
+// visibleAfterClear matches what a clear leaves in view: messages created
+// or imported after it and the ones it kept. Put this way round, rather
+// than excluding the cleared range, the (chat_id, created_at) and
+// (chat_id, imported_at) indexes skip cleared history instead of scanning
+// it.
+func visibleAfterClear(cleared *entities.ChatClear) bson.M {
+	visible := bson.A{
+		bson.M{"created_at": bson.M{"$gt": cleared.ClearedAt}},
+		bson.M{"imported_at": bson.M{"$gt": cleared.ClearedAt}},
+	}
+	if len(cleared.KeptMessageIDs) > 0 {
+		visible = append(visible, bson.M{"_id": bson.M{"$in": cleared.KeptMessageIDs}})
+	}
+	return bson.M{"$or": visible}
+}
+
+// hiddenByClears matches the messages the clears hide, for use under $nor
+// in reads across chats.
+func hiddenByClears(clears []*entities.ChatClear) bson.A {
+	hidden := make(bson.A, 0, len(clears))
+	for _, cleared := range clears {
+		condition := bson.M{
+			"chat_id":     cleared.ChatID,
+			"created_at":  bson.M{"$lte": cleared.ClearedAt},
+			"imported_at": bson.M{"$not": bson.M{"$gt": cleared.ClearedAt}},
+		}
+		if len(cleared.KeptMessageIDs) > 0 {
+			condition["_id"] = bson.M{"$nin": cleared.KeptMessageIDs}
+		}
+		hidden = append(hidden, condition)
+	}
+	return hidden
+}
+
+// ========== Link Previews ==========
+
+// SetLinkPreview attaches a preview, or removes it when preview is nil. It
+// only applies while the message still has the given content, so a slow
+// fetch cannot overwrite the preview of a newer edit; nil is returned
+// when nothing was updated.
+func (r *messageRepository) SetLinkPreview(ctx context.Context, messageID primitive.ObjectID, content string, preview *entities.LinkPreview) (*entities.Message, error) {
+	update := bson.M{"$set": bson.M{"link_preview": preview}}
+	if preview == nil {
+		update = bson.M{"$unset": bson.M{"link_preview": ""}}
+	}
+
+	var message entities.Message
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":        messageID,
+			"content":    content,
+			"is_deleted": bson.M{"$ne": true},
+		},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ========== Live Location ==========
+
+// UpdateLiveLocation moves an active, unexpired session owned by the sender
+// and returns the updated message, or mongo.ErrNoDocuments otherwise.
+func (r *messageRepository) UpdateLiveLocation(ctx context.Context, messageID, senderID primitive.ObjectID, latitude, longitude, accuracy float64, now time.Time) (*entities.Message, error) {
+	var message entities.Message
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":                      messageID,
+			"sender_id":                senderID,
+			"is_deleted":               bson.M{"$ne": true},
+			"location.live.is_active":  true,
+			"location.live.expires_at": bson.M{"$gt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"location.latitude":        latitude,
+				"location.longitude":       longitude,
+				"location.accuracy":        accuracy,
+				"location.live.updated_at": now,
+			},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *messageRepository) EndLiveLocation(ctx context.Context, messageID primitive.ObjectID, now time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": messageID, "location.live.is_active": true},
+		bson.M{
+			"$set": bson.M{
+				"location.live.is_active": false,
+				"location.live.ended_at":  now,
+				"updated_at":              now,
+			},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *messageRepository) GetExpiredLiveLocations(ctx context.Context, now time.Time, limit int) ([]*entities.Message, error) {
+	filter := bson.M{
+		"location.live.is_active":  true,
+		"location.live.expires_at": bson.M{"$lte": now},
+	}
+
+	opts := options.Find().SetLimit(int64(limit))
 
 	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
@@ -111,6 +473,55 @@ func (r *messageRepository) GetChatMessages(ctx context.Context, chatID primitiv
 	return messages, nil
 }
 
+// GetThreadReplies returns the direct and indirect replies to rootID after
+// the cursor, oldest first.
+func (r *messageRepository) GetThreadReplies(ctx context.Context, rootID primitive.ObjectID, cursor *entities.MessageCursor, limit int) ([]*entities.Message, error) {
+	filter := bson.M{
+		"thread_path": rootID,
+	}
+
+	if cursor != nil {
+		filter["$or"] = []bson.M{
+			{"created_at": bson.M{"$gt": cursor.CreatedAt}},
+			{"created_at": cursor.CreatedAt, "_id": bson.M{"$gt": cursor.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"created_at", 1}, {"_id", 1}}).
+		SetLimit(int64(limit))
+
+	cursorResult, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursorResult.Close(ctx)
+
+	var messages []*entities.Message
+	for cursorResult.Next(ctx) {
+		var message entities.Message
+		if err := cursorResult.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) IncrementReplyCount(ctx context.Context, messageIDs []primitive.ObjectID, delta int) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": messageIDs}},
+		bson.M{"$inc": bson.M{"reply_count": delta}},
+	)
+	return err
+}
+
 func (r *messageRepository) Update(ctx context.Context, message *entities.Message) error {
 	message.UpdatedAt = time.Now()
 
@@ -124,6 +535,12 @@ func (r *messageRepository) Update(ctx context.Context, message *entities.Messag
 
 func (r *messageRepository) Delete(ctx context.Context, messageID primitive.ObjectID) error {
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": messageID})
+	if err != nil {
+		return err
+	}
+
+	// Edit history goes with the message
+	_, err = r.revisionCollection.DeleteMany(ctx, bson.M{"message_id": messageID})
 	return err
 }
 
@@ -141,106 +558,137 @@ func (r *messageRepository) UpdateStatus(ctx context.Context, messageID primitiv
 	return err
 }
 
+// MarkAsDelivered records the first delivery to a recipient. Later calls
+// keep the original time.
 func (r *messageRepository) MarkAsDelivered(ctx context.Context, messageID, userID primitive.ObjectID) error {
-	deliveryInfo := entities.DeliveryInfo{
-		UserID:      userID,
-		DeliveredAt: time.Now(),
-	}
+	return r.MarkMultipleAsDelivered(ctx, []primitive.ObjectID{messageID}, userID)
+}
 
-	_, err := r.collection.UpdateOne(
+func (r *messageRepository) MarkMultipleAsDelivered(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) error {
+	now := time.Now()
+
+	_, err := r.collection.UpdateMany(
 		ctx,
-		bson.M{"_id": messageID},
 		bson.M{
-			"$addToSet": bson.M{"delivered_to": deliveryInfo},
-			"$set":      bson.M{"updated_at": time.Now()},
+			"_id":                  bson.M{"$in": messageIDs},
+			"sender_id":            bson.M{"$ne": userID},
+			"delivered_to.user_id": bson.M{"$ne": userID},
+		},
+		bson.M{
+			"$push": bson.M{"delivered_to": entities.DeliveryInfo{UserID: userID, DeliveredAt: now}},
+			"$set":  bson.M{"updated_at": now},
 		},
 	)
-
-	// Update status to delivered if not already read
-	if err == nil {
-		r.collection.UpdateOne(
-			ctx,
-			bson.M{
-				"_id":    messageID,
-				"status": entities.MessageSent,
-			},
-			bson.M{
-				"$set": bson.M{"status": entities.MessageDelivered},
-			},
-		)
-	}
-
 	return err
 }
 
 func (r *messageRepository) MarkAsRead(ctx context.Context, messageID, userID primitive.ObjectID) error {
-	readInfo := entities.ReadInfo{
-		UserID: userID,
-		ReadAt: time.Now(),
+	return r.MarkMultipleAsRead(ctx, []primitive.ObjectID{messageID}, userID)
+}
+
+// MarkMultipleAsRead records the first read by a recipient. A read message
+// has also been delivered, so a missing delivery receipt is filled in too.
+func (r *messageRepository) MarkMultipleAsRead(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) error {
+	if err := r.MarkMultipleAsDelivered(ctx, messageIDs, userID); err != nil {
+		return err
 	}
 
-	_, err := r.collection.UpdateOne(
+	now := time.Now()
+
+	_, err := r.collection.UpdateMany(
 		ctx,
-		bson.M{"_id": messageID},
 		bson.M{
-			"$addToSet": bson.M{"read_by": readInfo},
-			"$set": bson.M{
-				"status":     entities.MessageRead,
-				"updated_at": time.Now(),
-			},
+			"_id":             bson.M{"$in": messageIDs},
+			"sender_id":       bson.M{"$ne": userID},
+			"read_by.user_id": bson.M{"$ne": userID},
+		},
+		bson.M{
+			"$push": bson.M{"read_by": entities.ReadInfo{UserID: userID, ReadAt: now}},
+			"$set":  bson.M{"updated_at": now},
 		},
 	)
 	return err
 }
 
-func (r *messageRepository) MarkMultipleAsRead(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID) error {
-	readInfo := entities.ReadInfo{
-		UserID: userID,
-		ReadAt: time.Now(),
+// AdvanceStatus moves the aggregate status forward (sent, delivered, read)
+// and never back. It reports whether the status changed.
+func (r *messageRepository) AdvanceStatus(ctx context.Context, messageID primitive.ObjectID, status entities.MessageStatus) (bool, error) {
+	var earlier []entities.MessageStatus
+	switch status {
+	case entities.MessageDelivered:
+		earlier = []entities.MessageStatus{entities.MessageSent}
+	case entities.MessageRead:
+		earlier = []entities.MessageStatus{entities.MessageSent, entities.MessageDelivered}
+	default:
+		return false, nil
 	}
 
-	_, err := r.collection.UpdateMany(
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": bson.M{"$in": messageIDs}},
+		bson.M{"_id": messageID, "status": bson.M{"$in": earlier}},
 		bson.M{
-			"$addToSet": bson.M{"read_by": readInfo},
 			"$set": bson.M{
-				"status":     entities.MessageRead,
+				"status":     status,
 				"updated_at": time.Now(),
 			},
 		},
 	)
-	return err
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
 }
 
-func (r *messageRepository) AddReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType) error {
-	// First remove any existing reaction from this user
-	r.RemoveReaction(ctx, messageID, userID)
-
-	// Add new reaction
+// AddReaction records one emoji from a user. A user can react with several
+// different emoji, each once, up to maxPerUser. Both limits are part of the
+// update filter so concurrent requests cannot get past them. It reports
+// false when the reaction was already there or the user is at the limit.
+func (r *messageRepository) AddReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType, maxPerUser int) (bool, error) {
 	reactionInfo := entities.MessageReaction{
 		UserID:   userID,
 		Reaction: reaction,
 		AddedAt:  time.Now(),
 	}
 
-	_, err := r.collection.UpdateOne(
+	userReactions := bson.M{"$size": bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$reactions", bson.A{}}},
+		"cond":  bson.M{"$eq": bson.A{"$$this.user_id", userID}},
+	}}}
+
+	result, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{"_id": messageID},
 		bson.M{
-			"$addToSet": bson.M{"reactions": reactionInfo},
-			"$set":      bson.M{"updated_at": time.Now()},
+			"_id": messageID,
+			"reactions": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+				"user_id":  userID,
+				"reaction": reaction,
+			}}},
+			"$expr": bson.M{"$lt": bson.A{userReactions, maxPerUser}},
+		},
+		bson.M{
+			"$push": bson.M{"reactions": reactionInfo},
+			"$set":  bson.M{"updated_at": time.Now()},
 		},
 	)
-	return err
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
 }
 
-func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID) error {
+// RemoveReaction removes one of the user's reactions, or all of them when
+// reaction is empty.
+func (r *messageRepository) RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType) error {
+	match := bson.M{"user_id": userID}
+	if reaction != "" {
+		match["reaction"] = reaction
+	}
+
 	_, err := r.collection.UpdateOne(
 		ctx,
 		bson.M{"_id": messageID},
 		bson.M{
-			"$pull": bson.M{"reactions": bson.M{"user_id": userID}},
+			"$pull": bson.M{"reactions": match},
 			"$set":  bson.M{"updated_at": time.Now()},
 		},
 	)
@@ -299,6 +747,8 @@ func (r *messageRepository) ForwardMessages(ctx context.Context, messageIDs []pr
 				SenderID:      senderID,
 				Type:          original.Type,
 				Content:       original.Content,
+				PlainText:     original.PlainText,
+				Entities:      original.Entities,
 				MediaURL:      original.MediaURL,
 				MediaType:     original.MediaType,
 				FileSize:      original.FileSize,
@@ -324,27 +774,63 @@ func (r *messageRepository) ForwardMessages(ctx context.Context, messageIDs []pr
 	return nil
 }
 
-func (r *messageRepository) SoftDeleteMessage(ctx context.Context, messageID, userID primitive.ObjectID, deleteForEveryone bool) error {
+func (r *messageRepository) SoftDeleteMessages(ctx context.Context, messageIDs []primitive.ObjectID, userID primitive.ObjectID, deleteForEveryone bool) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
 	now := time.Now()
 
 	if deleteForEveryone {
-		_, err := r.collection.UpdateOne(
+		// Leave a tombstone: wipe content, media, reactions, mentions and
+		// the reply reference. The thread path stays so replies below the
+		// message remain reachable and reply counts keep adding up, as the
+		// tombstone holds its place in the thread like it does in the chat.
+		// Permission checks happen in the usecase.
+		_, err := r.collection.UpdateMany(
 			ctx,
-			bson.M{"_id": messageID, "sender_id": userID}, // Only sender can delete for everyone
+			bson.M{"_id": bson.M{"$in": messageIDs}},
 			bson.M{
 				"$set": bson.M{
 					"is_deleted": true,
 					"deleted_at": now,
-					"content":    "This message was deleted",
+					"deleted_by": userID,
+					"content":    entities.DeletedMessagePlaceholder,
+					"reactions":  []entities.MessageReaction{},
 					"updated_at": now,
 				},
+				"$unset": bson.M{
+					"media_url":     "",
+					"media_type":    "",
+					"file_size":     "",
+					"file_name":     "",
+					"thumbnail_url": "",
+					"duration":      "",
+					"dimensions":    "",
+					"poll":          "",
+					"location":      "",
+					"contact":       "",
+					"link_preview":  "",
+					"plain_text":    "",
+					"entities":      "",
+					"reply_to_id":   "",
+					"mentions":      "",
+					"edited_at":     "",
+					"edit_count":    "",
+				},
 			},
 		)
+		if err != nil {
+			return err
+		}
+
+		// Earlier revisions would otherwise still expose the content
+		_, err = r.revisionCollection.DeleteMany(ctx, bson.M{"message_id": bson.M{"$in": messageIDs}})
 		return err
 	} else {
-		_, err := r.collection.UpdateOne(
+		_, err := r.collection.UpdateMany(
 			ctx,
-			bson.M{"_id": messageID},
+			bson.M{"_id": bson.M{"$in": messageIDs}},
 			bson.M{
 				"$addToSet": bson.M{"deleted_for": userID},
 				"$set":      bson.M{"updated_at": now},
@@ -354,28 +840,107 @@ func (r *messageRepository) SoftDeleteMessage(ctx context.Context, messageID, us
 	}
 }
 
-func (r *messageRepository) EditMessage(ctx context.Context, messageID primitive.ObjectID, newContent string) error {
+func (r *messageRepository) EditMessage(ctx context.Context, messageID primitive.ObjectID, newContent, plainText string, textEntities []entities.TextEntity, mentions []primitive.ObjectID) (*entities.Message, error) {
 	now := time.Now()
-	_, err := r.collection.UpdateOne(
+
+	update := bson.M{
+		"$set": bson.M{
+			"content":    newContent,
+			"mentions":   mentions,
+			"edited_at":  now,
+			"updated_at": now,
+		},
+		"$inc": bson.M{"edit_count": 1},
+	}
+
+	// Formatting removed by the edit must not linger
+	if len(textEntities) > 0 {
+		update["$set"].(bson.M)["plain_text"] = plainText
+		update["$set"].(bson.M)["entities"] = textEntities
+	} else {
+		update["$unset"] = bson.M{"plain_text": "", "entities": ""}
+	}
+
+	var current entities.Message
+	err := r.collection.FindOne(ctx, bson.M{"_id": messageID, "is_deleted": bson.M{"$ne": true}}).Decode(&current)
+	if err != nil {
+		return nil, err
+	}
+
+	// Archive the current version before replacing it, so a failure part
+	// way through never loses it. The revision is keyed on its number, so
+	// writing it again on a retry changes nothing.
+	revisionCreatedAt := current.CreatedAt
+	if current.EditedAt != nil {
+		revisionCreatedAt = *current.EditedAt
+	}
+
+	_, err = r.revisionCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": messageID},
+		bson.M{"message_id": messageID, "revision": current.EditCount},
 		bson.M{
-			"$set": bson.M{
-				"content":    newContent,
-				"edited_at":  now,
-				"updated_at": now,
+			"$setOnInsert": bson.M{
+				"_id":        primitive.NewObjectID(),
+				"content":    current.Content,
+				"created_at": revisionCreatedAt,
 			},
 		},
+		options.Update().SetUpsert(true),
 	)
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	// Only replace the version just archived; a concurrent edit wins and
+	// this one is rejected
+	editCount := bson.M{"$eq": current.EditCount}
+	if current.EditCount == 0 {
+		editCount = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	var edited entities.Message
+	err = r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": messageID, "is_deleted": bson.M{"$ne": true}, "edit_count": editCount},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&edited)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("message changed while being edited")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &edited, nil
+}
+
+func (r *messageRepository) GetMessageRevisions(ctx context.Context, messageID primitive.ObjectID) ([]entities.MessageRevision, error) {
+	opts := options.Find().SetSort(bson.D{{"revision", 1}})
+
+	cursor, err := r.revisionCollection.Find(ctx, bson.M{"message_id": messageID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []entities.MessageRevision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
 }
 
-func (r *messageRepository) SearchMessagesInChat(ctx context.Context, chatID primitive.ObjectID, query string, limit int) ([]*entities.Message, error) {
+func (r *messageRepository) SearchMessagesInChat(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, query string, limit int) ([]*entities.Message, error) {
 	filter := bson.M{
 		"chat_id":    chatID,
 		"$text":      bson.M{"$search": query},
 		"is_deleted": bson.M{"$ne": true},
 	}
+	if cleared != nil {
+		filter["$and"] = bson.A{visibleAfterClear(cleared)}
+	}
 
 	opts := options.Find().
 		SetSort(bson.D{{"score", bson.M{"$meta": "textScore"}}}).
@@ -399,12 +964,95 @@ func (r *messageRepository) SearchMessagesInChat(ctx context.Context, chatID pri
 	return messages, nil
 }
 
-func (r *messageRepository) GetMediaMessages(ctx context.Context, chatID primitive.ObjectID, mediaType entities.MessageType, limit, offset int) ([]*entities.Message, error) {
+// SearchMessages searches the given chats, skipping messages deleted for
+// everyone or for the user and those their clears hide. With a query, results are ranked by relevance
+// and then recency; otherwise newest first.
+func (r *messageRepository) SearchMessages(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, clears []*entities.ChatClear, req *entities.MessageSearchRequest) ([]*repositories.ScoredMessage, error) {
+	filter := bson.M{
+		"chat_id":     bson.M{"$in": chatIDs},
+		"type":        bson.M{"$ne": entities.SystemMessage},
+		"is_deleted":  bson.M{"$ne": true},
+		"deleted_for": bson.M{"$ne": userID},
+	}
+	if len(clears) > 0 {
+		filter["$nor"] = hiddenByClears(clears)
+	}
+
+	if req.Query != "" {
+		filter["$text"] = bson.M{"$search": req.Query}
+	}
+	if req.SenderID != nil {
+		filter["sender_id"] = *req.SenderID
+	}
+	if req.Type != "" {
+		filter["type"] = req.Type
+	}
+
+	createdAt := bson.M{}
+	if req.From != nil {
+		createdAt["$gte"] = *req.From
+	}
+	if req.To != nil {
+		createdAt["$lt"] = *req.To
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	if req.HasMedia {
+		filter["media_url"] = bson.M{"$exists": true, "$ne": ""}
+	}
+	if req.HasLink {
+		filter["$or"] = bson.A{
+			bson.M{"link_preview": bson.M{"$exists": true}},
+			bson.M{"content": bson.M{"$regex": `https?://`, "$options": "i"}},
+		}
+	}
+
+	opts := options.Find().
+		SetLimit(int64(req.Limit)).
+		SetSkip(int64(req.Offset))
+	if req.Query != "" {
+		opts.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
+		opts.SetSort(bson.D{
+			{"score", bson.M{"$meta": "textScore"}},
+			{"created_at", -1},
+		})
+	} else {
+		opts.SetSort(bson.D{{"created_at", -1}, {"_id", -1}})
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []*repositories.ScoredMessage
+	for cursor.Next(ctx) {
+		var hit struct {
+			entities.Message `bson:",inline"`
+			Score            float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&hit); err != nil {
+			continue
+		}
+		message := hit.Message
+		results = append(results, &repositories.ScoredMessage{Message: &message, Score: hit.Score})
+	}
+
+	return results, nil
+}
+
+func (r *messageRepository) GetMediaMessages(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, mediaType entities.MessageType, limit, offset int) ([]*entities.Message, error) {
 	filter := bson.M{
 		"chat_id":    chatID,
 		"type":       mediaType,
 		"is_deleted": bson.M{"$ne": true},
 	}
+	if cleared != nil {
+		filter["$and"] = bson.A{visibleAfterClear(cleared)}
+	}
 
 	opts := options.Find().
 		SetSort(bson.D{{"created_at", -1}}).
@@ -429,12 +1077,57 @@ func (r *messageRepository) GetMediaMessages(ctx context.Context, chatID primiti
 	return messages, nil
 }
 
-func (r *messageRepository) GetUnreadMessageCount(ctx context.Context, chatID, userID primitive.ObjectID) (int64, error) {
+// GetMentions returns messages in the given chats that mention the user,
+// newest first.
+func (r *messageRepository) GetMentions(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, clears []*entities.ChatClear, limit, offset int) ([]*entities.Message, error) {
+	filter := bson.M{
+		"mentions":    userID,
+		"chat_id":     bson.M{"$in": chatIDs},
+		"is_deleted":  bson.M{"$ne": true},
+		"deleted_for": bson.M{"$ne": userID},
+	}
+	if len(clears) > 0 {
+		filter["$nor"] = hiddenByClears(clears)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"created_at", -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) GetUnreadMessageCount(ctx context.Context, chatID, userID primitive.ObjectID, cleared *entities.ChatClear) (int64, error) {
 	filter := bson.M{
 		"chat_id":         chatID,
 		"sender_id":       bson.M{"$ne": userID}, // Don't count own messages
 		"read_by.user_id": bson.M{"$ne": userID}, // Not read by this user
 		"is_deleted":      bson.M{"$ne": true},
+		"deleted_for":     bson.M{"$ne": userID},
+	}
+
+	// Messages kept through a clear were starred, so they have been seen
+	if cleared != nil {
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{"$gt": cleared.ClearedAt}},
+			bson.M{"imported_at": bson.M{"$gt": cleared.ClearedAt}},
+		}
 	}
 
 	count, err := r.collection.CountDocuments(ctx, filter)
@@ -510,3 +1203,96 @@ func (r *messageRepository) GetMessageStats(ctx context.Context, chatID primitiv
 
 	return &results[0], nil
 }
+
+// ========== Disappearing Messages ==========
+
+func (r *messageRepository) GetExpiredMessages(ctx context.Context, before time.Time, limit int) ([]*entities.Message, error) {
+	filter := bson.M{
+		"expires_at": bson.M{"$lte": before},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"expires_at", 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) IsMediaReferenced(ctx context.Context, mediaURL string, excludeID primitive.ObjectID) (bool, error) {
+	// Forwarded copies share the original media file
+	count, err := r.collection.CountDocuments(
+		ctx,
+		bson.M{
+			"media_url": mediaURL,
+			"_id":       bson.M{"$ne": excludeID},
+		},
+		options.Count().SetLimit(1),
+	)
+	return count > 0, err
+}
+
+// ========== Offline Sync ==========
+
+// GetChangedMessages returns messages created or changed after each chat's
+// sync point and no later than until, oldest change first. Tombstones are
+// included so clients learn about deletions.
+func (r *messageRepository) GetChangedMessages(ctx context.Context, points []repositories.ChatSyncPoint, after *repositories.SyncCursor, until time.Time, limit int) ([]*entities.Message, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	chatFilters := make([]bson.M, len(points))
+	for i, point := range points {
+		chatFilters[i] = bson.M{
+			"chat_id":    point.ChatID,
+			"updated_at": bson.M{"$gt": point.Since, "$lte": until},
+		}
+	}
+
+	filter := bson.M{"$or": chatFilters}
+	if after != nil {
+		filter = bson.M{"$and": []bson.M{
+			filter,
+			{"$or": []bson.M{
+				{"updated_at": bson.M{"$gt": after.UpdatedAt}},
+				{"updated_at": after.UpdatedAt, "_id": bson.M{"$gt": after.ID}},
+			}},
+		}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{"updated_at", 1}, {"_id", 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*entities.Message
+	for cursor.Next(ctx) {
+		var message entities.Message
+		if err := cursor.Decode(&message); err != nil {
+			continue
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type pinnedMessageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPinnedMessageRepository(db *mongo.Database) repositories.PinnedMessageRepository {
+	repo := &pinnedMessageRepository{
+		collection: db.Collection("pinned_messages"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *pinnedMessageRepository) createIndexes() {
+	ctx := context.Background()
+
+	// A message can be pinned once per chat
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"message_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+
+	// Sparse index for the expiry reaper
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"expires_at", 1}},
+		Options: options.Index().SetSparse(true),
+	})
+
+	// Index for removing pins when a message is deleted
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"message_id", 1}},
+	})
+}
+
+// Pin creates the pin, or refreshes who pinned it and its expiry if the
+// message is already pinned. It reports whether the pin was created or
+// brought back from expiry, either of which adds an active pin.
+func (r *pinnedMessageRepository) Pin(ctx context.Context, pin *entities.PinnedMessage) (bool, error) {
+	pin.PinnedAt = time.Now()
+	newID := primitive.NewObjectID()
+
+	set := bson.M{
+		"pinned_by": pin.PinnedBy,
+		"pinned_at": pin.PinnedAt,
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": newID},
+	}
+	if pin.ExpiresAt != nil {
+		set["expires_at"] = *pin.ExpiresAt
+	} else {
+		update["$unset"] = bson.M{"expires_at": ""}
+	}
+
+	// The document from before the update tells a new pin from a refresh
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.Before)
+
+	var previous entities.PinnedMessage
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"chat_id": pin.ChatID, "message_id": pin.MessageID},
+		update,
+		opts,
+	).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		pin.ID = newID
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	pin.ID = previous.ID
+	expired := previous.ExpiresAt != nil && !previous.ExpiresAt.After(pin.PinnedAt)
+	return expired, nil
+}
+
+func (r *pinnedMessageRepository) Unpin(ctx context.Context, chatID, messageID primitive.ObjectID) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"chat_id": chatID, "message_id": messageID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// activePinFilter matches pins that have not expired yet. Expired pins are
+// hidden right away even if the reaper has not removed them.
+func activePinFilter(chatID primitive.ObjectID, now time.Time) bson.M {
+	return bson.M{
+		"chat_id": chatID,
+		"$or": []bson.M{
+			{"expires_at": bson.M{"$exists": false}},
+			{"expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+func (r *pinnedMessageRepository) GetChatPins(ctx context.Context, chatID primitive.ObjectID, now time.Time) ([]*entities.PinnedMessage, error) {
+	opts := options.Find().SetSort(bson.D{{"pinned_at", -1}})
+
+	cursor, err := r.collection.Find(ctx, activePinFilter(chatID, now), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var pins []*entities.PinnedMessage
+	for cursor.Next(ctx) {
+		var pin entities.PinnedMessage
+		if err := cursor.Decode(&pin); err != nil {
+			continue
+		}
+		pins = append(pins, &pin)
+	}
+
+	return pins, nil
+}
+
+func (r *pinnedMessageRepository) IsPinned(ctx context.Context, chatID, messageID primitive.ObjectID, now time.Time) (bool, error) {
+	filter := activePinFilter(chatID, now)
+	filter["message_id"] = messageID
+
+	count, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CountPinsUpTo counts the chat's active pins created no later than the
+// given one, which ranks a new pin against others made at the same time.
+func (r *pinnedMessageRepository) CountPinsUpTo(ctx context.Context, chatID, pinID primitive.ObjectID, now time.Time) (int64, error) {
+	filter := activePinFilter(chatID, now)
+	filter["_id"] = bson.M{"$lte": pinID}
+
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+func (r *pinnedMessageRepository) GetExpiredPins(ctx context.Context, before time.Time, limit int) ([]*entities.PinnedMessage, error) {
+	opts := options.Find().
+		SetSort(bson.D{{"expires_at", 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"expires_at": bson.M{"$lte": before}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var pins []*entities.PinnedMessage
+	for cursor.Next(ctx) {
+		var pin entities.PinnedMessage
+		if err := cursor.Decode(&pin); err != nil {
+			continue
+		}
+		pins = append(pins, &pin)
+	}
+
+	return pins, nil
+}
+
+func (r *pinnedMessageRepository) DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"message_id": bson.M{"$in": messageIDs}})
+	return err
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type pollRepository struct {
+	voteCollection    *mongo.Collection
+	messageCollection *mongo.Collection
+}
+
+func NewPollRepository(db *mongo.Database) repositories.PollRepository {
+	repo := &pollRepository{
+		voteCollection:    db.Collection("poll_votes"),
+		messageCollection: db.Collection("messages"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *pollRepository) createIndexes() {
+	ctx := context.Background()
+
+	// One ballot per user and poll
+	r.voteCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"message_id", 1},
+			{"user_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+}
+
+// SetVote casts or replaces the user's ballot.
+func (r *pollRepository) SetVote(ctx context.Context, messageID, userID primitive.ObjectID, optionIDs []string) error {
+	_, err := r.voteCollection.UpdateOne(
+		ctx,
+		bson.M{"message_id": messageID, "user_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"option_ids": optionIDs,
+				"updated_at": time.Now(),
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *pollRepository) RemoveVote(ctx context.Context, messageID, userID primitive.ObjectID) (bool, error) {
+	result, err := r.voteCollection.DeleteOne(ctx, bson.M{"message_id": messageID, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+func (r *pollRepository) GetVotes(ctx context.Context, messageID primitive.ObjectID) ([]*entities.PollVote, error) {
+	opts := options.Find().SetSort(bson.D{{"updated_at", 1}})
+
+	cursor, err := r.voteCollection.Find(ctx, bson.M{"message_id": messageID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var votes []*entities.PollVote
+	for cursor.Next(ctx) {
+		var vote entities.PollVote
+		if err := cursor.Decode(&vote); err != nil {
+			continue
+		}
+		votes = append(votes, &vote)
+	}
+
+	return votes, nil
+}
+
+func (r *pollRepository) ClosePoll(ctx context.Context, messageID primitive.ObjectID) (bool, error) {
+	now := time.Now()
+	result, err := r.messageCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":            messageID,
+			"type":           entities.PollMessage,
+			"poll.is_closed": bson.M{"$ne": true},
+		},
+		bson.M{
+			"$set": bson.M{
+				"poll.is_closed": true,
+				"poll.closed_at": now,
+				"updated_at":     now,
+			},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *pollRepository) DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.voteCollection.DeleteMany(ctx, bson.M{"message_id": bson.M{"$in": messageIDs}})
+	return err
+}
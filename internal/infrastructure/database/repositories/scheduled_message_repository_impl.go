@@ -0,0 +1,286 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type scheduledMessageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewScheduledMessageRepository(db *mongo.Database) repositories.ScheduledMessageRepository {
+	repo := &scheduledMessageRepository{
+		collection: db.Collection("scheduled_messages"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *scheduledMessageRepository) createIndexes() {
+	ctx := context.Background()
+
+	// Index for the dispatcher picking up due messages
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"status", 1},
+			{"send_at", 1},
+		},
+	})
+
+	// Index for listing a sender's pending messages in a chat
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"sender_id", 1},
+			{"status", 1},
+		},
+	})
+
+	// Makes scheduling with a client message ID idempotent per sender and chat
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"chat_id", 1},
+			{"sender_id", 1},
+			{"client_message_id", 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"client_message_id": bson.M{"$type": "string"}}),
+	})
+}
+
+func (r *scheduledMessageRepository) Create(ctx context.Context, scheduled *entities.ScheduledMessage) error {
+	scheduled.CreatedAt = time.Now()
+	scheduled.UpdatedAt = time.Now()
+	scheduled.ID = primitive.NewObjectID()
+	scheduled.Status = entities.ScheduledPending
+
+	_, err := r.collection.InsertOne(ctx, scheduled)
+	return err
+}
+
+// CreateIdempotent creates a scheduled message carrying a client message
+// ID. If the sender already scheduled one with that ID in the chat, nothing
+// is inserted and the earlier one is returned instead; otherwise it returns
+// nil.
+func (r *scheduledMessageRepository) CreateIdempotent(ctx context.Context, scheduled *entities.ScheduledMessage) (*entities.ScheduledMessage, error) {
+	err := r.Create(ctx, scheduled)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// Lost a race with a concurrent retry
+	existing, findErr := r.GetByClientMessageID(ctx, scheduled.ChatID, scheduled.SenderID, scheduled.ClientMessageID)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if existing == nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// GetByClientMessageID finds a scheduled message, in any state, by the ID
+// its sender's client gave it. It returns nil without an error when there
+// is none.
+func (r *scheduledMessageRepository) GetByClientMessageID(ctx context.Context, chatID, senderID primitive.ObjectID, clientMessageID string) (*entities.ScheduledMessage, error) {
+	var scheduled entities.ScheduledMessage
+	err := r.collection.FindOne(ctx, bson.M{
+		"chat_id":           chatID,
+		"sender_id":         senderID,
+		"client_message_id": clientMessageID,
+	}).Decode(&scheduled)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+func (r *scheduledMessageRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*entities.ScheduledMessage, error) {
+	var scheduled entities.ScheduledMessage
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&scheduled)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+func (r *scheduledMessageRepository) GetPendingForChat(ctx context.Context, chatID, senderID primitive.ObjectID) ([]*entities.ScheduledMessage, error) {
+	filter := bson.M{
+		"chat_id":   chatID,
+		"sender_id": senderID,
+		"status":    entities.ScheduledPending,
+	}
+
+	opts := options.Find().SetSort(bson.D{{"send_at", 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var scheduled []*entities.ScheduledMessage
+	for cursor.Next(ctx) {
+		var item entities.ScheduledMessage
+		if err := cursor.Decode(&item); err != nil {
+			continue
+		}
+		scheduled = append(scheduled, &item)
+	}
+
+	return scheduled, nil
+}
+
+func (r *scheduledMessageRepository) UpdatePending(ctx context.Context, id primitive.ObjectID, content *string, sendAt *time.Time) (bool, error) {
+	update := bson.M{"updated_at": time.Now()}
+	if content != nil {
+		update["content"] = *content
+	}
+	if sendAt != nil {
+		update["send_at"] = *sendAt
+	}
+
+	// Only pending messages can change; a claimed message is already on its way
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": entities.ScheduledPending},
+		bson.M{"$set": update},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *scheduledMessageRepository) Cancel(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": entities.ScheduledPending},
+		bson.M{
+			"$set": bson.M{
+				"status":     entities.ScheduledCancelled,
+				"updated_at": time.Now(),
+			},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ========== Dispatching ==========
+
+// ClaimDue atomically moves one due message from pending to sending so that
+// exactly one server instance delivers it. Returns nil when nothing is due.
+func (r *scheduledMessageRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*entities.ScheduledMessage, error) {
+	lockedUntil := now.Add(lease)
+
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{"send_at", 1}}).
+		SetReturnDocument(options.After)
+
+	var scheduled entities.ScheduledMessage
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"status":  entities.ScheduledPending,
+			"send_at": bson.M{"$lte": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":       entities.ScheduledSending,
+				"locked_until": lockedUntil,
+				"updated_at":   now,
+			},
+		},
+		opts,
+	).Decode(&scheduled)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduled, nil
+}
+
+// MarkSent and MarkFailed only apply to a claimed message, so a late
+// dispatcher cannot overwrite a result that has already been recorded.
+func (r *scheduledMessageRepository) MarkSent(ctx context.Context, id, messageID primitive.ObjectID) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": entities.ScheduledSending},
+		bson.M{
+			"$set": bson.M{
+				"status":     entities.ScheduledSent,
+				"message_id": messageID,
+				"sent_at":    now,
+				"updated_at": now,
+			},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	return err
+}
+
+func (r *scheduledMessageRepository) MarkFailed(ctx context.Context, id primitive.ObjectID, reason string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": entities.ScheduledSending},
+		bson.M{
+			"$set": bson.M{
+				"status":     entities.ScheduledFailed,
+				"error":      reason,
+				"updated_at": time.Now(),
+			},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	return err
+}
+
+// RequeueStale puts messages whose dispatcher died mid-send back in the
+// queue. Dispatching is idempotent, so one that was already delivered is
+// just marked sent on the next attempt.
+func (r *scheduledMessageRepository) RequeueStale(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{
+			"status":       entities.ScheduledSending,
+			"locked_until": bson.M{"$lt": now},
+		},
+		bson.M{
+			"$set": bson.M{
+				"status":     entities.ScheduledPending,
+				"updated_at": now,
+			},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type starredMessageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewStarredMessageRepository(db *mongo.Database) repositories.StarredMessageRepository {
+	repo := &starredMessageRepository{
+		collection: db.Collection("starred_messages"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *starredMessageRepository) createIndexes() {
+	ctx := context.Background()
+
+	// A user can star a message only once
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"user_id", 1},
+			{"message_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+
+	// Index for listing a user's stars, optionally within one chat
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"user_id", 1},
+			{"chat_id", 1},
+			{"created_at", -1},
+		},
+	})
+
+	// Index for removing stars when a message is deleted
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"message_id", 1}},
+	})
+}
+
+func (r *starredMessageRepository) Star(ctx context.Context, star *entities.StarredMessage) error {
+	now := time.Now()
+
+	// Starring twice is a no-op that keeps the original star time
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"user_id": star.UserID, "message_id": star.MessageID},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"_id":        primitive.NewObjectID(),
+				"chat_id":    star.ChatID,
+				"created_at": now,
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *starredMessageRepository) Unstar(ctx context.Context, userID, messageID primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "message_id": messageID})
+	return err
+}
+
+func (r *starredMessageRepository) UnstarMessages(ctx context.Context, userID primitive.ObjectID, messageIDs []primitive.ObjectID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID, "message_id": bson.M{"$in": messageIDs}})
+	return err
+}
+
+func (r *starredMessageRepository) UnstarChat(ctx context.Context, userID, chatID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID, "chat_id": chatID})
+	return err
+}
+
+// GetStarred lists the user's stars in the given chats, newest first.
+// Stars on messages deleted for everyone or for the user are skipped
+// before paging, so pages are never short.
+func (r *starredMessageRepository) GetStarred(ctx context.Context, userID primitive.ObjectID, chatIDs []primitive.ObjectID, limit, offset int) ([]*entities.StarredMessage, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{"user_id": userID, "chat_id": bson.M{"$in": chatIDs}}}},
+		{{"$sort", bson.D{{"created_at", -1}, {"_id", -1}}}},
+		{{"$lookup", bson.M{
+			"from":         "messages",
+			"localField":   "message_id",
+			"foreignField": "_id",
+			"as":           "message",
+		}}},
+		{{"$match", bson.M{
+			"message.0":           bson.M{"$exists": true},
+			"message.is_deleted":  bson.M{"$ne": true},
+			"message.deleted_for": bson.M{"$ne": userID},
+		}}},
+		{{"$project", bson.M{"message": 0}}},
+	}
+	if offset > 0 {
+		pipeline = append(pipeline, bson.D{{"$skip", int64(offset)}})
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{"$limit", int64(limit)}})
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stars []*entities.StarredMessage
+	for cursor.Next(ctx) {
+		var star entities.StarredMessage
+		if err := cursor.Decode(&star); err != nil {
+			continue
+		}
+		stars = append(stars, &star)
+	}
+
+	return stars, nil
+}
+
+func (r *starredMessageRepository) GetStarredIDs(ctx context.Context, userID primitive.ObjectID, messageIDs []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	starred := make(map[primitive.ObjectID]bool)
+	if len(messageIDs) == 0 {
+		return starred, nil
+	}
+
+	cursor, err := r.collection.Find(
+		ctx,
+		bson.M{"user_id": userID, "message_id": bson.M{"$in": messageIDs}},
+		options.Find().SetProjection(bson.M{"message_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var star entities.StarredMessage
+		if err := cursor.Decode(&star); err != nil {
+			continue
+		}
+		starred[star.MessageID] = true
+	}
+
+	return starred, nil
+}
+
+func (r *starredMessageRepository) DeleteForMessages(ctx context.Context, messageIDs []primitive.ObjectID) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"message_id": bson.M{"$in": messageIDs}})
+	return err
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type threadRepository struct {
+	collection *mongo.Collection
+}
+
+func NewThreadRepository(db *mongo.Database) repositories.ThreadRepository {
+	repo := &threadRepository{
+		collection: db.Collection("thread_subscriptions"),
+	}
+
+	repo.createIndexes()
+
+	return repo
+}
+
+func (r *threadRepository) createIndexes() {
+	ctx := context.Background()
+
+	// One subscription per user and thread
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"root_id", 1},
+			{"user_id", 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+
+	// Index for looking up a user's unread threads
+	r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{"user_id", 1},
+			{"root_id", 1},
+		},
+	})
+}
+
+func (r *threadRepository) Subscribe(ctx context.Context, rootID, chatID, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"root_id": rootID, "user_id": userID},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"_id":          primitive.NewObjectID(),
+				"chat_id":      chatID,
+				"unread_count": 0,
+				"updated_at":   time.Now(),
+			},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RecordReply bumps the unread count for every subscriber except the sender
+// and returns the updated subscriptions so they can be notified.
+func (r *threadRepository) RecordReply(ctx context.Context, rootID, senderID primitive.ObjectID, repliedAt time.Time) ([]*entities.ThreadSubscription, error) {
+	filter := bson.M{
+		"root_id": rootID,
+		"user_id": bson.M{"$ne": senderID},
+	}
+
+	_, err := r.collection.UpdateMany(
+		ctx,
+		filter,
+		bson.M{
+			"$inc": bson.M{"unread_count": 1},
+			"$set": bson.M{
+				"last_reply_at": repliedAt,
+				"updated_at":    time.Now(),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subscriptions []*entities.ThreadSubscription
+	for cursor.Next(ctx) {
+		var subscription entities.ThreadSubscription
+		if err := cursor.Decode(&subscription); err != nil {
+			continue
+		}
+		subscriptions = append(subscriptions, &subscription)
+	}
+
+	return subscriptions, nil
+}
+
+func (r *threadRepository) MarkRead(ctx context.Context, rootID, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"root_id": rootID, "user_id": userID},
+		bson.M{
+			"$set": bson.M{
+				"unread_count": 0,
+				"updated_at":   time.Now(),
+			},
+		},
+	)
+	return err
+}
+
+func (r *threadRepository) GetUnreadCounts(ctx context.Context, userID primitive.ObjectID, rootIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	counts := make(map[primitive.ObjectID]int)
+	if len(rootIDs) == 0 {
+		return counts, nil
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"user_id":      userID,
+		"root_id":      bson.M{"$in": rootIDs},
+		"unread_count": bson.M{"$gt": 0},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var subscription entities.ThreadSubscription
+		if err := cursor.Decode(&subscription); err != nil {
+			continue
+		}
+		counts[subscription.RootID] = subscription.UnreadCount
+	}
+
+	return counts, nil
+}
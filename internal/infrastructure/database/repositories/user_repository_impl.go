@@ -58,6 +58,36 @@ func (r *userRepository) GetByUsername(ctx context.Context, username string) (*e
 	return &user, nil
 }
 
+func (r *userRepository) GetByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entities.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*entities.User
+	for cursor.Next(ctx) {
+		var user entities.User
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// GetByPhone finds a user whose stored phone matches any of the given forms
+// of the same number (as typed, and normalised).
+func (r *userRepository) GetByPhone(ctx context.Context, phones []string) (*entities.User, error) {
+	var user entities.User
+	err := r.collection.FindOne(ctx, bson.M{"phone": bson.M{"$in": phones}}).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, user *entities.User) error {
 	user.UpdatedAt = time.Now()
 
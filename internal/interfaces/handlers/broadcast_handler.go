@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/interfaces/middleware"
+	"bro-chat/internal/usecases"
+	"bro-chat/pkg/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BroadcastHandler struct {
+	broadcastUsecase *usecases.BroadcastUsecase
+}
+
+func NewBroadcastHandler(broadcastUsecase *usecases.BroadcastUsecase) *BroadcastHandler {
+	return &BroadcastHandler{
+		broadcastUsecase: broadcastUsecase,
+	}
+}
+
+// ========== Lists ==========
+
+func (h *BroadcastHandler) CreateList(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req entities.CreateBroadcastListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	list, err := h.broadcastUsecase.CreateList(c.Request.Context(), userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to create broadcast list", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Broadcast list created successfully", list)
+}
+
+func (h *BroadcastHandler) GetLists(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	lists, err := h.broadcastUsecase.GetLists(c.Request.Context(), userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve broadcast lists", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Broadcast lists retrieved successfully", lists)
+}
+
+func (h *BroadcastHandler) GetList(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	listIDStr := c.Param("listId")
+	listID, err := primitive.ObjectIDFromHex(listIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid broadcast list ID", err)
+		return
+	}
+
+	list, err := h.broadcastUsecase.GetList(c.Request.Context(), listID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Failed to get broadcast list", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Broadcast list retrieved successfully", list)
+}
+
+func (h *BroadcastHandler) UpdateList(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	listIDStr := c.Param("listId")
+	listID, err := primitive.ObjectIDFromHex(listIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid broadcast list ID", err)
+		return
+	}
+
+	var req entities.UpdateBroadcastListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	list, err := h.broadcastUsecase.UpdateList(c.Request.Context(), listID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update broadcast list", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Broadcast list updated successfully", list)
+}
+
+func (h *BroadcastHandler) DeleteList(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	listIDStr := c.Param("listId")
+	listID, err := primitive.ObjectIDFromHex(listIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid broadcast list ID", err)
+		return
+	}
+
+	if err := h.broadcastUsecase.DeleteList(c.Request.Context(), listID, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete broadcast list", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Broadcast list deleted successfully", nil)
+}
+
+// ========== Sending ==========
+
+func (h *BroadcastHandler) Send(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	listIDStr := c.Param("listId")
+	listID, err := primitive.ObjectIDFromHex(listIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid broadcast list ID", err)
+		return
+	}
+
+	var req entities.BroadcastMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	send, err := h.broadcastUsecase.Send(c.Request.Context(), listID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to send broadcast", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Broadcast sent successfully", send)
+}
+
+func (h *BroadcastHandler) GetSends(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	listIDStr := c.Param("listId")
+	listID, err := primitive.ObjectIDFromHex(listIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid broadcast list ID", err)
+		return
+	}
+
+	// Parse pagination
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	sends, err := h.broadcastUsecase.GetSends(c.Request.Context(), listID, userID, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get broadcast history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Broadcast history retrieved successfully", sends)
+}
@@ -6,23 +6,33 @@ import (
 	"bro-chat/internal/usecases"
 	"bro-chat/pkg/services"
 	"bro-chat/pkg/utils"
+	"bro-chat/pkg/vcard"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type MessageHandler struct {
-	messageUsecase    *usecases.MessageUsecase
-	fileUploadService *services.FileUploadService
+	messageUsecase          *usecases.MessageUsecase
+	scheduledMessageUsecase *usecases.ScheduledMessageUsecase
+	chatTransferUsecase     *usecases.ChatTransferUsecase
+	fileUploadService       *services.FileUploadService
 }
 
-func NewMessageHandler(messageUsecase *usecases.MessageUsecase, fileUploadService *services.FileUploadService) *MessageHandler {
+func NewMessageHandler(messageUsecase *usecases.MessageUsecase, scheduledMessageUsecase *usecases.ScheduledMessageUsecase, chatTransferUsecase *usecases.ChatTransferUsecase, fileUploadService *services.FileUploadService) *MessageHandler {
 	return &MessageHandler{
-		messageUsecase:    messageUsecase,
-		fileUploadService: fileUploadService,
+		messageUsecase:          messageUsecase,
+		scheduledMessageUsecase: scheduledMessageUsecase,
+		chatTransferUsecase:     chatTransferUsecase,
+		fileUploadService:       fileUploadService,
 	}
 }
 
@@ -41,6 +51,18 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
+	// Messages with a send time are queued for the dispatcher instead
+	if req.SendAt != nil {
+		scheduled, err := h.scheduledMessageUsecase.ScheduleMessage(c.Request.Context(), userID, &req)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to schedule message", err)
+			return
+		}
+
+		utils.SuccessResponse(c, http.StatusCreated, "Message scheduled successfully", scheduled)
+		return
+	}
+
 	message, err := h.messageUsecase.SendMessage(c.Request.Context(), userID, &req)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to send message", err)
@@ -64,27 +86,73 @@ func (h *MessageHandler) GetChatMessages(c *gin.Context) {
 		return
 	}
 
-	// Parse pagination parameters
+	// Parse cursor pagination parameters
 	limitStr := c.DefaultQuery("limit", "50")
-	offsetStr := c.DefaultQuery("offset", "0")
-
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
 		limit = 50
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil {
-		offset = 0
+	req := &entities.MessagePageRequest{
+		Before: c.Query("before"),
+		After:  c.Query("after"),
+		Limit:  limit,
+	}
+
+	if aroundStr := c.Query("around"); aroundStr != "" {
+		aroundID, err := primitive.ObjectIDFromHex(aroundStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+			return
+		}
+		req.Around = &aroundID
 	}
 
-	messages, err := h.messageUsecase.GetChatMessages(c.Request.Context(), chatID, userID, limit, offset)
+	page, err := h.messageUsecase.GetChatMessages(c.Request.Context(), chatID, userID, req)
+	if errors.Is(err, usecases.ErrInvalidCursor) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusForbidden, "Failed to retrieve messages", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Messages retrieved successfully", messages)
+	utils.SuccessResponse(c, http.StatusOK, "Messages retrieved successfully", page)
+}
+
+func (h *MessageHandler) GetThread(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "50")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 50
+	}
+
+	req := &entities.ThreadPageRequest{
+		After: c.Query("after"),
+		Limit: limit,
+	}
+
+	page, err := h.messageUsecase.GetThread(c.Request.Context(), messageID, userID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to retrieve thread", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Thread retrieved successfully", page)
 }
 
 func (h *MessageHandler) MarkAsRead(c *gin.Context) {
@@ -165,6 +233,20 @@ func (h *MessageHandler) SendMediaMessage(c *gin.Context) {
 
 	// Get message content (caption)
 	content := c.PostForm("content")
+	clientMessageID := c.PostForm("clientMessageId")
+
+	// Answer a retry before uploading the file a second time
+	if clientMessageID != "" {
+		sent, err := h.messageUsecase.GetSentMessage(c.Request.Context(), userID, chatID, clientMessageID)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to send media message", err)
+			return
+		}
+		if sent != nil {
+			utils.SuccessResponse(c, http.StatusOK, "Media message already sent", sent)
+			return
+		}
+	}
 
 	// Get file
 	file, fileHeader, err := c.Request.FormFile("file")
@@ -205,6 +287,8 @@ func (h *MessageHandler) SendMediaMessage(c *gin.Context) {
 		FileSize:   uploadResult.FileSize,
 		Duration:   uploadResult.Duration,
 		Dimensions: uploadResult.Dimensions,
+
+		ClientMessageID: clientMessageID,
 	}
 
 	// Send message
@@ -255,7 +339,10 @@ func (h *MessageHandler) RemoveReaction(c *gin.Context) {
 		return
 	}
 
-	err = h.messageUsecase.RemoveReaction(c.Request.Context(), messageID, userID)
+	// Without a reaction every one of the user's reactions is removed
+	reaction := entities.ReactionType(c.Query("reaction"))
+
+	err = h.messageUsecase.RemoveReaction(c.Request.Context(), messageID, userID, reaction)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to remove reaction", err)
 		return
@@ -264,53 +351,138 @@ func (h *MessageHandler) RemoveReaction(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Reaction removed successfully", nil)
 }
 
-// ========== Message Management ==========
+func (h *MessageHandler) GetMessageReactions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
 
-func (h *MessageHandler) ForwardMessages(c *gin.Context) {
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	// Optional filter to page through one emoji
+	reaction := entities.ReactionType(c.Query("reaction"))
+
+	// Parse pagination
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	reactions, err := h.messageUsecase.GetMessageReactions(c.Request.Context(), messageID, userID, reaction, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get reactions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Reactions retrieved successfully", reactions)
+}
+
+// ========== Starred Messages ==========
+
+func (h *MessageHandler) StarMessage(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 		return
 	}
 
-	var req entities.ForwardMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
 		return
 	}
 
-	err := h.messageUsecase.ForwardMessages(c.Request.Context(), userID, &req)
+	err = h.messageUsecase.StarMessage(c.Request.Context(), messageID, userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to forward messages", err)
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to star message", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Messages forwarded successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Message starred successfully", nil)
 }
 
-func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+func (h *MessageHandler) UnstarMessage(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 		return
 	}
 
-	var req entities.DeleteMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
 		return
 	}
 
-	err := h.messageUsecase.DeleteMessage(c.Request.Context(), userID, &req)
+	err = h.messageUsecase.UnstarMessage(c.Request.Context(), messageID, userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete message", err)
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to unstar message", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Message deleted successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Message unstarred successfully", nil)
 }
 
-func (h *MessageHandler) EditMessage(c *gin.Context) {
+func (h *MessageHandler) GetStarredMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	// Optional chat filter
+	var chatID *primitive.ObjectID
+	if chatIDStr := c.Query("chatId"); chatIDStr != "" {
+		id, err := primitive.ObjectIDFromHex(chatIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+			return
+		}
+		chatID = &id
+	}
+
+	// Parse pagination
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	messages, err := h.messageUsecase.GetStarredMessages(c.Request.Context(), userID, chatID, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get starred messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Starred messages retrieved successfully", messages)
+}
+
+// ========== Polls ==========
+
+func (h *MessageHandler) GetPollResults(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
@@ -324,58 +496,885 @@ func (h *MessageHandler) EditMessage(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Content string `json:"content" binding:"required"`
+	results, err := h.messageUsecase.GetPollResults(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get poll results", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Poll results retrieved successfully", results)
+}
+
+func (h *MessageHandler) VotePoll(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
 	}
+
+	var req entities.PollVoteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
 
-	err = h.messageUsecase.EditMessage(c.Request.Context(), messageID, userID, req.Content)
+	results, err := h.messageUsecase.VotePoll(c.Request.Context(), messageID, userID, &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to edit message", err)
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to vote", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Message edited successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "Vote recorded successfully", results)
 }
 
-// ========== Search and Media ==========
+func (h *MessageHandler) RetractPollVote(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
 
-func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	results, err := h.messageUsecase.RetractPollVote(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to retract vote", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Vote retracted successfully", results)
+}
+
+func (h *MessageHandler) ClosePoll(c *gin.Context) {
 	userID, exists := middleware.GetUserIDFromContext(c)
 	if !exists {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
 		return
 	}
 
-	chatIDStr := c.Param("chatId")
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	results, err := h.messageUsecase.ClosePoll(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to close poll", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Poll closed successfully", results)
+}
+
+// ========== Live Location ==========
+
+func (h *MessageHandler) StopLiveLocation(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	err = h.messageUsecase.StopLiveLocation(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to stop live location", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Live location stopped successfully", nil)
+}
+
+// ========== Contact Cards ==========
+
+func (h *MessageHandler) ImportVCard(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	// Parse multipart form
+	if err := c.Request.ParseMultipartForm(entities.MaxVCardSize); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to parse form", err)
+		return
+	}
+
+	chatIDStr := c.PostForm("chatId")
 	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
 		return
 	}
 
-	query := c.Query("q")
-	if query == "" {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Search query is required", nil)
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided", err)
 		return
 	}
+	defer file.Close()
 
-	limitStr := c.DefaultQuery("limit", "20")
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
-		limit = 20
+	if fileHeader.Size > entities.MaxVCardSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "vCard file is too large", nil)
+		return
 	}
 
-	messages, err := h.messageUsecase.SearchMessages(c.Request.Context(), chatID, userID, query, limit)
+	messages, err := h.chatTransferUsecase.ImportVCard(c.Request.Context(), userID, chatID, file)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Search failed", err)
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to import contacts", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Search completed successfully", messages)
+	utils.SuccessResponse(c, http.StatusCreated, "Contacts imported successfully", messages)
+}
+
+func (h *MessageHandler) ExportVCard(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	version := c.DefaultQuery("version", vcard.Version3)
+
+	var buf bytes.Buffer
+	fileName, err := h.chatTransferUsecase.ExportVCard(c.Request.Context(), messageID, userID, version, &buf)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to export contact", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, "text/vcard; charset=utf-8", buf.Bytes())
+}
+
+// ========== Mentions ==========
+
+func (h *MessageHandler) GetMentions(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	// Parse pagination
+	limitStr := c.DefaultQuery("limit", "50")
+	offsetStr := c.DefaultQuery("offset", "0")
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		offset = 0
+	}
+
+	messages, err := h.messageUsecase.GetMentions(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get mentions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Mentions retrieved successfully", messages)
+}
+
+// ========== Pinned Messages ==========
+
+func (h *MessageHandler) PinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	// The body is optional; without it the pin never expires
+	var req entities.PinMessageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+
+	pin, err := h.messageUsecase.PinMessage(c.Request.Context(), messageID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to pin message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message pinned successfully", pin)
+}
+
+func (h *MessageHandler) UnpinMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	err = h.messageUsecase.UnpinMessage(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to unpin message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message unpinned successfully", nil)
+}
+
+func (h *MessageHandler) GetPinnedMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	pins, err := h.messageUsecase.GetPinnedMessages(c.Request.Context(), chatID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get pinned messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Pinned messages retrieved successfully", pins)
+}
+
+// ========== Message Info ==========
+
+func (h *MessageHandler) GetMessageInfo(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	info, err := h.messageUsecase.GetMessageInfo(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get message info", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message info retrieved successfully", info)
+}
+
+// ========== Drafts ==========
+
+func (h *MessageHandler) GetDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	draft, err := h.messageUsecase.GetDraft(c.Request.Context(), chatID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Failed to get draft", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Draft retrieved successfully", draft)
+}
+
+func (h *MessageHandler) SaveDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	var req entities.SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	draft, err := h.messageUsecase.SaveDraft(c.Request.Context(), chatID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to save draft", err)
+		return
+	}
+
+	if draft == nil {
+		utils.SuccessResponse(c, http.StatusOK, "Draft cleared successfully", nil)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Draft saved successfully", draft)
+}
+
+func (h *MessageHandler) DeleteDraft(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	if err := h.messageUsecase.DeleteDraft(c.Request.Context(), chatID, userID); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete draft", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Draft deleted successfully", nil)
+}
+
+// ========== Chat Export ==========
+
+func (h *MessageHandler) ExportChat(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	req := entities.ChatExportRequest{
+		Format:   entities.ExportFormat(c.DefaultQuery("format", string(entities.ExportText))),
+		Timezone: c.Query("tz"),
+	}
+
+	export, err := h.chatTransferUsecase.ExportChat(c.Request.Context(), chatID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to export chat", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.FileName))
+	c.Header("Content-Type", export.ContentType)
+	c.Status(http.StatusOK)
+
+	// The response is streamed, so a failure part way can only be logged
+	if err := export.Write(c.Writer); err != nil {
+		fmt.Printf("Failed to export chat %s: %v", chatID.Hex(), err)
+	}
+}
+
+// ========== Chat Import ==========
+
+// ImportChat takes a WhatsApp export as "file", with optional chatId, name,
+// tz, dateOrder, dryRun and senders (a JSON object of transcript names to
+// user IDs) form fields.
+func (h *MessageHandler) ImportChat(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	// Parse multipart form; large uploads spill to disk
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to parse form", err)
+		return
+	}
+
+	req := entities.ChatImportRequest{
+		Name:      c.PostForm("name"),
+		Timezone:  c.PostForm("tz"),
+		DateOrder: c.PostForm("dateOrder"),
+		DryRun:    c.PostForm("dryRun") == "true",
+	}
+
+	if chatIDStr := c.PostForm("chatId"); chatIDStr != "" {
+		chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+			return
+		}
+		req.ChatID = &chatID
+	}
+
+	if senders := c.PostForm("senders"); senders != "" {
+		if err := json.Unmarshal([]byte(senders), &req.Senders); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid senders mapping", err)
+			return
+		}
+	}
+
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "No file provided", err)
+		return
+	}
+	defer file.Close()
+
+	if fileHeader.Size > entities.MaxChatImportSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Import file is too large", nil)
+		return
+	}
+
+	report, err := h.chatTransferUsecase.ImportChat(c.Request.Context(), userID, &req, file, fileHeader.Size, fileHeader.Filename)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to import chat", err)
+		return
+	}
+
+	if req.DryRun {
+		utils.SuccessResponse(c, http.StatusOK, "Chat import checked successfully", report)
+		return
+	}
+	utils.SuccessResponse(c, http.StatusCreated, "Chat imported successfully", report)
+}
+
+// ========== Message Management ==========
+
+func (h *MessageHandler) ForwardMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req entities.ForwardMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	err := h.messageUsecase.ForwardMessages(c.Request.Context(), userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to forward messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Messages forwarded successfully", nil)
+}
+
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req entities.DeleteMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	err := h.messageUsecase.DeleteMessage(c.Request.Context(), userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message deleted successfully", nil)
+}
+
+func (h *MessageHandler) DeleteMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	var req entities.DeleteMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	deleted, err := h.messageUsecase.DeleteMessages(c.Request.Context(), userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to delete messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Messages deleted successfully", gin.H{"deleted": deleted})
+}
+
+func (h *MessageHandler) ClearChat(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	// The body is optional; without it starred messages are cleared too
+	var req entities.ClearChatRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+
+	cleared, err := h.messageUsecase.ClearChat(c.Request.Context(), chatID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to clear chat", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Chat cleared successfully", cleared)
+}
+
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	err = h.messageUsecase.EditMessage(c.Request.Context(), messageID, userID, req.Content)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to edit message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Message edited successfully", nil)
+}
+
+func (h *MessageHandler) GetEditHistory(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	messageIDStr := c.Param("messageId")
+	messageID, err := primitive.ObjectIDFromHex(messageIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid message ID", err)
+		return
+	}
+
+	history, err := h.messageUsecase.GetEditHistory(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get edit history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Edit history retrieved successfully", history)
+}
+
+// ========== Scheduled Messages ==========
+
+func (h *MessageHandler) GetScheduledMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	scheduled, err := h.scheduledMessageUsecase.GetScheduledMessages(c.Request.Context(), chatID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to get scheduled messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled messages retrieved successfully", scheduled)
+}
+
+func (h *MessageHandler) UpdateScheduledMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	scheduledIDStr := c.Param("scheduledId")
+	scheduledID, err := primitive.ObjectIDFromHex(scheduledIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid scheduled message ID", err)
+		return
+	}
+
+	var req entities.UpdateScheduledMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	scheduled, err := h.scheduledMessageUsecase.UpdateScheduledMessage(c.Request.Context(), scheduledID, userID, &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update scheduled message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled message updated successfully", scheduled)
+}
+
+func (h *MessageHandler) CancelScheduledMessage(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	scheduledIDStr := c.Param("scheduledId")
+	scheduledID, err := primitive.ObjectIDFromHex(scheduledIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid scheduled message ID", err)
+		return
+	}
+
+	err = h.scheduledMessageUsecase.CancelScheduledMessage(c.Request.Context(), scheduledID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to cancel scheduled message", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled message cancelled successfully", nil)
+}
+
+// ========== Disappearing Messages ==========
+
+func (h *MessageHandler) SetDisappearingTimer(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	var req entities.SetDisappearingTimerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	err = h.messageUsecase.SetDisappearingTimer(c.Request.Context(), chatID, userID, req.DisappearingTime)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to update disappearing messages", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Disappearing messages updated successfully", req)
+}
+
+// ========== Search and Media ==========
+
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	chatIDStr := c.Param("chatId")
+	chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+		return
+	}
+
+	query := c.Query("q")
+	if query == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Search query is required", nil)
+		return
+	}
+
+	limitStr := c.DefaultQuery("limit", "20")
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		limit = 20
+	}
+
+	messages, err := h.messageUsecase.SearchMessages(c.Request.Context(), chatID, userID, query, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Search failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Search completed successfully", messages)
+}
+
+func (h *MessageHandler) SearchAllMessages(c *gin.Context) {
+	userID, exists := middleware.GetUserIDFromContext(c)
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated", nil)
+		return
+	}
+
+	req := &entities.MessageSearchRequest{
+		Query:    c.Query("q"),
+		Type:     entities.MessageType(c.Query("type")),
+		HasMedia: c.Query("hasMedia") == "true",
+		HasLink:  c.Query("hasLink") == "true",
+	}
+
+	if chatIDStr := c.Query("chatId"); chatIDStr != "" {
+		chatID, err := primitive.ObjectIDFromHex(chatIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid chat ID", err)
+			return
+		}
+		req.ChatID = &chatID
+	}
+
+	if senderIDStr := c.Query("senderId"); senderIDStr != "" {
+		senderID, err := primitive.ObjectIDFromHex(senderIDStr)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid sender ID", err)
+			return
+		}
+		req.SenderID = &senderID
+	}
+
+	var err error
+	if req.From, err = parseSearchDate(c.Query("from"), false); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from date", err)
+		return
+	}
+	if req.To, err = parseSearchDate(c.Query("to"), true); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to date", err)
+		return
+	}
+
+	// Parse pagination
+	req.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		req.Limit = 20
+	}
+	req.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		req.Offset = 0
+	}
+
+	page, err := h.messageUsecase.SearchAllMessages(c.Request.Context(), userID, req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Search failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Search completed successfully", page)
+}
+
+// parseSearchDate accepts RFC 3339 timestamps or plain dates. A plain "to"
+// date covers that whole day.
+func parseSearchDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
 }
 
 func (h *MessageHandler) GetMediaMessages(c *gin.Context) {
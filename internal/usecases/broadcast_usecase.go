@@ -0,0 +1,340 @@
+package usecases
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BroadcastUsecase struct {
+	broadcastRepo  repositories.BroadcastRepository
+	chatRepo       repositories.ChatRepository
+	userRepo       repositories.UserRepository
+	messageRepo    repositories.MessageRepository
+	messageUsecase *MessageUsecase
+}
+
+func NewBroadcastUsecase(
+	broadcastRepo repositories.BroadcastRepository,
+	chatRepo repositories.ChatRepository,
+	userRepo repositories.UserRepository,
+	messageRepo repositories.MessageRepository,
+	messageUsecase *MessageUsecase,
+) *BroadcastUsecase {
+	return &BroadcastUsecase{
+		broadcastRepo:  broadcastRepo,
+		chatRepo:       chatRepo,
+		userRepo:       userRepo,
+		messageRepo:    messageRepo,
+		messageUsecase: messageUsecase,
+	}
+}
+
+// ========== Lists ==========
+
+func (b *BroadcastUsecase) CreateList(ctx context.Context, ownerID primitive.ObjectID, req *entities.CreateBroadcastListRequest) (*entities.BroadcastList, error) {
+	name, err := validateBroadcastName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, err := b.validateRecipients(ctx, ownerID, req.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &entities.BroadcastList{
+		OwnerID:    ownerID,
+		Name:       name,
+		Recipients: recipients,
+	}
+
+	if err := b.broadcastRepo.CreateList(ctx, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (b *BroadcastUsecase) GetLists(ctx context.Context, ownerID primitive.ObjectID) ([]*entities.BroadcastList, error) {
+	lists, err := b.broadcastRepo.GetOwnerLists(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if lists == nil {
+		lists = []*entities.BroadcastList{}
+	}
+
+	return lists, nil
+}
+
+func (b *BroadcastUsecase) GetList(ctx context.Context, listID, ownerID primitive.ObjectID) (*entities.BroadcastList, error) {
+	list, err := b.broadcastRepo.GetList(ctx, listID, ownerID)
+	if err != nil {
+		return nil, errors.New("broadcast list not found")
+	}
+	return list, nil
+}
+
+func (b *BroadcastUsecase) UpdateList(ctx context.Context, listID, ownerID primitive.ObjectID, req *entities.UpdateBroadcastListRequest) (*entities.BroadcastList, error) {
+	if req.Name == nil && req.Recipients == nil {
+		return nil, errors.New("nothing to update")
+	}
+
+	list, err := b.GetList(ctx, listID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		if list.Name, err = validateBroadcastName(*req.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Recipients != nil {
+		if list.Recipients, err = b.validateRecipients(ctx, ownerID, *req.Recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := b.broadcastRepo.UpdateList(ctx, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (b *BroadcastUsecase) DeleteList(ctx context.Context, listID, ownerID primitive.ObjectID) error {
+	deleted, err := b.broadcastRepo.DeleteList(ctx, listID, ownerID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("broadcast list not found")
+	}
+	return nil
+}
+
+// ========== Sending ==========
+
+// Send delivers the message to every recipient as an ordinary direct
+// message from the owner, creating the direct chat when there is none yet.
+// A failure for one recipient is recorded and does not stop the others.
+func (b *BroadcastUsecase) Send(ctx context.Context, listID, ownerID primitive.ObjectID, req *entities.BroadcastMessageRequest) (*entities.BroadcastSend, error) {
+	list, err := b.GetList(ctx, listID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Type == entities.PollMessage {
+		return nil, errors.New("polls cannot be sent to a broadcast list")
+	}
+
+	template := entities.SendMessageRequest{
+		Type:       req.Type,
+		Content:    req.Content,
+		MediaURL:   req.MediaURL,
+		MediaType:  req.MediaType,
+		FileName:   req.FileName,
+		FileSize:   req.FileSize,
+		Duration:   req.Duration,
+		Dimensions: req.Dimensions,
+		Location:   req.Location,
+		Contact:    req.Contact,
+	}
+
+	// Validate once rather than failing the same way for every recipient
+	if err := b.messageUsecase.validateMessageContent(&template); err != nil {
+		return nil, err
+	}
+
+	send := &entities.BroadcastSend{
+		ListID:     list.ID,
+		OwnerID:    ownerID,
+		Type:       req.Type,
+		Content:    req.Content,
+		Deliveries: make([]entities.BroadcastDelivery, 0, len(list.Recipients)),
+	}
+
+	for _, recipientID := range list.Recipients {
+		delivery := entities.BroadcastDelivery{RecipientID: recipientID}
+
+		chat, err := b.getOrCreateDirectChat(ctx, ownerID, recipientID)
+		if err != nil {
+			delivery.Error = err.Error()
+			send.Deliveries = append(send.Deliveries, delivery)
+			continue
+		}
+		delivery.ChatID = chat.ID
+
+		// Each recipient gets their own copy; the owner's draft in that
+		// chat is left alone
+		messageReq := template
+		messageReq.ChatID = chat.ID
+		message, err := b.messageUsecase.sendMessage(ctx, ownerID, &messageReq, false)
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.MessageID = &message.ID
+		}
+
+		send.Deliveries = append(send.Deliveries, delivery)
+	}
+
+	if err := b.broadcastRepo.CreateSend(ctx, send); err != nil {
+		return nil, err
+	}
+
+	send.Stats = b.getSendStats(ctx, send)
+	return send, nil
+}
+
+// GetSends returns a list's send history, newest first, with receipts
+// summed across recipients.
+func (b *BroadcastUsecase) GetSends(ctx context.Context, listID, ownerID primitive.ObjectID, limit, offset int) ([]*entities.BroadcastSend, error) {
+	if _, err := b.GetList(ctx, listID, ownerID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	sends, err := b.broadcastRepo.GetSends(ctx, listID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if sends == nil {
+		sends = []*entities.BroadcastSend{}
+	}
+
+	for _, send := range sends {
+		send.Stats = b.getSendStats(ctx, send)
+	}
+
+	return sends, nil
+}
+
+func (b *BroadcastUsecase) getSendStats(ctx context.Context, send *entities.BroadcastSend) *entities.BroadcastSendStats {
+	stats := &entities.BroadcastSendStats{Recipients: len(send.Deliveries)}
+
+	recipientOf := make(map[primitive.ObjectID]primitive.ObjectID, len(send.Deliveries))
+	var messageIDs, recipientIDs []primitive.ObjectID
+	for _, delivery := range send.Deliveries {
+		if delivery.MessageID == nil {
+			stats.Failed++
+			continue
+		}
+		stats.Sent++
+		recipientOf[*delivery.MessageID] = delivery.RecipientID
+		messageIDs = append(messageIDs, *delivery.MessageID)
+		recipientIDs = append(recipientIDs, delivery.RecipientID)
+	}
+
+	if len(messageIDs) == 0 {
+		return stats
+	}
+
+	// Messages deleted since count as sent only
+	messages, err := b.messageRepo.GetByIDs(ctx, messageIDs)
+	if err != nil {
+		fmt.Printf("Failed to load broadcast messages: %v", err)
+		return stats
+	}
+
+	hidden := make(map[primitive.ObjectID]bool)
+	b.messageUsecase.loadHiddenReadReceipts(ctx, recipientIDs, hidden)
+
+	for _, message := range messages {
+		recipientID := recipientOf[message.ID]
+		switch {
+		case b.messageUsecase.isReadByUser(message, recipientID):
+			stats.Delivered++
+			if !hidden[recipientID] {
+				stats.Read++
+			}
+		case b.messageUsecase.isDeliveredToUser(message, recipientID):
+			stats.Delivered++
+		}
+	}
+
+	return stats
+}
+
+// getOrCreateDirectChat finds the owner's direct chat with the recipient,
+// starting one if they have never talked.
+func (b *BroadcastUsecase) getOrCreateDirectChat(ctx context.Context, ownerID, recipientID primitive.ObjectID) (*entities.Chat, error) {
+	chat, err := b.chatRepo.GetDirectChat(ctx, ownerID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if chat != nil {
+		return chat, nil
+	}
+
+	chat = &entities.Chat{
+		Type:         entities.DirectChat,
+		Participants: []primitive.ObjectID{ownerID, recipientID},
+		CreatedBy:    ownerID,
+	}
+
+	if err := b.chatRepo.Create(ctx, chat); err != nil {
+		return nil, err
+	}
+
+	return chat, nil
+}
+
+// validateRecipients drops duplicates and the owner, and checks every
+// recipient exists.
+func (b *BroadcastUsecase) validateRecipients(ctx context.Context, ownerID primitive.ObjectID, recipientIDs []primitive.ObjectID) ([]primitive.ObjectID, error) {
+	seen := make(map[primitive.ObjectID]bool, len(recipientIDs))
+	recipients := make([]primitive.ObjectID, 0, len(recipientIDs))
+	for _, recipientID := range recipientIDs {
+		if recipientID == ownerID || seen[recipientID] {
+			continue
+		}
+		seen[recipientID] = true
+		recipients = append(recipients, recipientID)
+	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New("a broadcast list needs at least one recipient")
+	}
+	if len(recipients) > entities.MaxBroadcastRecipients {
+		return nil, fmt.Errorf("a broadcast list can have at most %d recipients", entities.MaxBroadcastRecipients)
+	}
+
+	users, err := b.userRepo.GetByIDs(ctx, recipients)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) != len(recipients) {
+		return nil, errors.New("one or more recipients not found")
+	}
+
+	return recipients, nil
+}
+
+func validateBroadcastName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("broadcast list name cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > entities.MaxBroadcastNameLength {
+		return "", fmt.Errorf("broadcast list name cannot be longer than %d characters", entities.MaxBroadcastNameLength)
+	}
+	return name, nil
+}
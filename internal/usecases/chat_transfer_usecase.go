@@ -0,0 +1,980 @@
+package usecases
+
+import (
+	"archive/zip"
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"bro-chat/pkg/services"
+	"bro-chat/pkg/transcript"
+	"bro-chat/pkg/vcard"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChatTransferUsecase moves chat history in and out: transcript exports
+// and imports, and contact cards as vCard files.
+type ChatTransferUsecase struct {
+	messageRepo       repositories.MessageRepository
+	chatRepo          repositories.ChatRepository
+	userRepo          repositories.UserRepository
+	clearRepo         repositories.ChatClearRepository
+	fileUploadService *services.FileUploadService
+	messageUsecase    *MessageUsecase
+}
+
+func NewChatTransferUsecase(
+	messageRepo repositories.MessageRepository,
+	chatRepo repositories.ChatRepository,
+	userRepo repositories.UserRepository,
+	clearRepo repositories.ChatClearRepository,
+	fileUploadService *services.FileUploadService,
+	messageUsecase *MessageUsecase,
+) *ChatTransferUsecase {
+	return &ChatTransferUsecase{
+		messageRepo:       messageRepo,
+		chatRepo:          chatRepo,
+		userRepo:          userRepo,
+		clearRepo:         clearRepo,
+		fileUploadService: fileUploadService,
+		messageUsecase:    messageUsecase,
+	}
+}
+
+// ========== Contact Cards ==========
+
+// ImportVCard turns every card in a .vcf file into a contact message.
+func (t *ChatTransferUsecase) ImportVCard(ctx context.Context, userID, chatID primitive.ObjectID, r io.Reader) ([]*entities.Message, error) {
+	cards, err := vcard.Parse(io.LimitReader(r, entities.MaxVCardSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cards) > entities.MaxContactsPerImport {
+		return nil, fmt.Errorf("a vCard file can contain at most %d contacts", entities.MaxContactsPerImport)
+	}
+
+	// Validate everything first so a bad card doesn't leave a partial import
+	requests := make([]*entities.SendMessageRequest, 0, len(cards))
+	for _, card := range cards {
+		req := &entities.SendMessageRequest{
+			ChatID:  chatID,
+			Type:    entities.ContactMessage,
+			Contact: contactFromVCard(card),
+		}
+		if err := t.messageUsecase.validateMessageContent(req); err != nil {
+			return nil, fmt.Errorf("contact %q: %w", req.Contact.Name, err)
+		}
+		requests = append(requests, req)
+	}
+
+	var messages []*entities.Message
+	for _, req := range requests {
+		message, err := t.messageUsecase.SendMessage(ctx, userID, req)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// ExportVCard writes a contact message to w as a vCard in the requested
+// version (vcard.Version3 or vcard.Version4) and returns a file name for it.
+func (t *ChatTransferUsecase) ExportVCard(ctx context.Context, messageID, userID primitive.ObjectID, version string, w io.Writer) (string, error) {
+	message, err := t.messageUsecase.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if message.Type != entities.ContactMessage || message.Contact == nil {
+		return "", errors.New("message is not a contact card")
+	}
+
+	if err := vcard.Encode(w, []vcard.Card{vcardFromContact(message.Contact)}, version); err != nil {
+		return "", err
+	}
+
+	return contactFileName(message.Contact.Name), nil
+}
+
+// contactFileName turns a contact name into a safe .vcf file name.
+func contactFileName(name string) string {
+	return safeFileName(name, "contact") + ".vcf"
+}
+
+// safeFileName drops the characters file systems reject, falling back when
+// nothing is left.
+func safeFileName(name, fallback string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == ' ' || r == '-' || r == '_' || r == '.':
+			b.WriteRune(r)
+		case r < 0x20 || strings.ContainsRune(`"\/:*?<>|`, r):
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	fileName := strings.TrimSpace(b.String())
+	if fileName == "" {
+		fileName = fallback
+	}
+	return fileName
+}
+
+func contactFromVCard(card vcard.Card) *entities.ContactCard {
+	contact := &entities.ContactCard{
+		Name:         card.FormattedName,
+		FirstName:    card.GivenName,
+		LastName:     card.FamilyName,
+		Organization: card.Organization,
+	}
+	for _, phone := range card.Phones {
+		contact.Phones = append(contact.Phones, entities.ContactField{Value: phone.Value, Type: phone.Type})
+	}
+	for _, email := range card.Emails {
+		contact.Emails = append(contact.Emails, entities.ContactField{Value: email.Value, Type: email.Type})
+	}
+	return contact
+}
+
+func vcardFromContact(contact *entities.ContactCard) vcard.Card {
+	card := vcard.Card{
+		FormattedName: contact.Name,
+		FamilyName:    contact.LastName,
+		GivenName:     contact.FirstName,
+		Organization:  contact.Organization,
+	}
+	for _, phone := range contact.Phones {
+		card.Phones = append(card.Phones, vcard.Field{Value: phone.Value, Type: phone.Type})
+	}
+	for _, email := range contact.Emails {
+		card.Emails = append(card.Emails, vcard.Field{Value: email.Value, Type: email.Type})
+	}
+	return card
+}
+
+// ========== Chat Export ==========
+
+const exportPageSize = 500
+
+// ChatExport is an export that has passed the access checks. Write streams
+// it page by page, so a large chat is never built up in memory.
+type ChatExport struct {
+	FileName    string
+	ContentType string
+	write       func(w io.Writer) error
+}
+
+func (e *ChatExport) Write(w io.Writer) error {
+	return e.write(w)
+}
+
+// ExportChat prepares the chat as the user sees it: messages they deleted
+// for themselves are left out and messages deleted for everyone appear as
+// tombstones.
+func (t *ChatTransferUsecase) ExportChat(ctx context.Context, chatID, userID primitive.ObjectID, req *entities.ChatExportRequest) (*ChatExport, error) {
+	chat, err := t.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !t.messageUsecase.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	location := time.UTC
+	if req.Timezone != "" {
+		if location, err = time.LoadLocation(req.Timezone); err != nil {
+			return nil, errors.New("invalid timezone")
+		}
+	}
+
+	cleared, err := t.clearRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter := &chatExporter{
+		t:        t,
+		ctx:      ctx,
+		chat:     chat,
+		userID:   userID,
+		cleared:  cleared,
+		location: location,
+	}
+	baseName := "Chat with " + safeFileName(t.exportTitle(ctx, chat, userID), "chat")
+
+	switch req.Format {
+	case "", entities.ExportText:
+		return &ChatExport{FileName: baseName + ".txt", ContentType: "text/plain; charset=utf-8", write: exporter.writeText}, nil
+	case entities.ExportZip:
+		return &ChatExport{FileName: baseName + ".zip", ContentType: "application/zip", write: exporter.writeZip}, nil
+	case entities.ExportJSON:
+		return &ChatExport{FileName: baseName + ".json", ContentType: "application/json", write: exporter.writeJSON}, nil
+	default:
+		return nil, errors.New("unsupported export format")
+	}
+}
+
+// exportTitle names a group after itself and a direct chat after the other
+// participant.
+func (t *ChatTransferUsecase) exportTitle(ctx context.Context, chat *entities.Chat, userID primitive.ObjectID) string {
+	if chat.Type == entities.GroupChat || chat.Name != "" {
+		return chat.Name
+	}
+
+	for _, participantID := range chat.Participants {
+		if participantID == userID {
+			continue
+		}
+		if user, err := t.userRepo.GetByID(ctx, participantID); err == nil {
+			return user.Username
+		}
+	}
+	return ""
+}
+
+type chatExporter struct {
+	t        *ChatTransferUsecase
+	ctx      context.Context
+	chat     *entities.Chat
+	userID   primitive.ObjectID
+	cleared  *entities.ChatClear
+	location *time.Location
+}
+
+// exportAttachment is a file bundled next to the transcript in a ZIP export.
+type exportAttachment struct {
+	name    string
+	path    string                // Uploaded media
+	contact *entities.ContactCard // Written out as a vCard
+}
+
+// eachMessage walks the chat oldest first, skipping messages the user
+// deleted for themselves or cleared.
+func (e *chatExporter) eachMessage(fn func(msg *entities.Message, senderName string) error) error {
+	var cursor *entities.MessageCursor
+	for {
+		messages, err := e.t.messageRepo.GetChatMessages(e.ctx, e.chat.ID, e.cleared, cursor, repositories.PageNewer, exportPageSize)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		// Pages come back newest first
+		slices.Reverse(messages)
+		senderNames := e.t.messageUsecase.getSenderNames(e.ctx, messages)
+
+		for _, msg := range messages {
+			if e.t.messageUsecase.isDeletedForUser(msg, e.userID) {
+				continue
+			}
+			senderName := senderNames[msg.SenderID]
+			if msg.ImportedSender != "" {
+				senderName = msg.ImportedSender
+			}
+			if err := fn(msg, senderName); err != nil {
+				return err
+			}
+		}
+
+		last := messages[len(messages)-1]
+		cursor = &entities.MessageCursor{ID: last.ID, CreatedAt: last.CreatedAt}
+	}
+}
+
+func (e *chatExporter) writeText(w io.Writer) error {
+	tw := transcript.NewWriter(w)
+	err := e.eachMessage(func(msg *entities.Message, senderName string) error {
+		return tw.Write(e.transcriptLine(msg, senderName, ""))
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// writeZip bundles the transcript with the media it references. Files are
+// named after their position in the chat so they sort in order, the way
+// WhatsApp names them.
+func (e *chatExporter) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	transcriptFile, err := zw.Create("_chat.txt")
+	if err != nil {
+		return err
+	}
+
+	var attachments []exportAttachment
+	tw := transcript.NewWriter(transcriptFile)
+	err = e.eachMessage(func(msg *entities.Message, senderName string) error {
+		attachment, ok := e.attachmentOf(msg, len(attachments)+1)
+		if ok {
+			attachments = append(attachments, attachment)
+		}
+		return tw.Write(e.transcriptLine(msg, senderName, attachment.name))
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		if err := e.writeAttachment(zw, attachment); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (e *chatExporter) attachmentOf(msg *entities.Message, index int) (exportAttachment, bool) {
+	if msg.IsDeleted {
+		return exportAttachment{}, false
+	}
+
+	if msg.Type == entities.ContactMessage && msg.Contact != nil {
+		return exportAttachment{
+			name:    fmt.Sprintf("%08d-%s", index, contactFileName(msg.Contact.Name)),
+			contact: msg.Contact,
+		}, true
+	}
+
+	if msg.MediaURL == "" {
+		return exportAttachment{}, false
+	}
+
+	filePath, ok := e.t.fileUploadService.LocalPath(msg.MediaURL)
+	if !ok {
+		return exportAttachment{}, false
+	}
+
+	fileName := msg.FileName
+	if fileName == "" {
+		fileName = filepath.Base(filePath)
+	}
+
+	return exportAttachment{
+		name: fmt.Sprintf("%08d-%s", index, safeFileName(filepath.Base(fileName), "file")),
+		path: filePath,
+	}, true
+}
+
+func (e *chatExporter) writeAttachment(zw *zip.Writer, attachment exportAttachment) error {
+	if attachment.contact != nil {
+		entry, err := zw.Create(attachment.name)
+		if err != nil {
+			return err
+		}
+		return vcard.Encode(entry, []vcard.Card{vcardFromContact(attachment.contact)}, vcard.Version3)
+	}
+
+	file, err := os.Open(attachment.path)
+	if err != nil {
+		// Removed since the transcript was written; the marker stays
+		fmt.Printf("Failed to open exported media: %v", err)
+		return nil
+	}
+	defer file.Close()
+
+	// Media is already compressed, so store it as is
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: attachment.name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// transcriptLine renders a message the way WhatsApp exports it. attachment
+// names the bundled file for the message, if any.
+func (e *chatExporter) transcriptLine(msg *entities.Message, senderName, attachment string) transcript.Line {
+	line := transcript.Line{
+		Time:   msg.CreatedAt.In(e.location),
+		Sender: senderName,
+	}
+
+	if msg.Type == entities.SystemMessage {
+		line.Sender = ""
+		line.Text = msg.Content
+		return line
+	}
+
+	if msg.IsDeleted {
+		line.Text = transcript.DeletedMessage
+		if msg.SenderID == e.userID {
+			line.Text = transcript.YouDeletedMessage
+		}
+		return line
+	}
+
+	var parts []string
+	if msg.IsForwarded {
+		parts = append(parts, transcript.ForwardedMarker)
+	}
+
+	switch {
+	case attachment != "":
+		parts = append(parts, transcript.Attached(attachment))
+	case msg.MediaURL != "" || msg.Type == entities.ContactMessage:
+		parts = append(parts, transcript.MediaOmitted)
+	}
+
+	switch {
+	case msg.Type == entities.PollMessage && msg.Poll != nil:
+		poll := "POLL:\n" + msg.Poll.Question
+		for _, option := range msg.Poll.Options {
+			poll += "\nOPTION: " + option.Text
+		}
+		parts = append(parts, poll)
+	case msg.Type == entities.LocationMessage && msg.Location != nil:
+		if msg.Location.PlaceName != "" {
+			parts = append(parts, msg.Location.PlaceName+":")
+		}
+		parts = append(parts, fmt.Sprintf("location: https://maps.google.com/?q=%f,%f", msg.Location.Latitude, msg.Location.Longitude))
+	case msg.Type == entities.ContactMessage:
+		// The card itself is the attachment
+	case msg.Content != "":
+		parts = append(parts, msg.Content)
+	}
+
+	if msg.EditedAt != nil {
+		parts = append(parts, transcript.EditedMarker)
+	}
+
+	line.Text = strings.Join(parts, " ")
+	return line
+}
+
+// writeJSON streams a ChatArchive with the messages appended as an array.
+func (e *chatExporter) writeJSON(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	archive := &entities.ChatArchive{
+		ChatID:     e.chat.ID,
+		Type:       e.chat.Type,
+		Name:       e.t.exportTitle(e.ctx, e.chat, e.userID),
+		ExportedBy: e.userID,
+		ExportedAt: time.Now(),
+	}
+	if users, err := e.t.userRepo.GetByIDs(e.ctx, e.chat.Participants); err == nil {
+		for _, user := range users {
+			archive.Participants = append(archive.Participants, entities.ArchiveParticipant{UserID: user.ID, Username: user.Username})
+		}
+	}
+
+	header, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	// Reopen the header object to append the messages to it
+	bw.Write(header[:len(header)-1])
+	bw.WriteString(`,"messages":[`)
+
+	first := true
+	err = e.eachMessage(func(msg *entities.Message, senderName string) error {
+		data, err := json.Marshal(archivedMessage(msg, senderName))
+		if err != nil {
+			return err
+		}
+		if !first {
+			bw.WriteString(",")
+		}
+		first = false
+		_, err = bw.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+func archivedMessage(msg *entities.Message, senderName string) *entities.ArchivedMessage {
+	return &entities.ArchivedMessage{
+		ID:         msg.ID,
+		SenderID:   msg.SenderID,
+		SenderName: senderName,
+		Type:       msg.Type,
+		Content:    msg.Content,
+		MediaURL:   msg.MediaURL,
+		MediaType:  msg.MediaType,
+		FileName:   msg.FileName,
+		FileSize:   msg.FileSize,
+		Duration:   msg.Duration,
+		Poll:       msg.Poll,
+		Location:   msg.Location,
+		Contact:    msg.Contact,
+		ReplyToID:  msg.ReplyToID,
+		Reactions:  msg.Reactions,
+		Forwarded:  msg.IsForwarded,
+		EditedAt:   msg.EditedAt,
+		EditCount:  msg.EditCount,
+		IsDeleted:  msg.IsDeleted,
+		DeletedAt:  msg.DeletedAt,
+		CreatedAt:  msg.CreatedAt,
+	}
+}
+
+// ========== Chat Import ==========
+
+const importBatchSize = 1000
+
+// importSource is an uploaded export: the transcript and, for a ZIP, the
+// files bundled with it by name.
+type importSource struct {
+	transcript io.Reader
+	media      map[string]*zip.File
+}
+
+// ImportChat recreates the messages of a WhatsApp .txt or .zip export with
+// their original times. Senders are mapped to users through req.Senders,
+// by the importer's own name, or by phone number when creating a new chat;
+// the rest keep their name as a placeholder on messages posted by the
+// importing user.
+func (t *ChatTransferUsecase) ImportChat(ctx context.Context, userID primitive.ObjectID, req *entities.ChatImportRequest, file io.ReaderAt, size int64, fileName string) (*entities.ChatImportReport, error) {
+	location := time.UTC
+	if req.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(req.Timezone); err != nil {
+			return nil, errors.New("invalid timezone")
+		}
+	}
+
+	order := transcript.DateOrder(req.DateOrder)
+	switch order {
+	case "", transcript.DayMonthYear, transcript.MonthDayYear, transcript.YearMonthDay:
+	default:
+		return nil, errors.New("date order must be dmy, mdy or ymd")
+	}
+
+	var chat *entities.Chat
+	if req.ChatID != nil {
+		var err error
+		if chat, err = t.chatRepo.GetByID(ctx, *req.ChatID); err != nil {
+			return nil, errors.New("chat not found")
+		}
+		if !t.messageUsecase.isParticipant(userID, chat.Participants) {
+			return nil, errors.New("user is not a participant in this chat")
+		}
+	}
+
+	source, err := openImportSource(file, size, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := transcript.Parse(io.LimitReader(source.transcript, entities.MaxTranscriptSize), transcript.Options{
+		DateOrder:   order,
+		Location:    location,
+		MaxMessages: entities.MaxImportedMessages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	senders, err := t.matchImportSenders(ctx, userID, chat, parsed.Messages, req.Senders)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &entities.ChatImportReport{
+		DryRun:    req.DryRun,
+		DateOrder: string(parsed.DateOrder),
+		Messages:  len(parsed.Messages),
+	}
+	first, last := parsed.Messages[0].Time, parsed.Messages[len(parsed.Messages)-1].Time
+	report.FirstMessageAt, report.LastMessageAt = &first, &last
+
+	for _, msg := range parsed.Messages {
+		switch {
+		case msg.Attachment != "" && source.media[msg.Attachment] != nil:
+			report.Media++
+		case msg.Attachment != "":
+			report.MissingMedia = append(report.MissingMedia, msg.Attachment)
+		case msg.MediaOmitted:
+			report.OmittedMedia++
+		}
+	}
+
+	if req.DryRun {
+		if chat != nil {
+			report.ChatID = &chat.ID
+		}
+		report.Senders = senders.report()
+		return report, nil
+	}
+
+	if chat == nil {
+		if chat, err = t.createImportChat(ctx, userID, req.Name, fileName, senders); err != nil {
+			return nil, err
+		}
+	}
+	report.ChatID = &chat.ID
+
+	// Media that fails to save is reported again below
+	report.MissingMedia = nil
+
+	now := time.Now()
+	batch := make([]*entities.Message, 0, importBatchSize)
+	for _, msg := range parsed.Messages {
+		message := t.importedMessage(ctx, userID, chat, msg, senders, source, now, report)
+		batch = append(batch, message)
+
+		if len(batch) == importBatchSize {
+			if err := t.messageRepo.CreateImported(ctx, batch); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := t.messageRepo.CreateImported(ctx, batch); err != nil {
+		return nil, err
+	}
+
+	if lastMessage, err := t.messageRepo.GetLastMessage(ctx, chat.ID); err == nil {
+		if err := t.chatRepo.UpdateLastMessage(ctx, chat.ID, lastMessage); err != nil {
+			fmt.Printf("Failed to update last message: %v", err)
+		}
+	}
+
+	report.Senders = senders.report()
+	return report, nil
+}
+
+func openImportSource(file io.ReaderAt, size int64, fileName string) (*importSource, error) {
+	if size > entities.MaxChatImportSize {
+		return nil, errors.New("import file is too large")
+	}
+
+	if !strings.EqualFold(filepath.Ext(fileName), ".zip") {
+		if size > entities.MaxTranscriptSize {
+			return nil, errors.New("chat transcript is too large")
+		}
+		return &importSource{transcript: io.NewSectionReader(file, 0, size)}, nil
+	}
+
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, errors.New("invalid zip file")
+	}
+
+	source := &importSource{media: make(map[string]*zip.File)}
+	var transcriptFile *zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Base(f.Name)
+		source.media[name] = f
+
+		// iOS names the transcript _chat.txt and Android after the chat;
+		// other .txt files may be shared documents
+		if name == "_chat.txt" || strings.HasPrefix(name, "WhatsApp Chat") && strings.HasSuffix(name, ".txt") {
+			transcriptFile = f
+		}
+	}
+
+	if transcriptFile == nil {
+		return nil, errors.New("no chat transcript found in zip file")
+	}
+	if transcriptFile.UncompressedSize64 > entities.MaxTranscriptSize {
+		return nil, errors.New("chat transcript is too large")
+	}
+	delete(source.media, path.Base(transcriptFile.Name))
+
+	r, err := transcriptFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	source.transcript = r
+	return source, nil
+}
+
+// importSenders maps transcript names to users; names without a user map
+// to nil.
+type importSenders struct {
+	byName map[string]*entities.ImportSender
+	order  []string
+}
+
+func (s *importSenders) userID(name string) *primitive.ObjectID {
+	if sender := s.byName[name]; sender != nil {
+		return sender.UserID
+	}
+	return nil
+}
+
+func (s *importSenders) report() []entities.ImportSender {
+	senders := make([]entities.ImportSender, 0, len(s.order))
+	for _, name := range s.order {
+		senders = append(senders, *s.byName[name])
+	}
+	return senders
+}
+
+func (t *ChatTransferUsecase) matchImportSenders(ctx context.Context, userID primitive.ObjectID, chat *entities.Chat, messages []transcript.Message, mapping map[string]primitive.ObjectID) (*importSenders, error) {
+	senders := &importSenders{byName: make(map[string]*entities.ImportSender)}
+	for _, msg := range messages {
+		if msg.Sender == "" {
+			continue
+		}
+		if sender := senders.byName[msg.Sender]; sender != nil {
+			sender.Messages++
+			continue
+		}
+		if len(senders.order) == entities.MaxImportedSenders {
+			return nil, fmt.Errorf("a chat import can have at most %d senders", entities.MaxImportedSenders)
+		}
+		senders.byName[msg.Sender] = &entities.ImportSender{Name: msg.Sender, Messages: 1}
+		senders.order = append(senders.order, msg.Sender)
+	}
+
+	// Other members of an existing chat never wrote what is imported into
+	// it, so their names stay on the importer's messages as placeholders.
+	// Only a new chat, which they are added to, can credit them.
+	mappedIDs := make([]primitive.ObjectID, 0, len(mapping))
+	for name, mappedID := range mapping {
+		sender := senders.byName[name]
+		if sender == nil {
+			return nil, fmt.Errorf("sender %q does not appear in the transcript", name)
+		}
+		if chat != nil && mappedID != userID {
+			return nil, fmt.Errorf("sender %q can only be mapped to yourself in an existing chat", name)
+		}
+		id := mappedID
+		sender.UserID = &id
+		sender.MatchedBy = "mapping"
+		mappedIDs = append(mappedIDs, mappedID)
+	}
+	if len(mappedIDs) > 0 {
+		users, err := t.userRepo.GetByIDs(ctx, mappedIDs)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[primitive.ObjectID]bool, len(users))
+		for _, user := range users {
+			found[user.ID] = true
+		}
+		for _, mappedID := range mappedIDs {
+			if !found[mappedID] {
+				return nil, errors.New("one or more mapped users not found")
+			}
+		}
+	}
+
+	// Names are only matched to the importer
+	users, err := t.userRepo.GetByIDs(ctx, []primitive.ObjectID{userID})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range senders.order {
+		sender := senders.byName[name]
+		if sender.UserID != nil {
+			continue
+		}
+
+		for _, user := range users {
+			fullName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+			if strings.EqualFold(name, user.Username) || fullName != "" && strings.EqualFold(name, fullName) {
+				id := user.ID
+				sender.UserID = &id
+				sender.MatchedBy = "participant"
+				break
+			}
+		}
+		if sender.UserID != nil {
+			continue
+		}
+
+		// Contacts missing from the phone's address book show as numbers
+		if !strings.ContainsFunc(name, unicode.IsLetter) {
+			if phone := normalizePhone(name); len(phone) >= 7 {
+				if user, err := t.userRepo.GetByPhone(ctx, []string{name, phone}); err == nil {
+					if chat == nil || user.ID == userID {
+						id := user.ID
+						sender.UserID = &id
+						sender.MatchedBy = "phone"
+					}
+				}
+			}
+		}
+	}
+
+	return senders, nil
+}
+
+// createImportChat starts a group with the importer and every matched
+// sender, named after the export unless a name is given.
+func (t *ChatTransferUsecase) createImportChat(ctx context.Context, userID primitive.ObjectID, name, fileName string, senders *importSenders) (*entities.Chat, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		name = strings.TrimSpace(strings.TrimPrefix(name, "WhatsApp Chat with"))
+		if name == "" || name == "_chat" {
+			name = entities.DefaultImportChatName
+		}
+	}
+
+	participants := []primitive.ObjectID{userID}
+	for _, senderName := range senders.order {
+		if id := senders.userID(senderName); id != nil && !t.messageUsecase.isParticipant(*id, participants) {
+			participants = append(participants, *id)
+		}
+	}
+
+	chat := &entities.Chat{
+		Type:         entities.GroupChat,
+		Name:         name,
+		Participants: participants,
+		CreatedBy:    userID,
+	}
+
+	if err := t.chatRepo.Create(ctx, chat); err != nil {
+		return nil, err
+	}
+
+	return chat, nil
+}
+
+// importedMessage rebuilds one message, saving its media. History arrives
+// already read by everyone so it does not show up as unread.
+func (t *ChatTransferUsecase) importedMessage(ctx context.Context, userID primitive.ObjectID, chat *entities.Chat, msg transcript.Message, senders *importSenders, source *importSource, now time.Time, report *entities.ChatImportReport) *entities.Message {
+	message := &entities.Message{
+		ChatID:      chat.ID,
+		SenderID:    userID,
+		Type:        entities.TextMessage,
+		Content:     msg.Text,
+		IsForwarded: msg.Forwarded,
+		Status:      entities.MessageRead,
+		DeliveredTo: []entities.DeliveryInfo{},
+		ReadBy:      []entities.ReadInfo{},
+		Reactions:   []entities.MessageReaction{},
+		CreatedAt:   msg.Time,
+	}
+
+	switch {
+	case msg.Sender == "":
+		message.Type = entities.SystemMessage
+	case senders.userID(msg.Sender) != nil:
+		message.SenderID = *senders.userID(msg.Sender)
+	default:
+		message.ImportedSender = msg.Sender
+	}
+
+	for _, participantID := range chat.Participants {
+		if participantID == message.SenderID {
+			continue
+		}
+		message.DeliveredTo = append(message.DeliveredTo, entities.DeliveryInfo{UserID: participantID, DeliveredAt: now})
+		message.ReadBy = append(message.ReadBy, entities.ReadInfo{UserID: participantID, ReadAt: now})
+	}
+
+	if msg.Edited {
+		editedAt := msg.Time
+		message.EditedAt = &editedAt
+	}
+
+	if msg.Deleted {
+		deletedAt := msg.Time
+		message.IsDeleted = true
+		message.Content = entities.DeletedMessagePlaceholder
+		message.DeletedAt = &deletedAt
+		message.DeletedBy = &message.SenderID
+		return message
+	}
+
+	message.PlainText, message.Entities = formatContent(message.Type, message.Content)
+
+	if msg.Attachment != "" {
+		if !t.attachImportedMedia(ctx, userID, message, msg.Attachment, source) {
+			report.MissingMedia = append(report.MissingMedia, msg.Attachment)
+		}
+	}
+
+	// Keep something to show for media that did not come along
+	if message.Content == "" && message.MediaURL == "" && message.Contact == nil {
+		if msg.Attachment != "" {
+			message.Content = transcript.Attached(msg.Attachment)
+		} else if msg.MediaOmitted {
+			message.Content = transcript.MediaOmitted
+		}
+	}
+
+	return message
+}
+
+// attachImportedMedia saves a bundled file through the upload service and
+// points the message at it. Contact cards become contact messages.
+func (t *ChatTransferUsecase) attachImportedMedia(ctx context.Context, userID primitive.ObjectID, message *entities.Message, name string, source *importSource) bool {
+	f := source.media[name]
+	if f == nil {
+		return false
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+
+	if strings.EqualFold(path.Ext(name), ".vcf") {
+		cards, err := vcard.Parse(io.LimitReader(r, entities.MaxVCardSize))
+		if err != nil || len(cards) == 0 {
+			return false
+		}
+		message.Type = entities.ContactMessage
+		message.Contact = t.messageUsecase.buildContact(ctx, contactFromVCard(cards[0]))
+		return true
+	}
+
+	result, err := t.fileUploadService.SaveFile(name, r, userID)
+	if err != nil {
+		fmt.Printf("Failed to import media %s: %v", name, err)
+		return false
+	}
+
+	switch result.MediaType {
+	case "image":
+		message.Type = entities.ImageMessage
+	case "video":
+		message.Type = entities.VideoMessage
+	case "audio":
+		message.Type = entities.AudioMessage
+	default:
+		message.Type = entities.DocumentMessage
+	}
+	message.MediaURL = result.FileURL
+	message.MediaType = result.MediaType
+	message.FileName = name
+	message.FileSize = result.FileSize
+	message.ThumbnailURL = result.ThumbnailURL
+	message.Dimensions = result.Dimensions
+	message.Duration = result.Duration
+	return true
+}
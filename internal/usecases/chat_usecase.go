@@ -5,19 +5,27 @@ import (
 	"bro-chat/internal/domain/repositories"
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const draftPreviewLength = 100 // Runes of draft text shown in the chat list
+
 type ChatUsecase struct {
-	chatRepo repositories.ChatRepository
-	userRepo repositories.UserRepository
+	chatRepo  repositories.ChatRepository
+	userRepo  repositories.UserRepository
+	draftRepo repositories.DraftRepository
+	clearRepo repositories.ChatClearRepository
 }
 
-func NewChatUsecase(chatRepo repositories.ChatRepository, userRepo repositories.UserRepository) *ChatUsecase {
+func NewChatUsecase(chatRepo repositories.ChatRepository, userRepo repositories.UserRepository, draftRepo repositories.DraftRepository, clearRepo repositories.ChatClearRepository) *ChatUsecase {
 	return &ChatUsecase{
-		chatRepo: chatRepo,
-		userRepo: userRepo,
+		chatRepo:  chatRepo,
+		userRepo:  userRepo,
+		draftRepo: draftRepo,
+		clearRepo: clearRepo,
 	}
 }
 
@@ -69,7 +77,67 @@ func (c *ChatUsecase) CreateChat(ctx context.Context, userID primitive.ObjectID,
 }
 
 func (c *ChatUsecase) GetUserChats(ctx context.Context, userID primitive.ObjectID) ([]*entities.Chat, error) {
-	return c.chatRepo.GetUserChats(ctx, userID)
+	chats, err := c.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.hideClearedPreviews(ctx, userID, chats)
+
+	// Attach the user's drafts so the list can show "Draft: ..."
+	drafts, err := c.draftRepo.GetUserDrafts(ctx, userID)
+	if err != nil {
+		fmt.Printf("Failed to load drafts: %v", err)
+		return chats, nil
+	}
+
+	draftsByChat := make(map[primitive.ObjectID]*entities.MessageDraft, len(drafts))
+	for _, draft := range drafts {
+		draftsByChat[draft.ChatID] = draft
+	}
+
+	for _, chat := range chats {
+		if draft, ok := draftsByChat[chat.ID]; ok {
+			chat.Draft = draft
+			chat.DraftPreview = draftPreview(draft.Content)
+		}
+	}
+
+	return chats, nil
+}
+
+// hideClearedPreviews drops the last message from chats the user cleared
+// after it was sent, as it is no longer in their copy of the chat.
+func (c *ChatUsecase) hideClearedPreviews(ctx context.Context, userID primitive.ObjectID, chats []*entities.Chat) {
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	for _, chat := range chats {
+		chatIDs = append(chatIDs, chat.ID)
+	}
+
+	clears, err := c.clearRepo.GetForChats(ctx, userID, chatIDs)
+	if err != nil {
+		fmt.Printf("Failed to load chat clears: %v", err)
+		return
+	}
+
+	clearsByChat := make(map[primitive.ObjectID]*entities.ChatClear, len(clears))
+	for _, cleared := range clears {
+		clearsByChat[cleared.ChatID] = cleared
+	}
+
+	for _, chat := range chats {
+		if chat.LastMessage != nil && isHiddenByClear(chat.LastMessage, clearsByChat[chat.ID]) {
+			chat.LastMessage = nil
+		}
+	}
+}
+
+func draftPreview(content string) string {
+	preview := strings.Join(strings.Fields(content), " ")
+	if runes := []rune(preview); len(runes) > draftPreviewLength {
+		preview = string(runes[:draftPreviewLength]) + "…"
+	}
+	return entities.DraftPreviewPrefix + preview
 }
 
 func (c *ChatUsecase) GetChat(ctx context.Context, chatID, userID primitive.ObjectID) (*entities.Chat, error) {
@@ -4,22 +4,29 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 	"bro-chat/internal/domain/entities"
 	"bro-chat/internal/domain/repositories"
+	"bro-chat/pkg/emoji"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// maxAllowedReactions caps a group's reaction allowlist.
+const maxAllowedReactions = 50
+
 type GroupUsecase struct {
-	groupRepo repositories.GroupRepository
-	userRepo  repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+	messageUsecase *MessageUsecase
 }
 
-func NewGroupUsecase(groupRepo repositories.GroupRepository, userRepo repositories.UserRepository) *GroupUsecase {
+func NewGroupUsecase(groupRepo repositories.GroupRepository, userRepo repositories.UserRepository, messageUsecase *MessageUsecase) *GroupUsecase {
 	return &GroupUsecase{
-		groupRepo: groupRepo,
-		userRepo:  userRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+		messageUsecase: messageUsecase,
 	}
 }
 
@@ -107,12 +114,40 @@ func (u *GroupUsecase) UpdateGroupSettings(ctx context.Context, groupIDStr, user
 		return errors.New("you don't have permission to update group settings")
 	}
 
+	if req.DisappearingTime != nil && *req.DisappearingTime < 0 {
+		return errors.New("disappearing time cannot be negative")
+	}
+
+	if req.AllowedReactions != nil {
+		if len(*req.AllowedReactions) > maxAllowedReactions {
+			return fmt.Errorf("a group can allow at most %d reactions", maxAllowedReactions)
+		}
+		for _, reaction := range *req.AllowedReactions {
+			if !emoji.IsEmoji(string(reaction)) {
+				return fmt.Errorf("allowed reaction %q is not a single emoji", reaction)
+			}
+		}
+	}
+
+	// Remember the current timer so we can tell whether it changed
+	previousTimer := 0
+	if groupInfo, err := u.groupRepo.GetGroupInfo(ctx, groupID); err == nil {
+		previousTimer = disappearingSeconds(groupInfo.Settings)
+	}
+
 	// Update group settings
 	err = u.groupRepo.UpdateGroupSettings(ctx, groupID, req)
 	if err != nil {
 		return err
 	}
 
+	// Post a timeline notice when the disappearing timer changes
+	if groupInfo, err := u.groupRepo.GetGroupInfo(ctx, groupID); err == nil {
+		if newTimer := disappearingSeconds(groupInfo.Settings); newTimer != previousTimer {
+			u.messageUsecase.NotifyDisappearingTimerChanged(ctx, groupID, userID, newTimer)
+		}
+	}
+
 	// Log activity
 	u.logActivity(ctx, groupID, userID, "group_settings_updated", nil, map[string]interface{}{
 		"settings": req,
@@ -785,6 +820,14 @@ func (u *GroupUsecase) canChangeRole(ctx context.Context, groupID, currentUserID
 	return true, nil
 }
 
+// disappearingSeconds returns the effective disappearing timer of a group.
+func disappearingSeconds(settings *entities.GroupSettings) int {
+	if settings == nil || !settings.DisappearingMessages {
+		return 0
+	}
+	return settings.DisappearingTime
+}
+
 func (u *GroupUsecase) logActivity(ctx context.Context, groupID, actorID primitive.ObjectID, activityType string, targetUserID *primitive.ObjectID, details map[string]interface{}) {
 	activity := &entities.GroupActivity{
 		GroupID:      groupID,
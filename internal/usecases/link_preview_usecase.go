@@ -0,0 +1,168 @@
+package usecases
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"bro-chat/pkg/linkpreview"
+	"bro-chat/pkg/services"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	linkPreviewTTL        = 24 * time.Hour
+	linkPreviewFailureTTL = time.Hour
+	linkPreviewDeadline   = 30 * time.Second // Page, image and thumbnail together
+)
+
+// linkPattern finds http(s) links; trailing punctuation is trimmed by firstLink.
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+type LinkPreviewUsecase struct {
+	previewRepo       repositories.LinkPreviewRepository
+	fetcher           linkpreview.Fetcher
+	fileUploadService *services.FileUploadService
+}
+
+func NewLinkPreviewUsecase(
+	previewRepo repositories.LinkPreviewRepository,
+	fetcher linkpreview.Fetcher,
+	fileUploadService *services.FileUploadService,
+) *LinkPreviewUsecase {
+	return &LinkPreviewUsecase{
+		previewRepo:       previewRepo,
+		fetcher:           fetcher,
+		fileUploadService: fileUploadService,
+	}
+}
+
+// GetPreview returns the preview for a link, from the cache when possible.
+// It returns nil without an error when the page has nothing to show.
+func (l *LinkPreviewUsecase) GetPreview(ctx context.Context, link string) (*entities.LinkPreview, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	host := previewHost(u)
+	now := time.Now()
+
+	cached, err := l.previewRepo.Get(ctx, link, now)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cached.Preview, nil
+	}
+
+	unreachable, err := l.previewRepo.IsHostUnreachable(ctx, host, now)
+	if err != nil {
+		return nil, err
+	}
+	if unreachable {
+		return nil, nil
+	}
+
+	entry := &entities.CachedLinkPreview{
+		URL:       link,
+		Host:      host,
+		FetchedAt: now,
+		ExpiresAt: now.Add(linkPreviewTTL),
+	}
+
+	page, err := l.fetcher.FetchPage(ctx, link)
+	if err != nil {
+		// Cache failures for a shorter time so a site that was briefly
+		// down gets another chance
+		entry.ExpiresAt = now.Add(linkPreviewFailureTTL)
+		entry.Unreachable = isConnectionError(err)
+		if saveErr := l.previewRepo.Save(ctx, entry); saveErr != nil {
+			fmt.Printf("Failed to cache link preview failure: %v", saveErr)
+		}
+		return nil, err
+	}
+
+	if page.Title != "" || page.Description != "" || page.ImageURL != "" {
+		entry.Preview = &entities.LinkPreview{
+			URL:         link,
+			Title:       page.Title,
+			Description: page.Description,
+			ImageURL:    page.ImageURL,
+			SiteName:    page.SiteName,
+		}
+		if page.ImageURL != "" {
+			entry.Preview.ThumbnailURL = l.storeThumbnail(ctx, page.ImageURL)
+		}
+	}
+
+	if err := l.previewRepo.Save(ctx, entry); err != nil {
+		fmt.Printf("Failed to cache link preview: %v", err)
+	}
+
+	return entry.Preview, nil
+}
+
+// storeThumbnail keeps our own copy of the preview image so clients never
+// load it from the third-party site. Failures just leave the preview
+// without a thumbnail.
+func (l *LinkPreviewUsecase) storeThumbnail(ctx context.Context, imageURL string) string {
+	data, err := l.fetcher.FetchImage(ctx, imageURL)
+	if err != nil {
+		return ""
+	}
+
+	thumbnailURL, err := l.fileUploadService.SaveLinkThumbnail(imageURL, data)
+	if err != nil {
+		fmt.Printf("Failed to store link preview thumbnail: %v", err)
+		return ""
+	}
+
+	return thumbnailURL
+}
+
+// firstLink returns the first http(s) link in content, or "" if there is none.
+func firstLink(content string) string {
+	for _, match := range linkPattern.FindAllString(content, -1) {
+		link := strings.TrimRight(match, ".,;:!?'\")]}")
+
+		// Keep a closing bracket that belongs to the link, as in Wikipedia URLs
+		if strings.Count(link, "(") > strings.Count(link, ")") && strings.HasPrefix(match[len(link):], ")") {
+			link += ")"
+		}
+
+		if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+// previewHost keys failures by host and port, so one unreachable service
+// does not stop previews from the rest of the host.
+func previewHost(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if strings.EqualFold(u.Scheme, "https") {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// isConnectionError reports failures to reach the host. Addresses we
+// refused to dial say nothing about the host's other links.
+func isConnectionError(err error) bool {
+	if errors.Is(err, linkpreview.ErrBlockedAddress) {
+		return false
+	}
+
+	var netErr net.Error
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.As(err, &netErr) && netErr.Timeout()
+}
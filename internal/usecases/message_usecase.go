@@ -3,39 +3,124 @@ package usecases
 import (
 	"bro-chat/internal/domain/entities"
 	"bro-chat/internal/domain/repositories"
+	"bro-chat/pkg/emoji"
+	"bro-chat/pkg/formatting"
+	"bro-chat/pkg/services"
+	"bro-chat/pkg/vcard"
 	"bro-chat/pkg/websocket"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+	reaperBatchSize = 100
+)
+
+// ErrInvalidCursor is returned for a page cursor that cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// mentionPattern matches @username tokens that start a word, so e-mail
+// addresses are not taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])@([\p{L}\p{N}_.\-]+)`)
+
 type MessageUsecase struct {
-	messageRepo repositories.MessageRepository
-	chatRepo    repositories.ChatRepository
-	userRepo    repositories.UserRepository
-	hub         *websocket.Hub
+	messageRepo       repositories.MessageRepository
+	chatRepo          repositories.ChatRepository
+	userRepo          repositories.UserRepository
+	groupRepo         repositories.GroupRepository
+	starredRepo       repositories.StarredMessageRepository
+	pinnedRepo        repositories.PinnedMessageRepository
+	threadRepo        repositories.ThreadRepository
+	pollRepo          repositories.PollRepository
+	draftRepo         repositories.DraftRepository
+	clearRepo         repositories.ChatClearRepository
+	linkPreviews      *LinkPreviewUsecase
+	fileUploadService *services.FileUploadService
+	hub               *websocket.Hub
+	editWindow        time.Duration // Zero means no limit
+	deleteWindow      time.Duration // Zero means no limit
+	maxPinnedMessages int
+	reactionAllowlist []string // Empty allows any emoji
 }
 
 func NewMessageUsecase(
 	messageRepo repositories.MessageRepository,
 	chatRepo repositories.ChatRepository,
 	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	starredRepo repositories.StarredMessageRepository,
+	pinnedRepo repositories.PinnedMessageRepository,
+	threadRepo repositories.ThreadRepository,
+	pollRepo repositories.PollRepository,
+	draftRepo repositories.DraftRepository,
+	clearRepo repositories.ChatClearRepository,
+	linkPreviews *LinkPreviewUsecase,
+	fileUploadService *services.FileUploadService,
 	hub *websocket.Hub,
+	editWindow time.Duration,
+	deleteWindow time.Duration,
+	maxPinnedMessages int,
+	reactionAllowlist []string,
 ) *MessageUsecase {
 	return &MessageUsecase{
-		messageRepo: messageRepo,
-		chatRepo:    chatRepo,
-		userRepo:    userRepo,
-		hub:         hub,
+		messageRepo:       messageRepo,
+		chatRepo:          chatRepo,
+		userRepo:          userRepo,
+		groupRepo:         groupRepo,
+		starredRepo:       starredRepo,
+		pinnedRepo:        pinnedRepo,
+		threadRepo:        threadRepo,
+		pollRepo:          pollRepo,
+		draftRepo:         draftRepo,
+		clearRepo:         clearRepo,
+		linkPreviews:      linkPreviews,
+		fileUploadService: fileUploadService,
+		hub:               hub,
+		editWindow:        editWindow,
+		deleteWindow:      deleteWindow,
+		maxPinnedMessages: maxPinnedMessages,
+		reactionAllowlist: reactionAllowlist,
 	}
 }
 
 // ========== Core Message Operations ==========
 
+// SendMessage sends a message and clears the sender's draft for the chat.
 func (m *MessageUsecase) SendMessage(ctx context.Context, userID primitive.ObjectID, req *entities.SendMessageRequest) (*entities.Message, error) {
+	return m.sendMessage(ctx, userID, req, true)
+}
+
+// GetSentMessage returns the message the user already sent to the chat
+// with the given client message ID, or nil when there is none.
+func (m *MessageUsecase) GetSentMessage(ctx context.Context, userID, chatID primitive.ObjectID, clientMessageID string) (*entities.Message, error) {
+	if len(clientMessageID) > entities.MaxClientMessageIDLength {
+		return nil, fmt.Errorf("client message ID cannot be longer than %d characters", entities.MaxClientMessageIDLength)
+	}
+
+	return m.messageRepo.GetByClientMessageID(ctx, chatID, userID, clientMessageID)
+}
+
+// SendScheduledMessage delivers a scheduled message. The draft was cleared
+// when it was scheduled and may hold something new by now, so it is kept.
+func (m *MessageUsecase) SendScheduledMessage(ctx context.Context, userID primitive.ObjectID, req *entities.SendMessageRequest) (*entities.Message, error) {
+	return m.sendMessage(ctx, userID, req, false)
+}
+
+func (m *MessageUsecase) sendMessage(ctx context.Context, userID primitive.ObjectID, req *entities.SendMessageRequest, clearDraft bool) (*entities.Message, error) {
 	// Verify user is participant in chat
 	chat, err := m.chatRepo.GetByID(ctx, req.ChatID)
 	if err != nil {
@@ -46,6 +131,18 @@ func (m *MessageUsecase) SendMessage(ctx context.Context, userID primitive.Objec
 		return nil, errors.New("user is not a participant in this chat")
 	}
 
+	// A retry of a send that already went through gets the original back
+	// and nothing is broadcast again
+	if req.ClientMessageID != "" {
+		sent, err := m.GetSentMessage(ctx, userID, req.ChatID, req.ClientMessageID)
+		if err != nil {
+			return nil, err
+		}
+		if sent != nil {
+			return sent, nil
+		}
+	}
+
 	// Validate message content based on type
 	if err := m.validateMessageContent(req); err != nil {
 		return nil, err
@@ -53,30 +150,82 @@ func (m *MessageUsecase) SendMessage(ctx context.Context, userID primitive.Objec
 
 	// Create message
 	message := &entities.Message{
-		ChatID:      req.ChatID,
-		SenderID:    userID,
-		Type:        req.Type,
-		Content:     req.Content,
-		MediaURL:    req.MediaURL,
-		MediaType:   req.MediaType,
-		FileName:    req.FileName,
-		FileSize:    req.FileSize,
-		Duration:    req.Duration,
-		Dimensions:  req.Dimensions,
-		ReplyToID:   req.ReplyToID,
-		Status:      entities.MessageSent,
-		ReadBy:      []entities.ReadInfo{},
-		DeliveredTo: []entities.DeliveryInfo{},
-		Reactions:   []entities.MessageReaction{},
-		IsForwarded: false,
-		IsDeleted:   false,
+		ChatID:          req.ChatID,
+		SenderID:        userID,
+		ClientMessageID: req.ClientMessageID,
+		Type:            req.Type,
+		Content:         req.Content,
+		MediaURL:        req.MediaURL,
+		MediaType:       req.MediaType,
+		FileName:        req.FileName,
+		FileSize:        req.FileSize,
+		Duration:        req.Duration,
+		Dimensions:      req.Dimensions,
+		ReplyToID:       req.ReplyToID,
+		Status:          entities.MessageSent,
+		ReadBy:          []entities.ReadInfo{},
+		DeliveredTo:     []entities.DeliveryInfo{},
+		Reactions:       []entities.MessageReaction{},
+		IsForwarded:     false,
+		IsDeleted:       false,
+	}
+
+	// The question doubles as the content so previews and search work
+	if req.Type == entities.PollMessage {
+		message.Poll = buildPoll(req.Poll)
+		message.Content = message.Poll.Question
+	}
+
+	if req.Type == entities.LocationMessage {
+		message.Location = buildLocation(req.Location, time.Now())
+		message.Content = locationPreview(message.Location)
+	}
+
+	if req.Type == entities.ContactMessage {
+		message.Contact = m.buildContact(ctx, req.Contact)
+		message.Content = message.Contact.Name
+	}
+
+	// Stamp an expiry if the chat has disappearing messages turned on
+	if timer := m.disappearingTimer(ctx, chat); timer > 0 {
+		expiresAt := time.Now().Add(timer)
+		message.ExpiresAt = &expiresAt
+	}
+
+	// Replies inherit their parent's place in the thread
+	if req.ReplyToID != nil {
+		parent, err := m.messageRepo.GetByID(ctx, *req.ReplyToID)
+		if err != nil || parent.ChatID != req.ChatID {
+			return nil, errors.New("replied message not found")
+		}
+		message.ThreadPath = append(append([]primitive.ObjectID{}, parent.ThreadPath...), parent.ID)
 	}
 
-	// Save message to database
-	if err := m.messageRepo.Create(ctx, message); err != nil {
+	message.PlainText, message.Entities = formatContent(message.Type, message.Content)
+	message.Mentions = m.resolveMentions(ctx, req.Content, chat.Participants)
+
+	// Save message to database. A concurrent retry can still get here
+	// first, in which case its message is the one to return.
+	if message.ClientMessageID != "" {
+		sent, err := m.messageRepo.CreateIdempotent(ctx, message)
+		if err != nil {
+			return nil, err
+		}
+		if sent != nil {
+			return sent, nil
+		}
+	} else if err := m.messageRepo.Create(ctx, message); err != nil {
 		return nil, err
 	}
 
+	if len(message.ThreadPath) > 0 {
+		m.recordThreadReply(ctx, message)
+	}
+
+	if clearDraft {
+		m.clearDraft(ctx, userID, req.ChatID)
+	}
+
 	// Update chat's last message
 	if err := m.chatRepo.UpdateLastMessage(ctx, req.ChatID, message); err != nil {
 		// Log error but don't fail the message send
@@ -91,14 +240,18 @@ func (m *MessageUsecase) SendMessage(ctx context.Context, userID primitive.Objec
 
 	// Broadcast new message via WebSocket
 	m.hub.BroadcastNewMessage(message, sender.Username)
+	m.notifyMentions(message, nil, sender.Username)
 
-	// Mark as delivered for online participants
-	go m.markAsDeliveredForOnlineUsers(ctx, message, chat.Participants)
+	if message.Type == entities.TextMessage {
+		if link := firstLink(message.Content); link != "" {
+			go m.attachLinkPreview(message.ID, message.Content, link)
+		}
+	}
 
 	return message, nil
 }
 
-func (m *MessageUsecase) GetChatMessages(ctx context.Context, chatID, userID primitive.ObjectID, limit, offset int) ([]*entities.MessageResponse, error) {
+func (m *MessageUsecase) GetChatMessages(ctx context.Context, chatID, userID primitive.ObjectID, req *entities.MessagePageRequest) (*entities.MessagePage, error) {
 	// Verify user is participant in chat
 	chat, err := m.chatRepo.GetByID(ctx, chatID)
 	if err != nil {
@@ -109,14 +262,72 @@ func (m *MessageUsecase) GetChatMessages(ctx context.Context, chatID, userID pri
 		return nil, errors.New("user is not a participant in this chat")
 	}
 
-	// Get messages from repository
-	messages, err := m.messageRepo.GetChatMessages(ctx, chatID, limit, offset)
+	limit := req.Limit
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+
+	// Messages the user cleared are left out by the query itself
+	cleared, err := m.clearRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the requested window of messages (newest first)
+	var messages []*entities.Message
+	var hasOlder, hasNewer bool
+
+	switch {
+	case req.Around != nil:
+		messages, hasOlder, hasNewer, err = m.getMessagesAround(ctx, chatID, cleared, *req.Around, limit)
+	case req.After != "":
+		cursor, cursorErr := decodeMessageCursor(req.After)
+		if cursorErr != nil {
+			return nil, cursorErr
+		}
+		messages, hasNewer, err = m.getMessagePage(ctx, chatID, cleared, cursor, repositories.PageNewer, limit)
+		hasOlder = true
+	case req.Before != "":
+		cursor, cursorErr := decodeMessageCursor(req.Before)
+		if cursorErr != nil {
+			return nil, cursorErr
+		}
+		messages, hasOlder, err = m.getMessagePage(ctx, chatID, cleared, cursor, repositories.PageOlder, limit)
+		hasNewer = true
+	default:
+		messages, hasOlder, err = m.getMessagePage(ctx, chatID, cleared, nil, repositories.PageOlder, limit)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	page := &entities.MessagePage{
+		Messages: []*entities.MessageResponse{},
+	}
+
+	// Cursors come from the raw page so hidden messages never break paging
+	if len(messages) > 0 {
+		if hasOlder {
+			page.NextCursor = encodeMessageCursor(messages[len(messages)-1])
+		}
+		if hasNewer {
+			page.PrevCursor = encodeMessageCursor(messages[0])
+		}
+	}
+
+	switch {
+	case req.Around != nil:
+		page.HasMore = hasOlder || hasNewer
+	case req.After != "":
+		page.HasMore = hasNewer
+	default:
+		page.HasMore = hasOlder
+	}
+
+	starred := m.getStarredIDs(ctx, userID, messages)
+	unreadReplies := m.getUnreadReplies(ctx, userID, messages)
+
 	// Convert to response format with additional information
-	var messageResponses []*entities.MessageResponse
 	for _, msg := range messages {
 		// Skip deleted messages for this user
 		if m.isDeletedForUser(msg, userID) {
@@ -124,22 +335,28 @@ func (m *MessageUsecase) GetChatMessages(ctx context.Context, chatID, userID pri
 		}
 
 		response := m.buildMessageResponse(ctx, msg, userID)
-		messageResponses = append(messageResponses, response)
+		response.IsStarred = starred[msg.ID]
+		response.UnreadReplies = unreadReplies[msg.ID]
+		page.Messages = append(page.Messages, response)
 	}
 
 	// Mark messages as read for this user
-	var messageIDs []primitive.ObjectID
+	var unread []*entities.Message
 	for _, msg := range messages {
 		if msg.SenderID != userID && !m.isReadByUser(msg, userID) {
-			messageIDs = append(messageIDs, msg.ID)
+			unread = append(unread, msg)
 		}
 	}
 
-	if len(messageIDs) > 0 {
-		go m.markMultipleAsRead(ctx, messageIDs, userID, chatID)
+	if len(unread) > 0 {
+		go func() {
+			if err := m.recordReceipts(context.Background(), userID, unread, entities.MessageRead); err != nil {
+				fmt.Printf("Failed to mark messages as read: %v", err)
+			}
+		}()
 	}
 
-	return messageResponses, nil
+	return page, nil
 }
 
 func (m *MessageUsecase) MarkAsRead(ctx context.Context, messageID, userID primitive.ObjectID) error {
@@ -159,25 +376,226 @@ func (m *MessageUsecase) MarkAsRead(ctx context.Context, messageID, userID primi
 		return errors.New("user is not a participant in this chat")
 	}
 
-	// Don't mark own messages as read
-	if message.SenderID == userID {
+	return m.recordReceipts(ctx, userID, []*entities.Message{message}, entities.MessageRead)
+}
+
+// ========== Delivery and Read Receipts ==========
+
+// MarkDelivered records that one of the user's devices received the
+// messages. It is called by the WebSocket hub when a client acknowledges them.
+func (m *MessageUsecase) MarkDelivered(userID primitive.ObjectID, messageIDs []primitive.ObjectID) error {
+	if len(messageIDs) > maxPageSize {
+		return errors.New("too many messages in one acknowledgement")
+	}
+
+	ctx := context.Background()
+
+	messages, err := m.messageRepo.GetByIDs(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+
+	// Only acknowledge messages from chats the user is still part of
+	chats := make(map[primitive.ObjectID]*entities.Chat)
+	var visible []*entities.Message
+	for _, msg := range messages {
+		chat, ok := chats[msg.ChatID]
+		if !ok {
+			chat, err = m.chatRepo.GetByID(ctx, msg.ChatID)
+			if err != nil {
+				chat = nil
+			}
+			chats[msg.ChatID] = chat
+		}
+		if chat != nil && m.isParticipant(userID, chat.Participants) {
+			visible = append(visible, msg)
+		}
+	}
+
+	return m.recordReceipts(ctx, userID, visible, entities.MessageDelivered)
+}
+
+// recordReceipts stores the user's delivery or read receipts, tells the chat
+// and moves each message's overall status forward. Reading a message also
+// counts as receiving it. Callers check the user may see the messages.
+func (m *MessageUsecase) recordReceipts(ctx context.Context, userID primitive.ObjectID, messages []*entities.Message, status entities.MessageStatus) error {
+	var pending []*entities.Message
+	for _, msg := range messages {
+		if msg.SenderID == userID {
+			continue
+		}
+		if status == entities.MessageRead && m.isReadByUser(msg, userID) {
+			continue
+		}
+		if status == entities.MessageDelivered && m.isDeliveredToUser(msg, userID) {
+			continue
+		}
+		pending = append(pending, msg)
+	}
+
+	if len(pending) == 0 {
 		return nil
 	}
 
-	// Mark as read
-	if err := m.messageRepo.MarkAsRead(ctx, messageID, userID); err != nil {
+	messageIDs := make([]primitive.ObjectID, len(pending))
+	for i, msg := range pending {
+		messageIDs[i] = msg.ID
+	}
+
+	var err error
+	if status == entities.MessageRead {
+		err = m.messageRepo.MarkMultipleAsRead(ctx, messageIDs, userID)
+	} else {
+		err = m.messageRepo.MarkMultipleAsDelivered(ctx, messageIDs, userID)
+	}
+	if err != nil {
 		return err
 	}
 
-	// Broadcast read status via WebSocket
-	m.hub.BroadcastMessageStatus(messageID, message.ChatID, userID, entities.MessageRead)
+	// Users who hide read receipts still report delivery
+	broadcastStatus := status
+	if status == entities.MessageRead && m.hidesReadReceipts(ctx, userID) {
+		broadcastStatus = entities.MessageDelivered
+	}
+	for _, msg := range pending {
+		if broadcastStatus == entities.MessageDelivered && m.isDeliveredToUser(msg, userID) {
+			continue
+		}
+		m.hub.BroadcastMessageStatus(msg.ID, msg.ChatID, userID, broadcastStatus)
+	}
 
+	m.refreshAggregateStatus(ctx, messageIDs)
 	return nil
 }
 
+// refreshAggregateStatus advances each message to delivered or read once
+// every current recipient has reached that state, and tells the sender.
+func (m *MessageUsecase) refreshAggregateStatus(ctx context.Context, messageIDs []primitive.ObjectID) {
+	messages, err := m.messageRepo.GetByIDs(ctx, messageIDs)
+	if err != nil {
+		fmt.Printf("Failed to load messages for status update: %v", err)
+		return
+	}
+
+	chats := make(map[primitive.ObjectID]*entities.Chat)
+	hidden := make(map[primitive.ObjectID]bool)
+	for _, msg := range messages {
+		if msg.Status == entities.MessageRead || msg.Status == entities.MessageFailed {
+			continue
+		}
+
+		chat, ok := chats[msg.ChatID]
+		if !ok {
+			chat, err = m.chatRepo.GetByID(ctx, msg.ChatID)
+			if err != nil {
+				chat = nil
+			} else {
+				m.loadHiddenReadReceipts(ctx, chat.Participants, hidden)
+			}
+			chats[msg.ChatID] = chat
+		}
+		if chat == nil {
+			continue
+		}
+
+		status := aggregateStatus(msg, chat.Participants, hidden)
+		if messageStatusRank(status) <= messageStatusRank(msg.Status) {
+			continue
+		}
+
+		advanced, err := m.messageRepo.AdvanceStatus(ctx, msg.ID, status)
+		if err != nil {
+			fmt.Printf("Failed to update message status: %v", err)
+			continue
+		}
+		if advanced {
+			m.hub.NotifyAggregateStatus(msg.SenderID, msg.ID, msg.ChatID, status)
+		}
+	}
+}
+
+// aggregateStatus is the furthest state every participant other than the
+// sender has reached. A recipient who hides read receipts never counts as
+// having read, so the message stops at delivered.
+func aggregateStatus(message *entities.Message, participants []primitive.ObjectID, hidden map[primitive.ObjectID]bool) entities.MessageStatus {
+	delivered := make(map[primitive.ObjectID]bool, len(message.DeliveredTo))
+	for _, info := range message.DeliveredTo {
+		delivered[info.UserID] = true
+	}
+	read := make(map[primitive.ObjectID]bool, len(message.ReadBy))
+	for _, info := range message.ReadBy {
+		read[info.UserID] = true
+		delivered[info.UserID] = true
+	}
+
+	allDelivered, allRead := true, true
+	recipients := 0
+	for _, participantID := range participants {
+		if participantID == message.SenderID {
+			continue
+		}
+		recipients++
+		if !delivered[participantID] {
+			allDelivered = false
+		}
+		if !read[participantID] || hidden[participantID] {
+			allRead = false
+		}
+	}
+
+	switch {
+	case recipients == 0:
+		return entities.MessageSent
+	case allRead:
+		return entities.MessageRead
+	case allDelivered:
+		return entities.MessageDelivered
+	default:
+		return entities.MessageSent
+	}
+}
+
+func messageStatusRank(status entities.MessageStatus) int {
+	switch status {
+	case entities.MessageDelivered:
+		return 1
+	case entities.MessageRead:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (m *MessageUsecase) hidesReadReceipts(ctx context.Context, userID primitive.ObjectID) bool {
+	user, err := m.userRepo.GetByID(ctx, userID)
+	return err == nil && user.Privacy.HideReadReceipts
+}
+
+// loadHiddenReadReceipts adds the participants who hide read receipts to hidden.
+func (m *MessageUsecase) loadHiddenReadReceipts(ctx context.Context, participants []primitive.ObjectID, hidden map[primitive.ObjectID]bool) {
+	users, err := m.userRepo.GetByIDs(ctx, participants)
+	if err != nil {
+		fmt.Printf("Failed to load participants: %v", err)
+		return
+	}
+	for _, user := range users {
+		if user.Privacy.HideReadReceipts {
+			hidden[user.ID] = true
+		}
+	}
+}
+
 // ========== Message Reactions ==========
 
+// maxReactionsPerUser caps how many different emoji one user can put on a
+// single message.
+const maxReactionsPerUser = 20
+
 func (m *MessageUsecase) AddReaction(ctx context.Context, userID primitive.ObjectID, req *entities.MessageReactionRequest) error {
+	if !emoji.IsEmoji(string(req.Reaction)) {
+		return errors.New("reaction must be a single emoji")
+	}
+
 	// Get message to verify access
 	message, err := m.messageRepo.GetByID(ctx, req.MessageID)
 	if err != nil {
@@ -194,10 +612,28 @@ func (m *MessageUsecase) AddReaction(ctx context.Context, userID primitive.Objec
 		return errors.New("user is not a participant in this chat")
 	}
 
-	// Add reaction
-	if err := m.messageRepo.AddReaction(ctx, req.MessageID, userID, req.Reaction); err != nil {
+	if err := m.checkReactionAllowed(ctx, chat, req.Reaction); err != nil {
+		return err
+	}
+
+	// Add reaction. The repository enforces the per-user cap atomically.
+	added, err := m.messageRepo.AddReaction(ctx, req.MessageID, userID, req.Reaction, maxReactionsPerUser)
+	if err != nil {
 		return err
 	}
+	if !added {
+		// Reacting twice with the same emoji is a no-op
+		reactions, err := m.messageRepo.GetMessageReactions(ctx, req.MessageID)
+		if err != nil {
+			return err
+		}
+		for _, reaction := range reactions {
+			if reaction.UserID == userID && reaction.Reaction == req.Reaction {
+				return nil
+			}
+		}
+		return fmt.Errorf("you can add at most %d reactions to a message", maxReactionsPerUser)
+	}
 
 	// Get user info for broadcasting
 	user, err := m.userRepo.GetByID(ctx, userID)
@@ -211,7 +647,9 @@ func (m *MessageUsecase) AddReaction(ctx context.Context, userID primitive.Objec
 	return nil
 }
 
-func (m *MessageUsecase) RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID) error {
+// RemoveReaction removes one of the user's reactions, or every one of them
+// when reaction is empty.
+func (m *MessageUsecase) RemoveReaction(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType) error {
 	// Get message to verify access
 	message, err := m.messageRepo.GetByID(ctx, messageID)
 	if err != nil {
@@ -229,7 +667,7 @@ func (m *MessageUsecase) RemoveReaction(ctx context.Context, messageID, userID p
 	}
 
 	// Remove reaction
-	if err := m.messageRepo.RemoveReaction(ctx, messageID, userID); err != nil {
+	if err := m.messageRepo.RemoveReaction(ctx, messageID, userID, reaction); err != nil {
 		return err
 	}
 
@@ -240,11 +678,142 @@ func (m *MessageUsecase) RemoveReaction(ctx context.Context, messageID, userID p
 	}
 
 	// Broadcast reaction removal via WebSocket
-	m.hub.BroadcastMessageReaction(messageID, message.ChatID, userID, user.Username, "", "remove")
+	m.hub.BroadcastMessageReaction(messageID, message.ChatID, userID, user.Username, reaction, "remove")
+
+	return nil
+}
+
+// GetMessageReactions lists who reacted with what, grouped by emoji with
+// the most used first. Each group holds a page of users, newest first;
+// pass reaction to page through a single group.
+func (m *MessageUsecase) GetMessageReactions(ctx context.Context, messageID, userID primitive.ObjectID, reaction entities.ReactionType, limit, offset int) (*entities.MessageReactionsResponse, error) {
+	message, err := m.messageRepo.GetByID(ctx, messageID)
+	if err != nil || m.isDeletedForUser(message, userID) {
+		return nil, errors.New("message not found")
+	}
+
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	reactions, err := m.messageRepo.GetMessageReactions(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// Group by emoji, keeping the order each emoji was first used for ties
+	byReaction := make(map[entities.ReactionType][]entities.MessageReaction)
+	var order []entities.ReactionType
+	for _, r := range reactions {
+		if _, seen := byReaction[r.Reaction]; !seen {
+			order = append(order, r.Reaction)
+		}
+		byReaction[r.Reaction] = append(byReaction[r.Reaction], r)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(byReaction[order[i]]) > len(byReaction[order[j]])
+	})
+
+	response := &entities.MessageReactionsResponse{
+		MessageID: messageID,
+		Total:     len(reactions),
+		Groups:    []*entities.ReactionGroup{},
+	}
+
+	var userIDs []primitive.ObjectID
+	for _, groupReaction := range order {
+		if reaction != "" && groupReaction != reaction {
+			continue
+		}
+
+		group := byReaction[groupReaction]
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].AddedAt.After(group[j].AddedAt)
+		})
+
+		reactionGroup := &entities.ReactionGroup{
+			Reaction: groupReaction,
+			Count:    len(group),
+			Users:    []*entities.ReactionUser{},
+			HasMore:  offset+limit < len(group),
+		}
+		for i := offset; i < len(group) && i < offset+limit; i++ {
+			reactionGroup.Users = append(reactionGroup.Users, &entities.ReactionUser{
+				UserID:  group[i].UserID,
+				AddedAt: group[i].AddedAt,
+			})
+			userIDs = append(userIDs, group[i].UserID)
+		}
+
+		response.Groups = append(response.Groups, reactionGroup)
+	}
+
+	if len(userIDs) > 0 {
+		users, err := m.userRepo.GetByIDs(ctx, userIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		byID := make(map[primitive.ObjectID]*entities.User, len(users))
+		for _, user := range users {
+			byID[user.ID] = user
+		}
+		for _, group := range response.Groups {
+			for _, reactionUser := range group.Users {
+				if user, ok := byID[reactionUser.UserID]; ok {
+					reactionUser.Username = user.Username
+					reactionUser.Avatar = user.Avatar
+				}
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// checkReactionAllowed applies the deployment allowlist and, in groups, the
+// group's own allowlist. An empty list allows any emoji. Entries match with
+// or without the emoji presentation selector.
+func (m *MessageUsecase) checkReactionAllowed(ctx context.Context, chat *entities.Chat, reaction entities.ReactionType) error {
+	if len(m.reactionAllowlist) > 0 && !reactionListed(m.reactionAllowlist, string(reaction)) {
+		return errors.New("this reaction is not allowed")
+	}
+
+	if chat.Type == entities.GroupChat {
+		settings := m.groupSettings(ctx, chat)
+		if settings != nil && len(settings.AllowedReactions) > 0 {
+			allowed := make([]string, len(settings.AllowedReactions))
+			for i, r := range settings.AllowedReactions {
+				allowed[i] = string(r)
+			}
+			if !reactionListed(allowed, string(reaction)) {
+				return errors.New("this reaction is not allowed in this group")
+			}
+		}
+	}
 
 	return nil
 }
 
+func reactionListed(list []string, reaction string) bool {
+	reaction = emoji.Normalize(reaction)
+	return slices.ContainsFunc(list, func(entry string) bool {
+		return emoji.Normalize(entry) == reaction
+	})
+}
+
 // ========== Message Management ==========
 
 func (m *MessageUsecase) ForwardMessages(ctx context.Context, userID primitive.ObjectID, req *entities.ForwardMessageRequest) error {
@@ -306,63 +875,122 @@ func (m *MessageUsecase) DeleteMessage(ctx context.Context, userID primitive.Obj
 		return errors.New("user is not a participant in this chat")
 	}
 
-	// Check if user can delete for everyone (only message sender, within time limit)
-	canDeleteForEveryone := message.SenderID == userID &&
-		time.Since(message.CreatedAt) < 24*time.Hour // 24 hour limit
+	return m.deleteMessages(ctx, userID, chat, []*entities.Message{message}, req.DeleteForMe)
+}
 
-	if !req.DeleteForMe && !canDeleteForEveryone {
-		return errors.New("cannot delete message for everyone")
+// DeleteMessages deletes several messages of one chat at once and returns
+// the IDs deleted. Messages already deleted for everyone are skipped.
+func (m *MessageUsecase) DeleteMessages(ctx context.Context, userID primitive.ObjectID, req *entities.DeleteMessagesRequest) ([]primitive.ObjectID, error) {
+	if len(req.MessageIDs) == 0 {
+		return nil, errors.New("no messages to delete")
 	}
-
-	// Delete message
-	if err := m.messageRepo.SoftDeleteMessage(ctx, req.MessageID, userID, !req.DeleteForMe); err != nil {
-		return err
+	if len(req.MessageIDs) > entities.MaxBulkDeleteMessages {
+		return nil, fmt.Errorf("cannot delete more than %d messages at once", entities.MaxBulkDeleteMessages)
 	}
 
-	// Broadcast deletion if deleted for everyone
-	if !req.DeleteForMe {
-		// TODO: Broadcast message deletion to all chat participants
+	chat, err := m.chatRepo.GetByID(ctx, req.ChatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
 	}
 
-	return nil
-}
+	if !m.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
 
-func (m *MessageUsecase) EditMessage(ctx context.Context, messageID, userID primitive.ObjectID, newContent string) error {
-	// Get message to verify access
-	message, err := m.messageRepo.GetByID(ctx, messageID)
+	messages, err := m.messageRepo.GetByIDs(ctx, req.MessageIDs)
 	if err != nil {
-		return errors.New("message not found")
+		return nil, err
 	}
 
-	// Only sender can edit messages
-	if message.SenderID != userID {
-		return errors.New("only message sender can edit messages")
+	for _, message := range messages {
+		if message.ChatID != chat.ID {
+			return nil, errors.New("message not found")
+		}
 	}
 
-	// Only text messages can be edited
-	if message.Type != entities.TextMessage {
-		return errors.New("only text messages can be edited")
+	if err := m.deleteMessages(ctx, userID, chat, messages, req.DeleteForMe); err != nil {
+		return nil, err
 	}
 
-	// Time limit for editing (e.g., 24 hours)
-	if time.Since(message.CreatedAt) > 24*time.Hour {
-		return errors.New("message editing time limit exceeded")
+	deleted := make([]primitive.ObjectID, 0, len(messages))
+	for _, message := range messages {
+		deleted = append(deleted, message.ID)
+	}
+
+	return deleted, nil
+}
+
+// deleteMessages deletes messages of one chat for the user alone or, if
+// every one of them allows it, for everyone.
+func (m *MessageUsecase) deleteMessages(ctx context.Context, userID primitive.ObjectID, chat *entities.Chat, messages []*entities.Message, deleteForMe bool) error {
+	if len(messages) == 0 {
+		return nil
 	}
 
-	// Edit message
-	if err := m.messageRepo.EditMessage(ctx, messageID, newContent); err != nil {
+	if !deleteForMe {
+		for _, message := range messages {
+			if !m.canDeleteForEveryone(ctx, chat, message, userID) {
+				return errors.New("cannot delete message for everyone")
+			}
+		}
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	// Delete messages
+	if err := m.messageRepo.SoftDeleteMessages(ctx, messageIDs, userID, !deleteForMe); err != nil {
 		return err
 	}
 
-	// TODO: Broadcast message edit to chat participants
+	if deleteForMe {
+		// A message the user can no longer see should not stay starred
+		if err := m.starredRepo.UnstarMessages(ctx, userID, messageIDs); err != nil {
+			fmt.Printf("Failed to unstar deleted messages: %v", err)
+		}
+		return nil
+	}
+
+	// Stars and pins follow the messages away for every user
+	if err := m.starredRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+		fmt.Printf("Failed to remove stars for deleted messages: %v", err)
+	}
+	if err := m.pinnedRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+		fmt.Printf("Failed to remove pins for deleted messages: %v", err)
+	}
+	if err := m.pollRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+		fmt.Printf("Failed to remove poll votes for deleted messages: %v", err)
+	}
+
+	for _, message := range messages {
+		// The tombstone no longer references the upload, so drop the file too
+		m.deleteMessageMedia(ctx, message)
+
+		// Keep the chat list preview in step with the tombstone
+		if chat.LastMessage != nil && chat.LastMessage.ID == message.ID {
+			if err := m.chatRepo.UpdateLastMessage(ctx, chat.ID, tombstoneOf(message, userID)); err != nil {
+				fmt.Printf("Failed to update last message: %v", err)
+			}
+		}
+
+		// Broadcast message deletion to all chat participants
+		m.hub.BroadcastMessageDeleted(message.ID, message.ChatID, userID)
+	}
 
 	return nil
 }
 
-// ========== Search and Media ==========
+// ========== Clear Chat ==========
 
-func (m *MessageUsecase) SearchMessages(ctx context.Context, chatID, userID primitive.ObjectID, query string, limit int) ([]*entities.MessageResponse, error) {
-	// Verify user is participant in chat
+// ClearChat hides every message sent to the chat so far from the user
+// alone; other participants keep theirs. With KeepStarred the user's starred
+// messages stay, otherwise their stars go as with delete for me.
+//
+// Rather than marking each message, one marker per user and chat records
+// when it was cleared, and reads leave out what it covers.
+func (m *MessageUsecase) ClearChat(ctx context.Context, chatID, userID primitive.ObjectID, req *entities.ClearChatRequest) (*entities.ChatClear, error) {
 	chat, err := m.chatRepo.GetByID(ctx, chatID)
 	if err != nil {
 		return nil, errors.New("chat not found")
@@ -372,13 +1000,1801 @@ func (m *MessageUsecase) SearchMessages(ctx context.Context, chatID, userID prim
 		return nil, errors.New("user is not a participant in this chat")
 	}
 
-	// Search messages
-	messages, err := m.messageRepo.SearchMessagesInChat(ctx, chatID, query, limit)
-	if err != nil {
-		return nil, err
+	marker := &entities.ChatClear{
+		UserID:    userID,
+		ChatID:    chatID,
+		ClearedAt: time.Now(),
 	}
 
-	// Convert to response format
+	if req.KeepStarred {
+		stars, err := m.starredRepo.GetStarred(ctx, userID, []primitive.ObjectID{chatID}, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, star := range stars {
+			marker.KeptMessageIDs = append(marker.KeptMessageIDs, star.MessageID)
+		}
+	}
+
+	if err := m.clearRepo.Save(ctx, marker); err != nil {
+		return nil, err
+	}
+
+	if !req.KeepStarred {
+		if err := m.starredRepo.UnstarChat(ctx, userID, chatID); err != nil {
+			fmt.Printf("Failed to unstar cleared messages: %v", err)
+		}
+	}
+
+	// The user's other devices drop the history too
+	m.hub.NotifyChatCleared(userID, chatClearedPayload(marker))
+
+	return marker, nil
+}
+
+func chatClearedPayload(marker *entities.ChatClear) *websocket.ChatClearedPayload {
+	return &websocket.ChatClearedPayload{
+		ChatID:         marker.ChatID,
+		ClearedAt:      marker.ClearedAt,
+		KeptMessageIDs: marker.KeptMessageIDs,
+	}
+}
+
+func (m *MessageUsecase) EditMessage(ctx context.Context, messageID, userID primitive.ObjectID, newContent string) error {
+	// Get message to verify access
+	message, err := m.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return errors.New("message not found")
+	}
+
+	// Only sender can edit messages
+	if message.SenderID != userID {
+		return errors.New("only message sender can edit messages")
+	}
+
+	// Only text messages can be edited
+	if message.Type != entities.TextMessage {
+		return errors.New("only text messages can be edited")
+	}
+
+	if newContent == "" {
+		return errors.New("text message content cannot be empty")
+	}
+
+	// Edits are only allowed for a limited time after sending
+	if m.editWindow > 0 && time.Since(message.CreatedAt) > m.editWindow {
+		return errors.New("message editing time limit exceeded")
+	}
+
+	if message.Content == newContent {
+		return nil
+	}
+
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	mentions := m.resolveMentions(ctx, newContent, chat.Participants)
+
+	// Edit message, archiving the previous revision
+	plainText, textEntities := formatContent(message.Type, newContent)
+	edited, err := m.messageRepo.EditMessage(ctx, messageID, newContent, plainText, textEntities, mentions)
+	if err != nil {
+		return err
+	}
+
+	// Broadcast message edit to chat participants
+	m.hub.BroadcastMessageEdited(edited)
+
+	// Only people newly mentioned by the edit are notified
+	senderName := "Unknown"
+	if sender, err := m.userRepo.GetByID(ctx, userID); err == nil {
+		senderName = sender.Username
+	}
+	m.notifyMentions(edited, message.Mentions, senderName)
+
+	m.refreshLinkPreview(ctx, edited)
+
+	return nil
+}
+
+func (m *MessageUsecase) GetEditHistory(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.MessageEditHistory, error) {
+	// Get message to verify access
+	message, err := m.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, errors.New("message not found")
+	}
+
+	// Verify user is participant in the chat
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	if m.isDeletedForUser(message, userID) {
+		return nil, errors.New("message not found")
+	}
+
+	revisions, err := m.messageRepo.GetMessageRevisions(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The live content is the latest revision
+	current := entities.MessageRevision{
+		MessageID: message.ID,
+		Revision:  message.EditCount,
+		Content:   message.Content,
+		CreatedAt: message.CreatedAt,
+	}
+	if message.EditedAt != nil {
+		current.CreatedAt = *message.EditedAt
+	}
+
+	return &entities.MessageEditHistory{
+		MessageID:       message.ID,
+		CurrentRevision: message.EditCount,
+		Revisions:       append(revisions, current),
+	}, nil
+}
+
+// ========== Starred Messages ==========
+
+func (m *MessageUsecase) StarMessage(ctx context.Context, messageID, userID primitive.ObjectID) error {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	return m.starredRepo.Star(ctx, &entities.StarredMessage{
+		UserID:    userID,
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+	})
+}
+
+func (m *MessageUsecase) UnstarMessage(ctx context.Context, messageID, userID primitive.ObjectID) error {
+	// Always allowed, so stale stars can be cleared even after access is lost
+	return m.starredRepo.Unstar(ctx, userID, messageID)
+}
+
+// GetStarredMessages lists the user's stars across all chats, newest star
+// first. Messages the user can no longer see are left out.
+func (m *MessageUsecase) GetStarredMessages(ctx context.Context, userID primitive.ObjectID, chatID *primitive.ObjectID, limit, offset int) ([]*entities.StarredMessageResponse, error) {
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	chats, err := m.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	for _, chat := range chats {
+		if chatID == nil || chat.ID == *chatID {
+			chatIDs = append(chatIDs, chat.ID)
+		}
+	}
+
+	if chatID != nil && len(chatIDs) == 0 {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	responses := []*entities.StarredMessageResponse{}
+	if len(chatIDs) == 0 {
+		return responses, nil
+	}
+
+	// Chats the user left and deleted messages are filtered out before
+	// paging
+	stars, err := m.starredRepo.GetStarred(ctx, userID, chatIDs, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(stars))
+	for _, star := range stars {
+		messageIDs = append(messageIDs, star.MessageID)
+	}
+
+	messages, err := m.messageRepo.GetByIDs(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	messagesByID := make(map[primitive.ObjectID]*entities.Message, len(messages))
+	for _, message := range messages {
+		messagesByID[message.ID] = message
+	}
+
+	for _, star := range stars {
+		message, ok := messagesByID[star.MessageID]
+		if !ok {
+			continue
+		}
+
+		response := m.buildMessageResponse(ctx, message, userID)
+		response.IsStarred = true
+		responses = append(responses, &entities.StarredMessageResponse{
+			MessageResponse: response,
+			StarredAt:       star.CreatedAt,
+		})
+	}
+
+	return responses, nil
+}
+
+// ========== Polls ==========
+
+// VotePoll casts the user's ballot, replacing any earlier one.
+func (m *MessageUsecase) VotePoll(ctx context.Context, messageID, userID primitive.ObjectID, req *entities.PollVoteRequest) (*entities.PollResults, error) {
+	message, err := m.getOpenPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.OptionIDs) == 0 {
+		return nil, errors.New("choose at least one option")
+	}
+	if !message.Poll.MultipleChoice && len(req.OptionIDs) > 1 {
+		return nil, errors.New("this poll allows only one choice")
+	}
+
+	valid := make(map[string]bool)
+	for _, option := range message.Poll.Options {
+		valid[option.ID] = true
+	}
+
+	var optionIDs []string
+	chosen := make(map[string]bool)
+	for _, optionID := range req.OptionIDs {
+		if !valid[optionID] {
+			return nil, errors.New("invalid poll option")
+		}
+		if !chosen[optionID] {
+			chosen[optionID] = true
+			optionIDs = append(optionIDs, optionID)
+		}
+	}
+
+	if err := m.pollRepo.SetVote(ctx, messageID, userID, optionIDs); err != nil {
+		return nil, err
+	}
+
+	return m.publishPollResults(ctx, message, userID)
+}
+
+func (m *MessageUsecase) RetractPollVote(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.PollResults, error) {
+	message, err := m.getOpenPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := m.pollRepo.RemoveVote(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, errors.New("you have not voted in this poll")
+	}
+
+	return m.publishPollResults(ctx, message, userID)
+}
+
+// ClosePoll stops voting. Only the poll's creator can close it.
+func (m *MessageUsecase) ClosePoll(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.PollResults, error) {
+	message, err := m.getOpenPoll(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.SenderID != userID {
+		return nil, errors.New("only the poll creator can close it")
+	}
+
+	closed, err := m.pollRepo.ClosePoll(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, errors.New("poll is already closed")
+	}
+
+	now := time.Now()
+	message.Poll.IsClosed = true
+	message.Poll.ClosedAt = &now
+
+	return m.publishPollResults(ctx, message, userID)
+}
+
+func (m *MessageUsecase) GetPollResults(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.PollResults, error) {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Type != entities.PollMessage || message.Poll == nil {
+		return nil, errors.New("message is not a poll")
+	}
+
+	votes, err := m.pollRepo.GetVotes(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tallyPoll(message, votes, &userID), nil
+}
+
+// getOpenPoll loads a poll the user can vote in. getVisibleMessage already
+// limits this to chat participants.
+func (m *MessageUsecase) getOpenPoll(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.Message, error) {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Type != entities.PollMessage || message.Poll == nil {
+		return nil, errors.New("message is not a poll")
+	}
+	if message.Poll.IsClosed {
+		return nil, errors.New("poll is closed")
+	}
+
+	return message, nil
+}
+
+// publishPollResults broadcasts the new tally to the chat and returns the
+// caller's own view of it.
+func (m *MessageUsecase) publishPollResults(ctx context.Context, message *entities.Message, userID primitive.ObjectID) (*entities.PollResults, error) {
+	votes, err := m.pollRepo.GetVotes(ctx, message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.hub.BroadcastPollUpdated(tallyPoll(message, votes, nil))
+
+	return tallyPoll(message, votes, &userID), nil
+}
+
+// tallyPoll counts votes per option. Voter IDs are included only for named
+// polls; viewerID, when set, fills in that user's own choices.
+func tallyPoll(message *entities.Message, votes []*entities.PollVote, viewerID *primitive.ObjectID) *entities.PollResults {
+	results := &entities.PollResults{
+		MessageID:   message.ID,
+		ChatID:      message.ChatID,
+		Question:    message.Poll.Question,
+		Options:     make([]entities.PollOptionResult, len(message.Poll.Options)),
+		TotalVoters: len(votes),
+		Anonymous:   message.Poll.Anonymous,
+		IsClosed:    message.Poll.IsClosed,
+	}
+
+	index := make(map[string]int)
+	for i, option := range message.Poll.Options {
+		results.Options[i] = entities.PollOptionResult{ID: option.ID, Text: option.Text}
+		index[option.ID] = i
+	}
+
+	for _, vote := range votes {
+		for _, optionID := range vote.OptionIDs {
+			i, ok := index[optionID]
+			if !ok {
+				continue
+			}
+			results.Options[i].Votes++
+			if !message.Poll.Anonymous {
+				results.Options[i].Voters = append(results.Options[i].Voters, vote.UserID)
+			}
+		}
+
+		if viewerID != nil && vote.UserID == *viewerID {
+			results.MyVotes = vote.OptionIDs
+		}
+	}
+
+	return results
+}
+
+func validatePoll(poll *entities.CreatePollRequest) error {
+	if poll == nil {
+		return errors.New("poll message must include a poll")
+	}
+
+	if strings.TrimSpace(poll.Question) == "" {
+		return errors.New("poll question cannot be empty")
+	}
+
+	if len(poll.Options) < entities.MinPollOptions || len(poll.Options) > entities.MaxPollOptions {
+		return fmt.Errorf("poll must have between %d and %d options", entities.MinPollOptions, entities.MaxPollOptions)
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range poll.Options {
+		text := strings.TrimSpace(option)
+		if text == "" {
+			return errors.New("poll options cannot be empty")
+		}
+		if seen[text] {
+			return errors.New("poll options must be unique")
+		}
+		seen[text] = true
+	}
+
+	return nil
+}
+
+func buildPoll(req *entities.CreatePollRequest) *entities.Poll {
+	poll := &entities.Poll{
+		Question:       strings.TrimSpace(req.Question),
+		Options:        make([]entities.PollOption, 0, len(req.Options)),
+		MultipleChoice: req.MultipleChoice,
+		Anonymous:      req.Anonymous,
+	}
+
+	for i, option := range req.Options {
+		poll.Options = append(poll.Options, entities.PollOption{
+			ID:   strconv.Itoa(i + 1),
+			Text: strings.TrimSpace(option),
+		})
+	}
+
+	return poll
+}
+
+// ========== Live Location ==========
+
+// UpdateLiveLocation accepts a position streamed over the WebSocket. It
+// implements websocket.LiveLocationHandler and fills in the chat and sender
+// so the hub can fan the update out.
+func (m *MessageUsecase) UpdateLiveLocation(userID primitive.ObjectID, update *websocket.LocationUpdatePayload) error {
+	if err := validateCoordinates(update.Latitude, update.Longitude, update.Accuracy); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	message, err := m.messageRepo.UpdateLiveLocation(context.Background(), update.MessageID, userID, update.Latitude, update.Longitude, update.Accuracy, now)
+	if err != nil {
+		return errors.New("live location session not found or has ended")
+	}
+
+	update.ChatID = message.ChatID
+	update.SenderID = userID
+	update.UpdatedAt = now
+
+	return nil
+}
+
+// StopLiveLocation ends the sender's live location session early.
+func (m *MessageUsecase) StopLiveLocation(ctx context.Context, messageID, userID primitive.ObjectID) error {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	if message.SenderID != userID {
+		return errors.New("only the sender can stop sharing their location")
+	}
+
+	if message.Location == nil || message.Location.Live == nil || !message.Location.Live.IsActive {
+		return errors.New("live location is not active")
+	}
+
+	now := time.Now()
+	ended, err := m.messageRepo.EndLiveLocation(ctx, messageID, now)
+	if err != nil {
+		return err
+	}
+	if ended {
+		m.hub.BroadcastLiveLocationEnded(message.ID, message.ChatID, now)
+	}
+
+	return nil
+}
+
+func (m *MessageUsecase) endExpiredLiveLocations(ctx context.Context) {
+	for {
+		messages, err := m.messageRepo.GetExpiredLiveLocations(ctx, time.Now(), reaperBatchSize)
+		if err != nil {
+			fmt.Printf("Failed to load expired live locations: %v", err)
+			return
+		}
+
+		ended := 0
+		for _, message := range messages {
+			endedAt := message.Location.Live.ExpiresAt
+			removed, err := m.messageRepo.EndLiveLocation(ctx, message.ID, endedAt)
+			if err != nil {
+				fmt.Printf("Failed to end live location %s: %v", message.ID.Hex(), err)
+				continue
+			}
+			if removed {
+				m.hub.BroadcastLiveLocationEnded(message.ID, message.ChatID, endedAt)
+			}
+			ended++
+		}
+
+		if ended == 0 || len(messages) < reaperBatchSize {
+			return
+		}
+	}
+}
+
+func validateLocation(location *entities.LocationRequest) error {
+	if location == nil {
+		return errors.New("location message must include a location")
+	}
+
+	if err := validateCoordinates(location.Latitude, location.Longitude, location.Accuracy); err != nil {
+		return err
+	}
+
+	if len(location.PlaceName) > entities.MaxPlaceNameLength || len(location.Address) > entities.MaxPlaceNameLength {
+		return fmt.Errorf("place name and address must be at most %d characters", entities.MaxPlaceNameLength)
+	}
+
+	if location.LiveDuration != 0 && !entities.LiveLocationDurations[location.LiveDuration] {
+		return errors.New("live location duration must be 15 minutes, 1 hour or 8 hours")
+	}
+
+	return nil
+}
+
+func validateCoordinates(latitude, longitude, accuracy float64) error {
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	if math.IsNaN(accuracy) || accuracy < 0 {
+		return errors.New("accuracy cannot be negative")
+	}
+	return nil
+}
+
+func buildLocation(req *entities.LocationRequest, now time.Time) *entities.Location {
+	location := &entities.Location{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Accuracy:  req.Accuracy,
+		PlaceName: strings.TrimSpace(req.PlaceName),
+		Address:   strings.TrimSpace(req.Address),
+	}
+
+	if req.LiveDuration > 0 {
+		location.Live = &entities.LiveLocation{
+			IsActive:  true,
+			StartedAt: now,
+			ExpiresAt: now.Add(time.Duration(req.LiveDuration) * time.Second),
+			UpdatedAt: now,
+		}
+	}
+
+	return location
+}
+
+func locationPreview(location *entities.Location) string {
+	if location.Live != nil {
+		return "Live location"
+	}
+	if location.PlaceName != "" {
+		return location.PlaceName
+	}
+	return "Location"
+}
+
+// ========== Message Info ==========
+
+// GetMessageInfo lists when each recipient got and read the message. Only
+// the sender can see it.
+func (m *MessageUsecase) GetMessageInfo(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.MessageInfo, error) {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.SenderID != userID {
+		return nil, errors.New("only the sender can view message info")
+	}
+
+	if message.Type == entities.SystemMessage {
+		return nil, errors.New("system messages have no delivery info")
+	}
+
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	recipientIDs := make([]primitive.ObjectID, 0, len(chat.Participants))
+	for _, participantID := range chat.Participants {
+		if participantID != message.SenderID {
+			recipientIDs = append(recipientIDs, participantID)
+		}
+	}
+
+	users := map[primitive.ObjectID]*entities.User{}
+	if len(recipientIDs) > 0 {
+		found, err := m.userRepo.GetByIDs(ctx, recipientIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range found {
+			users[user.ID] = user
+		}
+	}
+
+	deliveredAt := make(map[primitive.ObjectID]time.Time, len(message.DeliveredTo))
+	for _, delivery := range message.DeliveredTo {
+		deliveredAt[delivery.UserID] = delivery.DeliveredAt
+	}
+	readAt := make(map[primitive.ObjectID]time.Time, len(message.ReadBy))
+	for _, read := range message.ReadBy {
+		readAt[read.UserID] = read.ReadAt
+	}
+
+	info := &entities.MessageInfo{
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		SentAt:    message.CreatedAt,
+		Read:      []*entities.MessageRecipientInfo{},
+		Delivered: []*entities.MessageRecipientInfo{},
+		Pending:   []*entities.MessageRecipientInfo{},
+	}
+
+	for _, recipientID := range recipientIDs {
+		user, ok := users[recipientID]
+		if !ok {
+			continue
+		}
+
+		recipient := &entities.MessageRecipientInfo{
+			UserID:    user.ID,
+			Username:  user.Username,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Avatar:    user.Avatar,
+		}
+
+		delivered, isDelivered := deliveredAt[recipientID]
+		read, isRead := readAt[recipientID]
+
+		// Reading implies delivery even if the delivery receipt was missed
+		if !isDelivered && isRead {
+			delivered, isDelivered = read, true
+		}
+		if isDelivered {
+			recipient.DeliveredAt = &delivered
+		}
+
+		switch {
+		case isRead && !user.Privacy.HideReadReceipts:
+			recipient.ReadAt = &read
+			info.Read = append(info.Read, recipient)
+		case isDelivered:
+			info.Delivered = append(info.Delivered, recipient)
+		default:
+			info.Pending = append(info.Pending, recipient)
+		}
+	}
+
+	// Most recent first, as in the chat apps people are used to
+	sort.SliceStable(info.Read, func(i, j int) bool {
+		return info.Read[i].ReadAt.After(*info.Read[j].ReadAt)
+	})
+	sort.SliceStable(info.Delivered, func(i, j int) bool {
+		return info.Delivered[i].DeliveredAt.After(*info.Delivered[j].DeliveredAt)
+	})
+
+	return info, nil
+}
+
+// ========== Drafts ==========
+
+func (m *MessageUsecase) GetDraft(ctx context.Context, chatID, userID primitive.ObjectID) (*entities.MessageDraft, error) {
+	if err := m.verifyChatAccess(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	draft, err := m.draftRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, errors.New("no draft for this chat")
+	}
+
+	return draft, nil
+}
+
+// SaveDraft stores the draft and syncs it to all of the user's devices. It
+// returns nil when the request cleared the draft.
+func (m *MessageUsecase) SaveDraft(ctx context.Context, chatID, userID primitive.ObjectID, req *entities.SaveDraftRequest) (*entities.MessageDraft, error) {
+	update := &websocket.DraftPayload{
+		ChatID:    chatID,
+		Content:   req.Content,
+		ReplyToID: req.ReplyToID,
+	}
+
+	draft, err := m.storeDraft(ctx, userID, update)
+	if err != nil {
+		return nil, err
+	}
+
+	m.hub.NotifyDraftUpdated(userID, update, nil)
+
+	return draft, nil
+}
+
+func (m *MessageUsecase) DeleteDraft(ctx context.Context, chatID, userID primitive.ObjectID) error {
+	if err := m.verifyChatAccess(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	m.clearDraft(ctx, userID, chatID)
+	return nil
+}
+
+// SyncDraft stores a draft sent over the WebSocket; the hub forwards it to
+// the user's other connections.
+func (m *MessageUsecase) SyncDraft(userID primitive.ObjectID, update *websocket.DraftPayload) error {
+	_, err := m.storeDraft(context.Background(), userID, update)
+	return err
+}
+
+// storeDraft validates and saves the draft, or deletes it when it is empty.
+// It fills in update.Cleared and update.UpdatedAt for the sync event.
+func (m *MessageUsecase) storeDraft(ctx context.Context, userID primitive.ObjectID, update *websocket.DraftPayload) (*entities.MessageDraft, error) {
+	if err := m.verifyChatAccess(ctx, update.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	if len(update.Content) > entities.MaxDraftLength {
+		return nil, fmt.Errorf("draft cannot be longer than %d bytes", entities.MaxDraftLength)
+	}
+
+	if update.ReplyToID != nil {
+		parent, err := m.getVisibleMessage(ctx, *update.ReplyToID, userID)
+		if err != nil || parent.ChatID != update.ChatID {
+			return nil, errors.New("replied message not found")
+		}
+	}
+
+	update.UpdatedAt = time.Now()
+
+	if strings.TrimSpace(update.Content) == "" && update.ReplyToID == nil {
+		if _, err := m.draftRepo.Delete(ctx, userID, update.ChatID); err != nil {
+			return nil, err
+		}
+		update.Content = ""
+		update.Cleared = true
+		return nil, nil
+	}
+
+	draft := &entities.MessageDraft{
+		UserID:    userID,
+		ChatID:    update.ChatID,
+		Content:   update.Content,
+		ReplyToID: update.ReplyToID,
+		UpdatedAt: update.UpdatedAt,
+	}
+	if err := m.draftRepo.Save(ctx, draft); err != nil {
+		return nil, err
+	}
+
+	return draft, nil
+}
+
+// clearDraft removes the user's draft for a chat and tells their devices.
+func (m *MessageUsecase) clearDraft(ctx context.Context, userID, chatID primitive.ObjectID) {
+	deleted, err := m.draftRepo.Delete(ctx, userID, chatID)
+	if err != nil {
+		fmt.Printf("Failed to clear draft: %v", err)
+		return
+	}
+
+	if deleted {
+		m.hub.NotifyDraftUpdated(userID, &websocket.DraftPayload{
+			ChatID:    chatID,
+			Cleared:   true,
+			UpdatedAt: time.Now(),
+		}, nil)
+	}
+}
+
+func (m *MessageUsecase) verifyChatAccess(ctx context.Context, chatID, userID primitive.ObjectID) error {
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return errors.New("user is not a participant in this chat")
+	}
+
+	return nil
+}
+
+// ========== Link Previews ==========
+
+// attachLinkPreview fetches the preview in the background and pushes the
+// updated message to the chat once it is ready.
+func (m *MessageUsecase) attachLinkPreview(messageID primitive.ObjectID, content, link string) {
+	ctx, cancel := context.WithTimeout(context.Background(), linkPreviewDeadline)
+	defer cancel()
+
+	preview, err := m.linkPreviews.GetPreview(ctx, link)
+	if err != nil || preview == nil {
+		return
+	}
+
+	updated, err := m.messageRepo.SetLinkPreview(ctx, messageID, content, preview)
+	if err != nil {
+		fmt.Printf("Failed to attach link preview: %v", err)
+		return
+	}
+	if updated != nil {
+		m.hub.BroadcastMessageUpdated(updated)
+	}
+}
+
+// refreshLinkPreview drops a preview that no longer matches the edited
+// content and fetches one for the new link, if any.
+func (m *MessageUsecase) refreshLinkPreview(ctx context.Context, message *entities.Message) {
+	link := firstLink(message.Content)
+	if message.LinkPreview != nil && message.LinkPreview.URL == link {
+		return
+	}
+
+	if message.LinkPreview != nil {
+		updated, err := m.messageRepo.SetLinkPreview(ctx, message.ID, message.Content, nil)
+		if err != nil {
+			fmt.Printf("Failed to remove link preview: %v", err)
+		} else if updated != nil {
+			m.hub.BroadcastMessageUpdated(updated)
+		}
+	}
+
+	if link != "" {
+		go m.attachLinkPreview(message.ID, message.Content, link)
+	}
+}
+
+// ========== Contact Cards ==========
+
+// buildContact cleans up a contact card and links it to a registered user
+// when one of its phone numbers or e-mail addresses matches.
+func (m *MessageUsecase) buildContact(ctx context.Context, req *entities.ContactCard) *entities.ContactCard {
+	contact := &entities.ContactCard{
+		Name:         strings.TrimSpace(req.Name),
+		FirstName:    strings.TrimSpace(req.FirstName),
+		LastName:     strings.TrimSpace(req.LastName),
+		Organization: strings.TrimSpace(req.Organization),
+	}
+
+	for _, phone := range req.Phones {
+		if value := strings.TrimSpace(phone.Value); value != "" {
+			contact.Phones = append(contact.Phones, entities.ContactField{Value: value, Type: strings.ToLower(strings.TrimSpace(phone.Type))})
+		}
+	}
+	for _, email := range req.Emails {
+		if value := strings.TrimSpace(email.Value); value != "" {
+			contact.Emails = append(contact.Emails, entities.ContactField{Value: value, Type: strings.ToLower(strings.TrimSpace(email.Type))})
+		}
+	}
+
+	if contact.Name == "" {
+		contact.Name = strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	}
+	if contact.Name == "" && len(contact.Phones) > 0 {
+		contact.Name = contact.Phones[0].Value
+	}
+	if contact.Name == "" && len(contact.Emails) > 0 {
+		contact.Name = contact.Emails[0].Value
+	}
+
+	// Never trust a client-supplied user ID; match it ourselves
+	contact.UserID = m.matchContactUser(ctx, contact)
+
+	return contact
+}
+
+func (m *MessageUsecase) matchContactUser(ctx context.Context, contact *entities.ContactCard) *primitive.ObjectID {
+	for _, email := range contact.Emails {
+		for _, candidate := range []string{email.Value, strings.ToLower(email.Value)} {
+			if user, err := m.userRepo.GetByEmail(ctx, candidate); err == nil {
+				return &user.ID
+			}
+		}
+	}
+
+	for _, phone := range contact.Phones {
+		forms := []string{phone.Value}
+		if normalized := normalizePhone(phone.Value); normalized != "" && normalized != phone.Value {
+			forms = append(forms, normalized)
+		}
+		if user, err := m.userRepo.GetByPhone(ctx, forms); err == nil {
+			return &user.ID
+		}
+	}
+
+	return nil
+}
+
+func validateContact(contact *entities.ContactCard) error {
+	if contact == nil {
+		return errors.New("contact message must include a contact")
+	}
+
+	if len(contact.Phones) == 0 && len(contact.Emails) == 0 {
+		return errors.New("contact must have at least one phone number or email")
+	}
+
+	if len(contact.Phones) > entities.MaxContactFields || len(contact.Emails) > entities.MaxContactFields {
+		return fmt.Errorf("contact can have at most %d phone numbers and %d emails", entities.MaxContactFields, entities.MaxContactFields)
+	}
+
+	// Types are written into vCards as parameters, so they must be tokens
+	for _, phone := range contact.Phones {
+		if len(normalizePhone(phone.Value)) < 3 {
+			return fmt.Errorf("invalid phone number %q", phone.Value)
+		}
+		if phone.Type != "" && !vcard.IsValidType(phone.Type) {
+			return fmt.Errorf("invalid phone type %q", phone.Type)
+		}
+	}
+
+	for _, email := range contact.Emails {
+		at := strings.Index(email.Value, "@")
+		if at <= 0 || at == len(email.Value)-1 {
+			return fmt.Errorf("invalid email %q", email.Value)
+		}
+		if email.Type != "" && !vcard.IsValidType(email.Type) {
+			return fmt.Errorf("invalid email type %q", email.Type)
+		}
+	}
+
+	return nil
+}
+
+// normalizePhone keeps the digits and a leading plus sign.
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if r == '+' && i == 0 || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ========== Mentions ==========
+
+// GetMentions returns messages that mention the user across the chats they
+// are still in, newest first.
+func (m *MessageUsecase) GetMentions(ctx context.Context, userID primitive.ObjectID, limit, offset int) ([]*entities.MessageResponse, error) {
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	chats, err := m.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	messageResponses := []*entities.MessageResponse{}
+	if len(chats) == 0 {
+		return messageResponses, nil
+	}
+
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	for _, chat := range chats {
+		chatIDs = append(chatIDs, chat.ID)
+	}
+
+	clears, err := m.clearRepo.GetForChats(ctx, userID, chatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := m.messageRepo.GetMentions(ctx, userID, chatIDs, clears, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range messages {
+		messageResponses = append(messageResponses, m.buildMessageResponse(ctx, msg, userID))
+	}
+
+	return messageResponses, nil
+}
+
+// resolveMentions maps @username tokens to chat participants. Names that
+// don't belong to a participant are ignored.
+func (m *MessageUsecase) resolveMentions(ctx context.Context, content string, participants []primitive.ObjectID) []primitive.ObjectID {
+	var mentions []primitive.ObjectID
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		// Trailing punctuation ends a sentence rather than a username
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+
+		user, err := m.userRepo.GetByUsername(ctx, username)
+		if err != nil || !m.isParticipant(user.ID, participants) {
+			continue
+		}
+		if !m.containsID(mentions, user.ID) {
+			mentions = append(mentions, user.ID)
+		}
+	}
+
+	return mentions
+}
+
+// notifyMentions sends a mention event to everyone mentioned in the message
+// except the sender and anyone in alreadyNotified.
+func (m *MessageUsecase) notifyMentions(message *entities.Message, alreadyNotified []primitive.ObjectID, senderName string) {
+	for _, userID := range message.Mentions {
+		if userID == message.SenderID || m.containsID(alreadyNotified, userID) {
+			continue
+		}
+		m.hub.NotifyMention(userID, message, senderName)
+	}
+}
+
+func (m *MessageUsecase) containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// ========== Threads ==========
+
+// GetThread returns a message and every reply below it, oldest first. Viewing
+// a thread clears the user's unread-reply indicator for it.
+func (m *MessageUsecase) GetThread(ctx context.Context, messageID, userID primitive.ObjectID, req *entities.ThreadPageRequest) (*entities.ThreadPage, error) {
+	// Replies stay reachable below a message deleted for everyone
+	root, err := m.messageRepo.GetByIDIncludingDeleted(ctx, messageID)
+	if err != nil {
+		return nil, errors.New("message not found")
+	}
+	if err := m.checkMessageAccess(ctx, root, userID); err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	var cursor *entities.MessageCursor
+	if req.After != "" {
+		cursor, err = decodeMessageCursor(req.After)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Fetch one extra reply to find out whether another page exists
+	replies, err := m.messageRepo.GetThreadReplies(ctx, root.ID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entities.ThreadPage{
+		Replies: []*entities.MessageResponse{},
+	}
+
+	if len(replies) > limit {
+		replies = replies[:limit]
+		page.HasMore = true
+	}
+	if len(replies) > 0 {
+		page.NextCursor = encodeMessageCursor(replies[len(replies)-1])
+	}
+
+	page.Root = m.buildMessageResponse(ctx, root, userID)
+	for _, reply := range replies {
+		if m.isDeletedForUser(reply, userID) {
+			continue
+		}
+		page.Replies = append(page.Replies, m.buildMessageResponse(ctx, reply, userID))
+	}
+
+	// Subscriptions live on the top-level root, whichever branch is viewed
+	threadRootID := root.ID
+	if len(root.ThreadPath) > 0 {
+		threadRootID = root.ThreadPath[0]
+	}
+	if err := m.threadRepo.MarkRead(ctx, threadRootID, userID); err != nil {
+		fmt.Printf("Failed to mark thread as read: %v", err)
+	}
+
+	return page, nil
+}
+
+// recordThreadReply updates reply counts up the thread and notifies the
+// thread's participants. The root author and every replier take part.
+func (m *MessageUsecase) recordThreadReply(ctx context.Context, message *entities.Message) {
+	if err := m.messageRepo.IncrementReplyCount(ctx, message.ThreadPath, 1); err != nil {
+		fmt.Printf("Failed to update reply counts: %v", err)
+	}
+
+	rootID := message.ThreadPath[0]
+	if root, err := m.messageRepo.GetByID(ctx, rootID); err == nil {
+		if err := m.threadRepo.Subscribe(ctx, rootID, message.ChatID, root.SenderID); err != nil {
+			fmt.Printf("Failed to subscribe thread author: %v", err)
+		}
+	}
+
+	subscriptions, err := m.threadRepo.RecordReply(ctx, rootID, message.SenderID, message.CreatedAt)
+	if err != nil {
+		fmt.Printf("Failed to record thread reply: %v", err)
+	}
+	for _, subscription := range subscriptions {
+		m.hub.NotifyThreadReply(subscription, message)
+	}
+
+	if err := m.threadRepo.Subscribe(ctx, rootID, message.ChatID, message.SenderID); err != nil {
+		fmt.Printf("Failed to subscribe thread participant: %v", err)
+	}
+}
+
+func (m *MessageUsecase) getUnreadReplies(ctx context.Context, userID primitive.ObjectID, messages []*entities.Message) map[primitive.ObjectID]int {
+	var rootIDs []primitive.ObjectID
+	for _, msg := range messages {
+		if msg.ReplyCount > 0 && len(msg.ThreadPath) == 0 {
+			rootIDs = append(rootIDs, msg.ID)
+		}
+	}
+
+	unread, err := m.threadRepo.GetUnreadCounts(ctx, userID, rootIDs)
+	if err != nil {
+		fmt.Printf("Failed to load unread thread replies: %v", err)
+		return map[primitive.ObjectID]int{}
+	}
+	return unread
+}
+
+// ========== Pinned Messages ==========
+
+func (m *MessageUsecase) PinMessage(ctx context.Context, messageID, userID primitive.ObjectID, req *entities.PinMessageRequest) (*entities.PinnedMessage, error) {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.IsDeleted || message.Type == entities.SystemMessage {
+		return nil, errors.New("this message cannot be pinned")
+	}
+
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !m.canPinMessages(ctx, chat, userID) {
+		return nil, errors.New("you don't have permission to pin messages in this chat")
+	}
+
+	pin := &entities.PinnedMessage{
+		ChatID:    chat.ID,
+		MessageID: message.ID,
+		PinnedBy:  userID,
+	}
+
+	if req.Duration != "" {
+		duration, ok := entities.PinDurations[req.Duration]
+		if !ok {
+			return nil, errors.New("pin duration must be one of 24h, 7d or 30d")
+		}
+		expiresAt := time.Now().Add(duration)
+		pin.ExpiresAt = &expiresAt
+	}
+
+	// Re-pinning only refreshes the expiry, so it doesn't count against the limit
+	alreadyPinned, err := m.pinnedRepo.IsPinned(ctx, chat.ID, message.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if !alreadyPinned {
+		pins, err := m.pinnedRepo.GetChatPins(ctx, chat.ID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if len(pins) >= m.maxPinnedMessages {
+			return nil, fmt.Errorf("a chat can have at most %d pinned messages", m.maxPinnedMessages)
+		}
+	}
+
+	added, err := m.pinnedRepo.Pin(ctx, pin)
+	if err != nil {
+		return nil, err
+	}
+
+	// Concurrent pins can all pass the check above, so a new pin is ranked
+	// again once stored and undone if it lands beyond the limit
+	if added {
+		rank, err := m.pinnedRepo.CountPinsUpTo(ctx, chat.ID, pin.ID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if rank > int64(m.maxPinnedMessages) {
+			if _, err := m.pinnedRepo.Unpin(ctx, chat.ID, message.ID); err != nil {
+				fmt.Printf("Failed to undo pin over the limit: %v", err)
+			}
+			return nil, fmt.Errorf("a chat can have at most %d pinned messages", m.maxPinnedMessages)
+		}
+	}
+
+	m.hub.BroadcastMessagePinned(pin)
+
+	if added {
+		m.notifyPinChanged(ctx, chat.ID, userID, "pinned")
+	}
+
+	return pin, nil
+}
+
+func (m *MessageUsecase) UnpinMessage(ctx context.Context, messageID, userID primitive.ObjectID) error {
+	message, err := m.getVisibleMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	if !m.canPinMessages(ctx, chat, userID) {
+		return errors.New("you don't have permission to unpin messages in this chat")
+	}
+
+	removed, err := m.pinnedRepo.Unpin(ctx, chat.ID, message.ID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errors.New("message is not pinned")
+	}
+
+	m.hub.BroadcastMessageUnpinned(message.ID, chat.ID, userID)
+	m.notifyPinChanged(ctx, chat.ID, userID, "unpinned")
+
+	return nil
+}
+
+// GetPinnedMessages returns the chat's active pins, most recently pinned first.
+func (m *MessageUsecase) GetPinnedMessages(ctx context.Context, chatID, userID primitive.ObjectID) ([]*entities.PinnedMessageResponse, error) {
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	pins, err := m.pinnedRepo.GetChatPins(ctx, chatID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []*entities.PinnedMessageResponse{}
+	for _, pin := range pins {
+		message, err := m.messageRepo.GetByID(ctx, pin.MessageID)
+		if err != nil || m.isDeletedForUser(message, userID) {
+			continue
+		}
+
+		responses = append(responses, &entities.PinnedMessageResponse{
+			PinnedMessage: pin,
+			Message:       m.buildMessageResponse(ctx, message, userID),
+		})
+	}
+
+	return responses, nil
+}
+
+// canPinMessages follows the group's WhoCanPinMessages setting; anyone in a
+// direct chat may pin.
+func (m *MessageUsecase) canPinMessages(ctx context.Context, chat *entities.Chat, userID primitive.ObjectID) bool {
+	if chat.Type != entities.GroupChat {
+		return true
+	}
+
+	// Without the settings we can't tell whether pinning is admin-only
+	groupInfo, err := m.groupRepo.GetGroupInfo(ctx, chat.ID)
+	if err != nil {
+		return false
+	}
+
+	if groupInfo.Settings != nil && groupInfo.Settings.WhoCanPinMessages == "admins" {
+		isAdmin, err := m.groupRepo.IsGroupAdmin(ctx, chat.ID, userID)
+		return err == nil && isAdmin
+	}
+
+	// Default: everyone can pin
+	return true
+}
+
+func (m *MessageUsecase) notifyPinChanged(ctx context.Context, chatID, actorID primitive.ObjectID, action string) {
+	actorName := "Someone"
+	if actor, err := m.userRepo.GetByID(ctx, actorID); err == nil {
+		actorName = actor.Username
+	}
+
+	content := fmt.Sprintf("%s %s a message.", actorName, action)
+	if _, err := m.PostSystemMessage(ctx, chatID, actorID, content); err != nil {
+		fmt.Printf("Failed to post pin notice: %v", err)
+	}
+}
+
+// ========== Disappearing Messages ==========
+
+func (m *MessageUsecase) SetDisappearingTimer(ctx context.Context, chatID, userID primitive.ObjectID, seconds int) error {
+	if seconds < 0 {
+		return errors.New("disappearing time cannot be negative")
+	}
+
+	// Verify user is participant in chat
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return errors.New("user is not a participant in this chat")
+	}
+
+	// Group timers follow GroupSettings and are changed through the group endpoints
+	if chat.Type == entities.GroupChat {
+		return errors.New("use group settings to change the disappearing timer of a group")
+	}
+
+	if chat.DisappearingTime == seconds {
+		return nil
+	}
+
+	if err := m.chatRepo.UpdateDisappearingTime(ctx, chatID, seconds); err != nil {
+		return err
+	}
+
+	m.NotifyDisappearingTimerChanged(ctx, chatID, userID, seconds)
+
+	return nil
+}
+
+// NotifyDisappearingTimerChanged posts the timeline notice shown when a
+// chat's disappearing messages timer is turned on, off or changed.
+func (m *MessageUsecase) NotifyDisappearingTimerChanged(ctx context.Context, chatID, actorID primitive.ObjectID, seconds int) {
+	actorName := "Someone"
+	if actor, err := m.userRepo.GetByID(ctx, actorID); err == nil {
+		actorName = actor.Username
+	}
+
+	var content string
+	if seconds > 0 {
+		content = fmt.Sprintf("%s turned on disappearing messages. New messages will disappear from this chat %s after they're sent.",
+			actorName, formatDisappearingTime(seconds))
+	} else {
+		content = fmt.Sprintf("%s turned off disappearing messages.", actorName)
+	}
+
+	if _, err := m.PostSystemMessage(ctx, chatID, actorID, content); err != nil {
+		fmt.Printf("Failed to post disappearing timer notice: %v", err)
+	}
+}
+
+// PostSystemMessage records a server-generated notice in the chat timeline
+// and broadcasts it like any other new message.
+func (m *MessageUsecase) PostSystemMessage(ctx context.Context, chatID, actorID primitive.ObjectID, content string) (*entities.Message, error) {
+	message := &entities.Message{
+		ChatID:      chatID,
+		SenderID:    actorID,
+		Type:        entities.SystemMessage,
+		Content:     content,
+		Status:      entities.MessageSent,
+		ReadBy:      []entities.ReadInfo{},
+		DeliveredTo: []entities.DeliveryInfo{},
+		Reactions:   []entities.MessageReaction{},
+	}
+
+	if err := m.messageRepo.Create(ctx, message); err != nil {
+		return nil, err
+	}
+
+	if err := m.chatRepo.UpdateLastMessage(ctx, chatID, message); err != nil {
+		fmt.Printf("Failed to update last message: %v", err)
+	}
+
+	m.hub.BroadcastNewMessage(message, "")
+
+	return message, nil
+}
+
+// RunExpiryReaper periodically hard-deletes expired disappearing messages,
+// removes pins whose duration has run out and ends expired live locations.
+// It is safe to run on several server instances at once.
+func (m *MessageUsecase) RunExpiryReaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.reapExpiredMessages(context.Background())
+		m.reapExpiredPins(context.Background())
+		m.endExpiredLiveLocations(context.Background())
+	}
+}
+
+func (m *MessageUsecase) reapExpiredMessages(ctx context.Context) {
+	for {
+		messages, err := m.messageRepo.GetExpiredMessages(ctx, time.Now(), reaperBatchSize)
+		if err != nil {
+			fmt.Printf("Failed to load expired messages: %v", err)
+			return
+		}
+
+		reaped := 0
+		reapedByChat := make(map[primitive.ObjectID][]primitive.ObjectID)
+		for _, message := range messages {
+			if err := m.messageRepo.Delete(ctx, message.ID); err != nil {
+				fmt.Printf("Failed to delete expired message %s: %v", message.ID.Hex(), err)
+				continue
+			}
+
+			m.deleteMessageMedia(ctx, message)
+			if err := m.messageRepo.IncrementReplyCount(ctx, message.ThreadPath, -1); err != nil {
+				fmt.Printf("Failed to update reply counts: %v", err)
+			}
+			m.hub.BroadcastMessageExpired(message.ID, message.ChatID)
+			reapedByChat[message.ChatID] = append(reapedByChat[message.ChatID], message.ID)
+			reaped++
+		}
+
+		for chatID, messageIDs := range reapedByChat {
+			if err := m.starredRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+				fmt.Printf("Failed to remove stars for expired messages: %v", err)
+			}
+			if err := m.pinnedRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+				fmt.Printf("Failed to remove pins for expired messages: %v", err)
+			}
+			if err := m.pollRepo.DeleteForMessages(ctx, messageIDs); err != nil {
+				fmt.Printf("Failed to remove poll votes for expired messages: %v", err)
+			}
+			m.refreshLastMessage(ctx, chatID, messageIDs)
+		}
+
+		if reaped == 0 || len(messages) < reaperBatchSize {
+			return
+		}
+	}
+}
+
+func (m *MessageUsecase) reapExpiredPins(ctx context.Context) {
+	for {
+		pins, err := m.pinnedRepo.GetExpiredPins(ctx, time.Now(), reaperBatchSize)
+		if err != nil {
+			fmt.Printf("Failed to load expired pins: %v", err)
+			return
+		}
+
+		reaped := 0
+		for _, pin := range pins {
+			removed, err := m.pinnedRepo.Unpin(ctx, pin.ChatID, pin.MessageID)
+			if err != nil {
+				fmt.Printf("Failed to remove expired pin %s: %v", pin.ID.Hex(), err)
+				continue
+			}
+			// Another instance may have got there first
+			if removed {
+				m.hub.BroadcastMessageUnpinned(pin.MessageID, pin.ChatID, primitive.NilObjectID)
+			}
+			reaped++
+		}
+
+		if reaped == 0 || len(pins) < reaperBatchSize {
+			return
+		}
+	}
+}
+
+// deleteMessageMedia removes a message's uploaded file unless another
+// message (e.g. a forwarded copy) still points at it.
+func (m *MessageUsecase) deleteMessageMedia(ctx context.Context, message *entities.Message) {
+	if message.MediaURL == "" {
+		return
+	}
+
+	referenced, err := m.messageRepo.IsMediaReferenced(ctx, message.MediaURL, message.ID)
+	if err != nil || referenced {
+		return
+	}
+
+	if err := m.fileUploadService.DeleteByURL(message.MediaURL); err != nil {
+		fmt.Printf("Failed to delete media %s: %v", message.MediaURL, err)
+	}
+}
+
+// refreshLastMessage replaces a chat's last message preview if it points at
+// one of the removed messages.
+func (m *MessageUsecase) refreshLastMessage(ctx context.Context, chatID primitive.ObjectID, removedIDs []primitive.ObjectID) {
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil || chat.LastMessage == nil {
+		return
+	}
+
+	stale := false
+	for _, id := range removedIDs {
+		if chat.LastMessage.ID == id {
+			stale = true
+			break
+		}
+	}
+	if !stale {
+		return
+	}
+
+	lastMessage, err := m.messageRepo.GetLastMessage(ctx, chatID)
+	if err != nil {
+		lastMessage = nil
+	}
+
+	if err := m.chatRepo.UpdateLastMessage(ctx, chatID, lastMessage); err != nil {
+		fmt.Printf("Failed to update last message: %v", err)
+	}
+}
+
+// ========== Search and Media ==========
+
+const (
+	snippetLength  = 160 // Runes of context returned around a match
+	snippetLeadIn  = 40  // Runes shown before the first match
+	ellipsis       = "…"
+	ellipsisLength = 1 // UTF-16 length of ellipsis
+)
+
+// SearchAllMessages searches every chat the user is still a participant in.
+// Chats they have left are no longer in their chat list and so are skipped.
+func (m *MessageUsecase) SearchAllMessages(ctx context.Context, userID primitive.ObjectID, req *entities.MessageSearchRequest) (*entities.MessageSearchPage, error) {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" && req.ChatID == nil && req.SenderID == nil && req.Type == "" &&
+		req.From == nil && req.To == nil && !req.HasMedia && !req.HasLink {
+		return nil, errors.New("search query or at least one filter is required")
+	}
+
+	if req.From != nil && req.To != nil && !req.From.Before(*req.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > maxPageSize {
+		limit = defaultPageSize
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	chats, err := m.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatIDs := make([]primitive.ObjectID, 0, len(chats))
+	for _, chat := range chats {
+		if req.ChatID == nil || chat.ID == *req.ChatID {
+			chatIDs = append(chatIDs, chat.ID)
+		}
+	}
+
+	if req.ChatID != nil && len(chatIDs) == 0 {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	page := &entities.MessageSearchPage{Results: []*entities.MessageSearchResult{}}
+	if len(chatIDs) == 0 {
+		return page, nil
+	}
+
+	// Fetch one extra hit to know whether there is another page
+	query := *req
+	query.Limit = limit + 1
+
+	clears, err := m.clearRepo.GetForChats(ctx, userID, chatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := m.messageRepo.SearchMessages(ctx, userID, chatIDs, clears, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+		page.HasMore = true
+		page.NextOffset = req.Offset + limit
+	}
+
+	messages := make([]*entities.Message, 0, len(hits))
+	for _, hit := range hits {
+		messages = append(messages, hit.Message)
+	}
+	starred := m.getStarredIDs(ctx, userID, messages)
+
+	terms := searchTerms(req.Query)
+	for _, hit := range hits {
+		response := m.buildMessageResponse(ctx, hit.Message, userID)
+		response.IsStarred = starred[hit.ID]
+
+		// Snippets leave out formatting markup so matches inside *bold*
+		// text line up
+		text := hit.Content
+		if hit.PlainText != "" {
+			text = hit.PlainText
+		}
+
+		// Show the file name when that is where the match was
+		snippet, highlights := buildSnippet(text, terms)
+		if len(highlights) == 0 && hit.FileName != "" {
+			if fileSnippet, fileHighlights := buildSnippet(hit.FileName, terms); len(fileHighlights) > 0 {
+				snippet, highlights = fileSnippet, fileHighlights
+			}
+		}
+
+		page.Results = append(page.Results, &entities.MessageSearchResult{
+			MessageResponse: response,
+			Score:           hit.Score,
+			Snippet:         snippet,
+			Highlights:      highlights,
+		})
+	}
+
+	return page, nil
+}
+
+// searchTerms splits a text search query into lower-cased words and quoted
+// phrases, dropping negated (-word) terms since they never match.
+func searchTerms(query string) [][]rune {
+	var terms [][]rune
+	for i, part := range strings.Split(query, `"`) {
+		// Odd parts sit between quotes and are phrases
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				terms = append(terms, []rune(strings.ToLower(phrase)))
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if !strings.HasPrefix(word, "-") {
+				terms = append(terms, []rune(strings.ToLower(word)))
+			}
+		}
+	}
+	return terms
+}
+
+// buildSnippet cuts text down to a window around the first match and
+// returns where the terms appear in it, in UTF-16 units.
+func buildSnippet(text string, terms [][]rune) (string, []entities.TextRange) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Find word-initial matches; text search matches stemmed words, so
+	// "run" should light up "running"
+	type match struct{ start, end int }
+	var matches []match
+	for i := range lower {
+		if i > 0 && (unicode.IsLetter(lower[i-1]) || unicode.IsDigit(lower[i-1])) {
+			continue
+		}
+		longest := 0
+		for _, term := range terms {
+			if len(term) > longest && i+len(term) <= len(lower) && string(lower[i:i+len(term)]) == string(term) {
+				longest = len(term)
+			}
+		}
+		if longest > 0 && (len(matches) == 0 || i >= matches[len(matches)-1].end) {
+			matches = append(matches, match{i, i + longest})
+		}
+	}
+
+	start := 0
+	if len(matches) > 0 && matches[0].start > snippetLeadIn {
+		start = matches[0].start - snippetLeadIn
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+		if start = end - snippetLength; start < 0 {
+			start = 0
+		}
+	}
+
+	var snippet strings.Builder
+	offset := 0
+	if start > 0 {
+		snippet.WriteString(ellipsis)
+		offset = ellipsisLength
+	}
+	snippet.WriteString(string(runes[start:end]))
+	if end < len(runes) {
+		snippet.WriteString(ellipsis)
+	}
+
+	var highlights []entities.TextRange
+	for _, match := range matches {
+		if match.start < start || match.end > end {
+			continue
+		}
+		highlights = append(highlights, entities.TextRange{
+			Offset: offset + utf16Length(runes[start:match.start]),
+			Length: utf16Length(runes[match.start:match.end]),
+		})
+	}
+
+	return snippet.String(), highlights
+}
+
+func utf16Length(runes []rune) int {
+	length := 0
+	for _, r := range runes {
+		if r >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+func (m *MessageUsecase) SearchMessages(ctx context.Context, chatID, userID primitive.ObjectID, query string, limit int) ([]*entities.MessageResponse, error) {
+	// Verify user is participant in chat
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return nil, errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return nil, errors.New("user is not a participant in this chat")
+	}
+
+	cleared, err := m.clearRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Search messages
+	messages, err := m.messageRepo.SearchMessagesInChat(ctx, chatID, cleared, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to response format
 	var messageResponses []*entities.MessageResponse
 	for _, msg := range messages {
 		if !m.isDeletedForUser(msg, userID) {
@@ -401,8 +2817,13 @@ func (m *MessageUsecase) GetMediaMessages(ctx context.Context, chatID, userID pr
 		return nil, errors.New("user is not a participant in this chat")
 	}
 
+	cleared, err := m.clearRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get media messages
-	messages, err := m.messageRepo.GetMediaMessages(ctx, chatID, mediaType, limit, offset)
+	messages, err := m.messageRepo.GetMediaMessages(ctx, chatID, cleared, mediaType, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -430,7 +2851,12 @@ func (m *MessageUsecase) GetUnreadCount(ctx context.Context, chatID, userID prim
 		return 0, errors.New("user is not a participant in this chat")
 	}
 
-	return m.messageRepo.GetUnreadMessageCount(ctx, chatID, userID)
+	cleared, err := m.clearRepo.Get(ctx, userID, chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.messageRepo.GetUnreadMessageCount(ctx, chatID, userID, cleared)
 }
 
 // ========== Helper Methods ==========
@@ -449,12 +2875,191 @@ func (m *MessageUsecase) validateMessageContent(req *entities.SendMessageRequest
 		if req.MediaURL == "" || req.FileName == "" {
 			return errors.New("file message must have media URL and filename")
 		}
+	case entities.PollMessage:
+		return validatePoll(req.Poll)
+	case entities.LocationMessage:
+		return validateLocation(req.Location)
+	case entities.ContactMessage:
+		return validateContact(req.Contact)
 	default:
 		return errors.New("unsupported message type")
 	}
 	return nil
 }
 
+// formatContent parses the markup in text messages and media captions,
+// returning nothing for plain text so no second copy of it is stored.
+func formatContent(messageType entities.MessageType, content string) (string, []entities.TextEntity) {
+	switch messageType {
+	case entities.PollMessage, entities.LocationMessage, entities.ContactMessage, entities.SystemMessage:
+		return "", nil
+	}
+
+	plainText, parsed := formatting.Parse(content)
+	if len(parsed) == 0 {
+		return "", nil
+	}
+
+	textEntities := make([]entities.TextEntity, 0, len(parsed))
+	for _, entity := range parsed {
+		textEntities = append(textEntities, entities.TextEntity{
+			Type:   string(entity.Type),
+			Offset: entity.Offset,
+			Length: entity.Length,
+		})
+	}
+	return plainText, textEntities
+}
+
+// disappearingTimer returns how long new messages in the chat should live,
+// or zero when disappearing messages are off.
+func (m *MessageUsecase) disappearingTimer(ctx context.Context, chat *entities.Chat) time.Duration {
+	if chat.Type != entities.GroupChat {
+		return time.Duration(chat.DisappearingTime) * time.Second
+	}
+
+	settings := m.groupSettings(ctx, chat)
+	if settings == nil || !settings.DisappearingMessages || settings.DisappearingTime <= 0 {
+		return 0
+	}
+	return time.Duration(settings.DisappearingTime) * time.Second
+}
+
+// groupSettings prefers the settings kept by the group repository over the
+// copy on the chat.
+func (m *MessageUsecase) groupSettings(ctx context.Context, chat *entities.Chat) *entities.GroupSettings {
+	if group, err := m.groupRepo.GetGroupInfo(ctx, chat.ID); err == nil && group.Settings != nil {
+		return group.Settings
+	}
+	return chat.Settings
+}
+
+func formatDisappearingTime(seconds int) string {
+	duration := time.Duration(seconds) * time.Second
+
+	switch {
+	case duration%(24*time.Hour) == 0:
+		return pluralize(int(duration/(24*time.Hour)), "day")
+	case duration%time.Hour == 0:
+		return pluralize(int(duration/time.Hour), "hour")
+	case duration%time.Minute == 0:
+		return pluralize(int(duration/time.Minute), "minute")
+	default:
+		return pluralize(seconds, "second")
+	}
+}
+
+func pluralize(count int, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
+
+func (m *MessageUsecase) getSenderNames(ctx context.Context, messages []*entities.Message) map[primitive.ObjectID]string {
+	names := make(map[primitive.ObjectID]string)
+	if len(messages) == 0 {
+		return names
+	}
+
+	senderIDs := make([]primitive.ObjectID, 0, len(messages))
+	for _, msg := range messages {
+		senderIDs = append(senderIDs, msg.SenderID)
+	}
+
+	users, err := m.userRepo.GetByIDs(ctx, senderIDs)
+	if err != nil {
+		fmt.Printf("Failed to load message senders: %v", err)
+		return names
+	}
+	for _, user := range users {
+		names[user.ID] = user.Username
+	}
+
+	return names
+}
+
+// getVisibleMessage loads a message the user is allowed to see.
+func (m *MessageUsecase) getVisibleMessage(ctx context.Context, messageID, userID primitive.ObjectID) (*entities.Message, error) {
+	message, err := m.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, errors.New("message not found")
+	}
+
+	if err := m.checkMessageAccess(ctx, message, userID); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// checkMessageAccess fails unless the user is in the message's chat and
+// has not deleted the message for themselves.
+func (m *MessageUsecase) checkMessageAccess(ctx context.Context, message *entities.Message, userID primitive.ObjectID) error {
+	chat, err := m.chatRepo.GetByID(ctx, message.ChatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	if !m.isParticipant(userID, chat.Participants) {
+		return errors.New("user is not a participant in this chat")
+	}
+
+	if m.isDeletedForUser(message, userID) {
+		return errors.New("message not found")
+	}
+
+	return nil
+}
+
+func (m *MessageUsecase) getStarredIDs(ctx context.Context, userID primitive.ObjectID, messages []*entities.Message) map[primitive.ObjectID]bool {
+	messageIDs := make([]primitive.ObjectID, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	starred, err := m.starredRepo.GetStarredIDs(ctx, userID, messageIDs)
+	if err != nil {
+		fmt.Printf("Failed to load starred messages: %v", err)
+		return map[primitive.ObjectID]bool{}
+	}
+	return starred
+}
+
+// canDeleteForEveryone allows the sender within the delete window, and group
+// admins for any message at any time. Like the edit window, a zero delete
+// window puts no time limit on the sender.
+func (m *MessageUsecase) canDeleteForEveryone(ctx context.Context, chat *entities.Chat, message *entities.Message, userID primitive.ObjectID) bool {
+	if message.SenderID == userID && (m.deleteWindow <= 0 || time.Since(message.CreatedAt) < m.deleteWindow) {
+		return true
+	}
+
+	if chat.Type != entities.GroupChat {
+		return false
+	}
+
+	isAdmin, err := m.groupRepo.IsGroupAdmin(ctx, chat.ID, userID)
+	return err == nil && isAdmin
+}
+
+// tombstoneOf mirrors what SoftDeleteMessages leaves behind for everyone.
+func tombstoneOf(message *entities.Message, deletedBy primitive.ObjectID) *entities.Message {
+	now := time.Now()
+	return &entities.Message{
+		ID:        message.ID,
+		ChatID:    message.ChatID,
+		SenderID:  message.SenderID,
+		Type:      message.Type,
+		Content:   entities.DeletedMessagePlaceholder,
+		Status:    message.Status,
+		IsDeleted: true,
+		DeletedAt: &now,
+		DeletedBy: &deletedBy,
+		CreatedAt: message.CreatedAt,
+		UpdatedAt: now,
+	}
+}
+
 func (m *MessageUsecase) isParticipant(userID primitive.ObjectID, participants []primitive.ObjectID) bool {
 	for _, p := range participants {
 		if p == userID {
@@ -473,10 +3078,9 @@ func (m *MessageUsecase) isReadByUser(message *entities.Message, userID primitiv
 	return false
 }
 
+// isDeletedForUser reports "delete for me"; messages deleted for everyone are
+// still shown as tombstones.
 func (m *MessageUsecase) isDeletedForUser(message *entities.Message, userID primitive.ObjectID) bool {
-	if message.IsDeleted {
-		return true
-	}
 	for _, deletedFor := range message.DeletedFor {
 		if deletedFor == userID {
 			return true
@@ -485,6 +3089,18 @@ func (m *MessageUsecase) isDeletedForUser(message *entities.Message, userID prim
 	return false
 }
 
+// isHiddenByClear reports whether the user's clear of the message's chat
+// hides it. A nil clear hides nothing.
+func isHiddenByClear(message *entities.Message, cleared *entities.ChatClear) bool {
+	if cleared == nil || message.ChatID != cleared.ChatID || message.CreatedAt.After(cleared.ClearedAt) {
+		return false
+	}
+	if message.ImportedAt != nil && message.ImportedAt.After(cleared.ClearedAt) {
+		return false
+	}
+	return !slices.Contains(cleared.KeptMessageIDs, message.ID)
+}
+
 func (m *MessageUsecase) buildMessageResponse(ctx context.Context, msg *entities.Message, currentUserID primitive.ObjectID) *entities.MessageResponse {
 	response := &entities.MessageResponse{
 		Message:     msg,
@@ -493,7 +3109,9 @@ func (m *MessageUsecase) buildMessageResponse(ctx context.Context, msg *entities
 	}
 
 	// Get sender name
-	if sender, err := m.userRepo.GetByID(ctx, msg.SenderID); err == nil {
+	if msg.ImportedSender != "" {
+		response.SenderName = msg.ImportedSender
+	} else if sender, err := m.userRepo.GetByID(ctx, msg.SenderID); err == nil {
 		response.SenderName = sender.Username
 	}
 
@@ -513,37 +3131,89 @@ func (m *MessageUsecase) buildMessageResponse(ctx context.Context, msg *entities
 	return response
 }
 
-func (m *MessageUsecase) isDeliveredToUser(message *entities.Message, userID primitive.ObjectID) bool {
-	for _, deliveryInfo := range message.DeliveredTo {
-		if deliveryInfo.UserID == userID {
-			return true
-		}
+// getMessagePage reads one page in the given direction and reports whether
+// more messages exist beyond it.
+func (m *MessageUsecase) getMessagePage(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, cursor *entities.MessageCursor, direction repositories.PageDirection, limit int) ([]*entities.Message, bool, error) {
+	messages, err := m.messageRepo.GetChatMessages(ctx, chatID, cleared, cursor, direction, limit+1)
+	if err != nil {
+		return nil, false, err
 	}
-	return false
+
+	if len(messages) <= limit {
+		return messages, false, nil
+	}
+
+	// Drop the probe message, which is the one furthest from the cursor
+	if direction == repositories.PageNewer {
+		return messages[1:], true, nil
+	}
+	return messages[:limit], true, nil
 }
 
-func (m *MessageUsecase) markAsDeliveredForOnlineUsers(ctx context.Context, message *entities.Message, participants []primitive.ObjectID) {
-	for _, participantID := range participants {
-		// Skip sender
-		if participantID == message.SenderID {
-			continue
-		}
+// getMessagesAround loads a window centred on anchorID for jump-to-message.
+func (m *MessageUsecase) getMessagesAround(ctx context.Context, chatID primitive.ObjectID, cleared *entities.ChatClear, anchorID primitive.ObjectID, limit int) ([]*entities.Message, bool, bool, error) {
+	// Tombstones are part of the timeline, so they can be jumped to
+	anchor, err := m.messageRepo.GetByIDIncludingDeleted(ctx, anchorID)
+	if err != nil || anchor.ChatID != chatID || isHiddenByClear(anchor, cleared) {
+		return nil, false, false, errors.New("message not found")
+	}
 
-		// Check if user is online (simplified - you might want to check actual online status)
-		if err := m.messageRepo.MarkAsDelivered(ctx, message.ID, participantID); err == nil {
-			// Broadcast delivery status
-			m.hub.BroadcastMessageStatus(message.ID, message.ChatID, participantID, entities.MessageDelivered)
-		}
+	cursor := &entities.MessageCursor{ID: anchor.ID, CreatedAt: anchor.CreatedAt}
+
+	newer, hasNewer, err := m.getMessagePage(ctx, chatID, cleared, cursor, repositories.PageNewer, limit/2)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	older, hasOlder, err := m.getMessagePage(ctx, chatID, cleared, cursor, repositories.PageOlder, limit-limit/2-1)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	messages := make([]*entities.Message, 0, len(newer)+len(older)+1)
+	messages = append(messages, newer...)
+	messages = append(messages, anchor)
+	messages = append(messages, older...)
+
+	return messages, hasOlder, hasNewer, nil
+}
+
+func encodeMessageCursor(message *entities.Message) string {
+	raw := fmt.Sprintf("%d:%s", message.CreatedAt.UnixMilli(), message.ID.Hex())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeMessageCursor(value string) (*entities.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
 	}
+
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &entities.MessageCursor{ID: id, CreatedAt: time.UnixMilli(millis)}, nil
 }
 
-func (m *MessageUsecase) markMultipleAsRead(ctx context.Context, messageIDs []primitive.ObjectID, userID, chatID primitive.ObjectID) {
-	if err := m.messageRepo.MarkMultipleAsRead(ctx, messageIDs, userID); err == nil {
-		// Broadcast read status for all messages
-		for _, messageID := range messageIDs {
-			m.hub.BroadcastMessageStatus(messageID, chatID, userID, entities.MessageRead)
+func (m *MessageUsecase) isDeliveredToUser(message *entities.Message, userID primitive.ObjectID) bool {
+	for _, deliveryInfo := range message.DeliveredTo {
+		if deliveryInfo.UserID == userID {
+			return true
 		}
 	}
+	return false
 }
 
 // ========== Additional Public Methods ==========
@@ -572,6 +3242,7 @@ func (m *MessageUsecase) GetMessage(ctx context.Context, messageID, userID primi
 
 	// Build response
 	response := m.buildMessageResponse(ctx, message, userID)
+	response.IsStarred = m.getStarredIDs(ctx, userID, []*entities.Message{message})[message.ID]
 	return response, nil
 }
 
@@ -580,58 +3251,31 @@ func (m *MessageUsecase) MarkMultipleAsRead(ctx context.Context, messageIDs []pr
 		return errors.New("no message IDs provided")
 	}
 
-	// Get the first message to determine the chat ID and verify access
-	firstMessage, err := m.messageRepo.GetByID(ctx, messageIDs[0])
-	if err != nil {
-		return errors.New("message not found")
-	}
-
-	// Verify user is participant in the chat
-	chat, err := m.chatRepo.GetByID(ctx, firstMessage.ChatID)
+	messages, err := m.messageRepo.GetByIDs(ctx, messageIDs)
 	if err != nil {
-		return errors.New("chat not found")
+		return err
 	}
-
-	if !m.isParticipant(userID, chat.Participants) {
-		return errors.New("user is not a participant in this chat")
+	if len(messages) == 0 {
+		return errors.New("message not found")
 	}
 
 	// Verify all messages belong to the same chat
-	for _, messageID := range messageIDs {
-		message, err := m.messageRepo.GetByID(ctx, messageID)
-		if err != nil {
-			continue // Skip invalid message IDs
-		}
-		if message.ChatID != firstMessage.ChatID {
+	chatID := messages[0].ChatID
+	for _, message := range messages {
+		if message.ChatID != chatID {
 			return errors.New("all messages must belong to the same chat")
 		}
 	}
 
-	// Filter out messages sent by the user (don't mark own messages as read)
-	var validMessageIDs []primitive.ObjectID
-	for _, messageID := range messageIDs {
-		message, err := m.messageRepo.GetByID(ctx, messageID)
-		if err != nil {
-			continue
-		}
-		if message.SenderID != userID && !m.isReadByUser(message, userID) {
-			validMessageIDs = append(validMessageIDs, messageID)
-		}
-	}
-
-	if len(validMessageIDs) == 0 {
-		return nil // No messages to mark as read
-	}
-
-	// Mark messages as read
-	if err := m.messageRepo.MarkMultipleAsRead(ctx, validMessageIDs, userID); err != nil {
-		return err
+	// Verify user is participant in the chat
+	chat, err := m.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return errors.New("chat not found")
 	}
 
-	// Broadcast read status for all messages
-	for _, messageID := range validMessageIDs {
-		m.hub.BroadcastMessageStatus(messageID, firstMessage.ChatID, userID, entities.MessageRead)
+	if !m.isParticipant(userID, chat.Participants) {
+		return errors.New("user is not a participant in this chat")
 	}
 
-	return nil
+	return m.recordReceipts(ctx, userID, messages, entities.MessageRead)
 }
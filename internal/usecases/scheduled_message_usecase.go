@@ -0,0 +1,286 @@
+package usecases
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxScheduleAhead = 365 * 24 * time.Hour
+	dispatchLease    = 2 * time.Minute
+)
+
+type ScheduledMessageUsecase struct {
+	scheduledRepo  repositories.ScheduledMessageRepository
+	chatRepo       repositories.ChatRepository
+	messageUsecase *MessageUsecase
+}
+
+func NewScheduledMessageUsecase(
+	scheduledRepo repositories.ScheduledMessageRepository,
+	chatRepo repositories.ChatRepository,
+	messageUsecase *MessageUsecase,
+) *ScheduledMessageUsecase {
+	return &ScheduledMessageUsecase{
+		scheduledRepo:  scheduledRepo,
+		chatRepo:       chatRepo,
+		messageUsecase: messageUsecase,
+	}
+}
+
+// ========== Scheduling ==========
+
+func (s *ScheduledMessageUsecase) ScheduleMessage(ctx context.Context, userID primitive.ObjectID, req *entities.SendMessageRequest) (*entities.ScheduledMessage, error) {
+	if req.SendAt == nil {
+		return nil, errors.New("sendAt is required to schedule a message")
+	}
+
+	if err := validateSendAt(*req.SendAt); err != nil {
+		return nil, err
+	}
+
+	// Verify user is participant in chat
+	if err := s.verifyParticipant(ctx, req.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	// A retry of a schedule that already went through gets the original back
+	if req.ClientMessageID != "" {
+		if len(req.ClientMessageID) > entities.MaxClientMessageIDLength {
+			return nil, fmt.Errorf("client message ID cannot be longer than %d characters", entities.MaxClientMessageIDLength)
+		}
+
+		existing, err := s.scheduledRepo.GetByClientMessageID(ctx, req.ChatID, userID, req.ClientMessageID)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+
+	// Validate message content based on type
+	if err := s.messageUsecase.validateMessageContent(req); err != nil {
+		return nil, err
+	}
+
+	scheduled := &entities.ScheduledMessage{
+		ChatID:     req.ChatID,
+		SenderID:   userID,
+		Type:       req.Type,
+		Content:    req.Content,
+		MediaURL:   req.MediaURL,
+		MediaType:  req.MediaType,
+		FileName:   req.FileName,
+		FileSize:   req.FileSize,
+		Duration:   req.Duration,
+		Dimensions: req.Dimensions,
+		ReplyToID:  req.ReplyToID,
+		Poll:       req.Poll,
+		Location:   req.Location,
+		Contact:    req.Contact,
+		SendAt:     *req.SendAt,
+
+		ClientMessageID: req.ClientMessageID,
+	}
+
+	// A concurrent retry can still get here first, in which case its
+	// scheduled message is the one to return
+	if scheduled.ClientMessageID != "" {
+		existing, err := s.scheduledRepo.CreateIdempotent(ctx, scheduled)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	} else if err := s.scheduledRepo.Create(ctx, scheduled); err != nil {
+		return nil, err
+	}
+
+	// Scheduling counts as sending as far as the draft is concerned
+	s.messageUsecase.clearDraft(ctx, userID, req.ChatID)
+
+	return scheduled, nil
+}
+
+func (s *ScheduledMessageUsecase) GetScheduledMessages(ctx context.Context, chatID, userID primitive.ObjectID) ([]*entities.ScheduledMessage, error) {
+	// Verify user is participant in chat
+	if err := s.verifyParticipant(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	scheduled, err := s.scheduledRepo.GetPendingForChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduled == nil {
+		scheduled = []*entities.ScheduledMessage{}
+	}
+
+	return scheduled, nil
+}
+
+func (s *ScheduledMessageUsecase) UpdateScheduledMessage(ctx context.Context, scheduledID, userID primitive.ObjectID, req *entities.UpdateScheduledMessageRequest) (*entities.ScheduledMessage, error) {
+	scheduled, err := s.getOwnScheduledMessage(ctx, scheduledID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Content == nil && req.SendAt == nil {
+		return nil, errors.New("nothing to update")
+	}
+
+	if req.Content != nil && scheduled.Type == entities.TextMessage && *req.Content == "" {
+		return nil, errors.New("text message content cannot be empty")
+	}
+
+	if req.SendAt != nil {
+		if err := validateSendAt(*req.SendAt); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.scheduledRepo.UpdatePending(ctx, scheduledID, req.Content, req.SendAt)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("scheduled message is no longer pending")
+	}
+
+	return s.scheduledRepo.GetByID(ctx, scheduledID)
+}
+
+func (s *ScheduledMessageUsecase) CancelScheduledMessage(ctx context.Context, scheduledID, userID primitive.ObjectID) error {
+	if _, err := s.getOwnScheduledMessage(ctx, scheduledID, userID); err != nil {
+		return err
+	}
+
+	cancelled, err := s.scheduledRepo.Cancel(ctx, scheduledID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return errors.New("scheduled message is no longer pending")
+	}
+
+	return nil
+}
+
+// ========== Dispatcher ==========
+
+// RunDispatcher delivers due scheduled messages. Each message is claimed
+// atomically before sending, so several server instances can run it at once.
+func (s *ScheduledMessageUsecase) RunDispatcher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.dispatchDue(context.Background())
+	}
+}
+
+func (s *ScheduledMessageUsecase) dispatchDue(ctx context.Context) {
+	if _, err := s.scheduledRepo.RequeueStale(ctx, time.Now()); err != nil {
+		fmt.Printf("Failed to release stale scheduled messages: %v", err)
+	}
+
+	for {
+		scheduled, err := s.scheduledRepo.ClaimDue(ctx, time.Now(), dispatchLease)
+		if err != nil {
+			fmt.Printf("Failed to claim scheduled message: %v", err)
+			return
+		}
+		if scheduled == nil {
+			return
+		}
+
+		s.dispatch(ctx, scheduled)
+	}
+}
+
+func (s *ScheduledMessageUsecase) dispatch(ctx context.Context, scheduled *entities.ScheduledMessage) {
+	req := &entities.SendMessageRequest{
+		ChatID:     scheduled.ChatID,
+		Type:       scheduled.Type,
+		Content:    scheduled.Content,
+		MediaURL:   scheduled.MediaURL,
+		MediaType:  scheduled.MediaType,
+		FileName:   scheduled.FileName,
+		FileSize:   scheduled.FileSize,
+		Duration:   scheduled.Duration,
+		Dimensions: scheduled.Dimensions,
+		ReplyToID:  scheduled.ReplyToID,
+		Poll:       scheduled.Poll,
+		Location:   scheduled.Location,
+		Contact:    scheduled.Contact,
+
+		ClientMessageID: scheduled.ClientMessageID,
+	}
+
+	// Every dispatch carries a client message ID, so a retry after an
+	// interrupted one cannot send the message twice
+	if req.ClientMessageID == "" {
+		req.ClientMessageID = "scheduled:" + scheduled.ID.Hex()
+	}
+
+	// Go through the normal send path so participant checks still apply
+	message, err := s.messageUsecase.SendScheduledMessage(ctx, scheduled.SenderID, req)
+	if err != nil {
+		if markErr := s.scheduledRepo.MarkFailed(ctx, scheduled.ID, err.Error()); markErr != nil {
+			fmt.Printf("Failed to mark scheduled message %s as failed: %v", scheduled.ID.Hex(), markErr)
+		}
+		return
+	}
+
+	if err := s.scheduledRepo.MarkSent(ctx, scheduled.ID, message.ID); err != nil {
+		fmt.Printf("Failed to mark scheduled message %s as sent: %v", scheduled.ID.Hex(), err)
+	}
+}
+
+// ========== Helper Methods ==========
+
+func (s *ScheduledMessageUsecase) verifyParticipant(ctx context.Context, chatID, userID primitive.ObjectID) error {
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return errors.New("chat not found")
+	}
+
+	if !s.messageUsecase.isParticipant(userID, chat.Participants) {
+		return errors.New("user is not a participant in this chat")
+	}
+
+	return nil
+}
+
+func (s *ScheduledMessageUsecase) getOwnScheduledMessage(ctx context.Context, scheduledID, userID primitive.ObjectID) (*entities.ScheduledMessage, error) {
+	scheduled, err := s.scheduledRepo.GetByID(ctx, scheduledID)
+	if err != nil {
+		return nil, errors.New("scheduled message not found")
+	}
+
+	// Scheduled messages are private to their sender until delivered
+	if scheduled.SenderID != userID {
+		return nil, errors.New("scheduled message not found")
+	}
+
+	return scheduled, nil
+}
+
+func validateSendAt(sendAt time.Time) error {
+	if !sendAt.After(time.Now()) {
+		return errors.New("sendAt must be in the future")
+	}
+	if sendAt.After(time.Now().Add(maxScheduleAhead)) {
+		return errors.New("sendAt cannot be more than a year ahead")
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package usecases
+
+import (
+	"bro-chat/internal/domain/entities"
+	"bro-chat/internal/domain/repositories"
+	"bro-chat/pkg/websocket"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SyncUsecase struct {
+	messageRepo    repositories.MessageRepository
+	chatRepo       repositories.ChatRepository
+	userRepo       repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	clearRepo      repositories.ChatClearRepository
+	messageUsecase *MessageUsecase
+}
+
+func NewSyncUsecase(
+	messageRepo repositories.MessageRepository,
+	chatRepo repositories.ChatRepository,
+	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	clearRepo repositories.ChatClearRepository,
+	messageUsecase *MessageUsecase,
+) *SyncUsecase {
+	return &SyncUsecase{
+		messageRepo:    messageRepo,
+		chatRepo:       chatRepo,
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		clearRepo:      clearRepo,
+		messageUsecase: messageUsecase,
+	}
+}
+
+// ErrInvalidSyncToken is returned for a sync token that cannot be decoded.
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// ========== Offline Sync ==========
+
+const (
+	syncPageSize  = 100
+	maxSyncEvents = 2000                // Per request; the client continues with the token
+	maxSyncAge    = 30 * 24 * time.Hour // Older clients reload their chats instead
+)
+
+// SyncMissed replays what the user missed while offline, oldest first:
+// new messages, edits, reactions, deletions and group membership changes.
+// Messages are marked delivered as each page is queued. It implements
+// websocket.SyncHandler.
+//
+// Changes are read from a snapshot that ends when the sync starts, and the
+// final token resumes from there. Marking messages delivered changes them
+// again, so without the snapshot a page could come back as new. Anything
+// later reaches the connected client live.
+func (s *SyncUsecase) SyncMissed(userID primitive.ObjectID, req *websocket.SyncRequestPayload, send func(websocket.WSMessage) bool) (*websocket.SyncCompletePayload, error) {
+	ctx := context.Background()
+	startedAt := time.Now()
+	until := startedAt
+
+	var after *repositories.SyncCursor
+	if req.SyncToken != "" {
+		cursor, snapshot, err := decodeSyncToken(req.SyncToken)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+		// A sync that was cut short continues in the snapshot it started
+		if !snapshot.IsZero() {
+			until = snapshot
+		}
+	} else if len(req.Chats) == 0 {
+		return nil, errors.New("sync token or chat positions required")
+	}
+
+	positions, err := s.getChatPositions(ctx, req.Chats)
+	if err != nil {
+		return nil, err
+	}
+
+	chats, err := s.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := make(map[primitive.ObjectID]time.Time)
+	var points []repositories.ChatSyncPoint
+	groupIDs := []primitive.ObjectID{}
+	var oldest time.Time
+	for _, chat := range chats {
+		chatSince, ok := positions[chat.ID]
+		if !ok {
+			if after == nil {
+				continue
+			}
+			chatSince = after.UpdatedAt
+		}
+		if chatSince.Before(startedAt.Add(-maxSyncAge)) {
+			return nil, errors.New("sync position is too old, reload chats instead")
+		}
+
+		since[chat.ID] = chatSince
+		points = append(points, repositories.ChatSyncPoint{ChatID: chat.ID, Since: chatSince})
+		if chat.Type == entities.GroupChat {
+			groupIDs = append(groupIDs, chat.ID)
+		}
+		if oldest.IsZero() || chatSince.Before(oldest) {
+			oldest = chatSince
+		}
+	}
+
+	complete := &websocket.SyncCompletePayload{}
+	if len(points) == 0 {
+		complete.SyncToken = encodeSyncToken(&repositories.SyncCursor{UpdatedAt: until}, time.Time{})
+		return complete, nil
+	}
+
+	activities, err := s.getMembershipChanges(ctx, userID, groupIDs, since, oldest)
+	if err != nil {
+		return nil, err
+	}
+
+	chatIDs := make([]primitive.ObjectID, 0, len(points))
+	for _, point := range points {
+		chatIDs = append(chatIDs, point.ChatID)
+	}
+	clears, err := s.clearRepo.GetForChats(ctx, userID, chatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clears made on another device while this one was away go first, so
+	// the history is dropped before anything newer arrives
+	clearsByChat := make(map[primitive.ObjectID]*entities.ChatClear, len(clears))
+	for _, cleared := range clears {
+		clearsByChat[cleared.ChatID] = cleared
+		if !cleared.ClearedAt.After(since[cleared.ChatID]) {
+			continue
+		}
+		event := websocket.WSMessage{Type: string(websocket.WSChatCleared), Payload: chatClearedPayload(cleared)}
+		if !send(event) {
+			return nil, errors.New("sync interrupted")
+		}
+		complete.Events++
+	}
+
+	// Membership changes are interleaved with messages by time
+	sendActivitiesUntil := func(until *time.Time) bool {
+		for len(activities) > 0 && (until == nil || !activities[0].createdAt.After(*until)) {
+			if !send(activities[0].event) {
+				return false
+			}
+			activities = activities[1:]
+			complete.Events++
+		}
+		return true
+	}
+
+	// Only cap once a page has moved the cursor, or the client would
+	// resume from where it started and get the same events again
+	loaded := false
+	for {
+		if loaded && after != nil && complete.Events >= maxSyncEvents {
+			complete.HasMore = true
+			complete.SyncToken = encodeSyncToken(after, until)
+			return complete, nil
+		}
+
+		messages, err := s.messageRepo.GetChangedMessages(ctx, points, after, until, syncPageSize)
+		if err != nil {
+			return nil, err
+		}
+		loaded = true
+
+		senderNames := s.messageUsecase.getSenderNames(ctx, messages)
+		var received []*entities.Message
+		for _, msg := range messages {
+			if !sendActivitiesUntil(&msg.UpdatedAt) {
+				return nil, errors.New("sync interrupted")
+			}
+
+			// The device already dropped what the user cleared
+			if isHiddenByClear(msg, clearsByChat[msg.ChatID]) {
+				after = &repositories.SyncCursor{UpdatedAt: msg.UpdatedAt, ID: msg.ID}
+				continue
+			}
+
+			if event, ok := s.syncEvent(msg, userID, since[msg.ChatID], senderNames); ok {
+				if !send(event) {
+					return nil, errors.New("sync interrupted")
+				}
+				complete.Events++
+			}
+
+			if !msg.IsDeleted && !s.messageUsecase.isDeletedForUser(msg, userID) {
+				received = append(received, msg)
+			}
+			after = &repositories.SyncCursor{UpdatedAt: msg.UpdatedAt, ID: msg.ID}
+		}
+
+		if err := s.messageUsecase.recordReceipts(ctx, userID, received, entities.MessageDelivered); err != nil {
+			fmt.Printf("Failed to mark synced messages as delivered: %v", err)
+		}
+
+		if len(messages) < syncPageSize {
+			break
+		}
+	}
+
+	if !sendActivitiesUntil(&until) {
+		return nil, errors.New("sync interrupted")
+	}
+
+	complete.SyncToken = encodeSyncToken(&repositories.SyncCursor{UpdatedAt: until}, time.Time{})
+	return complete, nil
+}
+
+// getChatPositions finds when each chat's last seen message arrived, which
+// is where its sync starts; chats without a position use the token's time.
+// An imported message counts from its import, since the device cannot have
+// seen it before then.
+func (s *SyncUsecase) getChatPositions(ctx context.Context, chats []websocket.ChatSyncPosition) (map[primitive.ObjectID]time.Time, error) {
+	positions := make(map[primitive.ObjectID]time.Time, len(chats))
+	if len(chats) == 0 {
+		return positions, nil
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(chats))
+	for _, position := range chats {
+		messageIDs = append(messageIDs, position.LastMessageID)
+	}
+
+	messages, err := s.messageRepo.GetByIDsIncludingDeleted(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]*entities.Message, len(messages))
+	for _, msg := range messages {
+		byID[msg.ID] = msg
+	}
+
+	for _, position := range chats {
+		msg := byID[position.LastMessageID]
+		if msg != nil && msg.ChatID != position.ChatID {
+			return nil, errors.New("sync position does not belong to its chat")
+		}
+
+		var at time.Time
+		switch {
+		case msg == nil:
+			// Gone since, for example reaped after disappearing. The ID
+			// still tells roughly when it was sent.
+			at = position.LastMessageID.Timestamp()
+		case msg.ImportedAt != nil && msg.ImportedAt.After(msg.CreatedAt):
+			at = *msg.ImportedAt
+		default:
+			at = msg.CreatedAt
+		}
+		positions[position.ChatID] = at
+	}
+
+	return positions, nil
+}
+
+// syncEvent turns a changed message into the event a live client would
+// have received. Messages created and deleted while the user was away are
+// skipped.
+func (s *SyncUsecase) syncEvent(msg *entities.Message, userID primitive.ObjectID, since time.Time, senderNames map[primitive.ObjectID]string) (websocket.WSMessage, bool) {
+	isNew := msg.CreatedAt.After(since)
+
+	if msg.IsDeleted || s.messageUsecase.isDeletedForUser(msg, userID) {
+		if isNew {
+			return websocket.WSMessage{}, false
+		}
+
+		payload := websocket.MessageDeletedPayload{
+			MessageID: msg.ID,
+			ChatID:    msg.ChatID,
+			DeletedBy: userID,
+			Content:   entities.DeletedMessagePlaceholder,
+			DeletedAt: msg.UpdatedAt,
+		}
+		if msg.IsDeleted && msg.DeletedBy != nil {
+			payload.DeletedBy = *msg.DeletedBy
+		}
+		if msg.IsDeleted && msg.DeletedAt != nil {
+			payload.DeletedAt = *msg.DeletedAt
+		}
+		return websocket.WSMessage{Type: string(websocket.WSMessageDeleted), Payload: payload}, true
+	}
+
+	if isNew {
+		return websocket.WSMessage{
+			Type: string(websocket.WSNewMessage),
+			Payload: websocket.NewMessagePayload{
+				Message:         msg,
+				ChatID:          msg.ChatID,
+				SenderName:      senderNames[msg.SenderID],
+				ClientMessageID: msg.ClientMessageID,
+			},
+		}, true
+	}
+
+	return websocket.WSMessage{
+		Type: string(websocket.WSMessageUpdated),
+		Payload: websocket.MessageUpdatedPayload{
+			MessageID: msg.ID,
+			ChatID:    msg.ChatID,
+			Message:   msg,
+		},
+	}, true
+}
+
+type membershipEvent struct {
+	createdAt time.Time
+	event     websocket.WSMessage
+}
+
+// getMembershipChanges loads member activity in the user's groups after
+// each group's sync position, plus removals from groups they have left.
+func (s *SyncUsecase) getMembershipChanges(ctx context.Context, userID primitive.ObjectID, groupIDs []primitive.ObjectID, since map[primitive.ObjectID]time.Time, oldest time.Time) ([]membershipEvent, error) {
+	activities, err := s.groupRepo.GetMembershipChanges(ctx, groupIDs, userID, oldest, maxSyncEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberIDs []primitive.ObjectID
+	for _, activity := range activities {
+		if activity.TargetUserID != nil {
+			memberIDs = append(memberIDs, *activity.TargetUserID)
+		}
+	}
+
+	members := make(map[primitive.ObjectID]*entities.User)
+	if len(memberIDs) > 0 {
+		users, err := s.userRepo.GetByIDs(ctx, memberIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			members[user.ID] = user
+		}
+	}
+
+	var events []membershipEvent
+	for _, activity := range activities {
+		if groupSince, ok := since[activity.GroupID]; ok && !activity.CreatedAt.After(groupSince) {
+			continue
+		}
+		if activity.TargetUserID == nil || members[*activity.TargetUserID] == nil {
+			continue
+		}
+
+		payload := websocket.MemberUpdatePayload{
+			GroupID: activity.GroupID,
+			User:    *members[*activity.TargetUserID],
+		}
+		if role, ok := activity.Details["new_role"].(string); ok {
+			payload.Role = role
+		}
+
+		events = append(events, membershipEvent{
+			createdAt: activity.CreatedAt,
+			event:     websocket.WSMessage{Type: activity.Type, Payload: payload},
+		})
+	}
+
+	return events, nil
+}
+
+// Sync tokens use the same layout as message cursors, keyed on updated_at.
+// A token that resumes a sync cut short also carries the end of its
+// snapshot.
+func encodeSyncToken(cursor *repositories.SyncCursor, snapshot time.Time) string {
+	raw := fmt.Sprintf("%d:%s", cursor.UpdatedAt.UnixMilli(), cursor.ID.Hex())
+	if !snapshot.IsZero() {
+		raw += fmt.Sprintf(":%d", snapshot.UnixMilli())
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSyncToken(value string) (*repositories.SyncCursor, time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, time.Time{}, ErrInvalidSyncToken
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, time.Time{}, ErrInvalidSyncToken
+	}
+
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, time.Time{}, ErrInvalidSyncToken
+	}
+
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return nil, time.Time{}, ErrInvalidSyncToken
+	}
+
+	var snapshot time.Time
+	if len(parts) == 3 {
+		snapshotMillis, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, time.Time{}, ErrInvalidSyncToken
+		}
+		snapshot = time.UnixMilli(snapshotMillis)
+	}
+
+	return &repositories.SyncCursor{UpdatedAt: time.UnixMilli(millis), ID: id}, snapshot, nil
+}
@@ -104,6 +104,23 @@ func (u *UserUsecase) UpdateProfile(ctx context.Context, userID primitive.Object
 	return user, nil
 }
 
+func (u *UserUsecase) UpdatePrivacy(ctx context.Context, userID primitive.ObjectID, req *entities.UpdatePrivacyRequest) (*entities.User, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.HideReadReceipts != nil {
+		user.Privacy.HideReadReceipts = *req.HideReadReceipts
+	}
+
+	if err := u.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (u *UserUsecase) SearchUsers(ctx context.Context, query string) ([]*entities.User, error) {
 	return u.userRepo.SearchUsers(ctx, query, 20)
 }
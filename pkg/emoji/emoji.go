@@ -0,0 +1,183 @@
+// Package emoji recognises single emoji, including the multi-code-point
+// forms described in Unicode Technical Standard #51: skin tone modifiers,
+// flags, keycaps, tag sequences and ZWJ sequences.
+package emoji
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxLength bounds the bytes of one emoji. The longest standard sequences
+// (families, subdivision flags) need under 40.
+const MaxLength = 64
+
+const (
+	zwj              = '\u200D'
+	variationSelect  = '\uFE0F' // Emoji presentation selector
+	textSelect       = '\uFE0E' // Text presentation selector
+	combiningKeycap  = '\u20E3'
+	blackFlag        = '\U0001F3F4'
+	tagCancel        = '\U000E007F'
+	firstTag         = '\U000E0020'
+	lastTag          = '\U000E007E'
+	firstRegional    = '\U0001F1E6'
+	lastRegional     = '\U0001F1FF'
+	firstSkinTone    = '\U0001F3FB'
+	lastSkinTone     = '\U0001F3FF'
+	maxZWJComponents = 10
+)
+
+// pictographic lists the code points that can stand alone as an emoji or
+// start a sequence. The Miscellaneous Symbols and Dingbats blocks are
+// mostly plain symbols, so only their emoji code points are listed.
+var pictographic = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE}, {0x203C, 0x203C}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139}, {0x2194, 0x2199}, {0x21A9, 0x21AA},
+	{0x231A, 0x231B}, {0x2328, 0x2328}, {0x23CF, 0x23CF}, {0x23E9, 0x23F3},
+	{0x23F8, 0x23FA}, {0x24C2, 0x24C2}, {0x25AA, 0x25AB}, {0x25B6, 0x25B6},
+	{0x25C0, 0x25C0}, {0x25FB, 0x25FE},
+	{0x2600, 0x2604}, {0x260E, 0x260E}, {0x2611, 0x2611}, {0x2614, 0x2615},
+	{0x2618, 0x2618}, {0x261D, 0x261D}, {0x2620, 0x2620}, {0x2622, 0x2623},
+	{0x2626, 0x2626}, {0x262A, 0x262A}, {0x262E, 0x262F}, {0x2638, 0x263A},
+	{0x2640, 0x2640}, {0x2642, 0x2642}, {0x2648, 0x2653}, {0x265F, 0x2660},
+	{0x2663, 0x2663}, {0x2665, 0x2666}, {0x2668, 0x2668}, {0x267B, 0x267B},
+	{0x267E, 0x267F}, {0x2692, 0x2697}, {0x2699, 0x2699}, {0x269B, 0x269C},
+	{0x26A0, 0x26A1}, {0x26A7, 0x26A7}, {0x26AA, 0x26AB}, {0x26B0, 0x26B1},
+	{0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26C8, 0x26C8}, {0x26CE, 0x26CF},
+	{0x26D1, 0x26D1}, {0x26D3, 0x26D4}, {0x26E9, 0x26EA}, {0x26F0, 0x26F5},
+	{0x26F7, 0x26FA}, {0x26FD, 0x26FD}, {0x2702, 0x2702}, {0x2705, 0x2705},
+	{0x2708, 0x270D}, {0x270F, 0x270F}, {0x2712, 0x2712}, {0x2714, 0x2714},
+	{0x2716, 0x2716}, {0x271D, 0x271D}, {0x2721, 0x2721}, {0x2728, 0x2728},
+	{0x2733, 0x2734}, {0x2744, 0x2744}, {0x2747, 0x2747}, {0x274C, 0x274C},
+	{0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2763, 0x2764},
+	{0x2795, 0x2797}, {0x27A1, 0x27A1}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2934, 0x2935},
+	{0x2B05, 0x2B07}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55},
+	{0x3030, 0x3030}, {0x303D, 0x303D}, {0x3297, 0x3297}, {0x3299, 0x3299},
+	{0x1F000, 0x1F0FF}, {0x1F10D, 0x1F1AD}, {0x1F201, 0x1F2FF},
+	{0x1F300, 0x1F3FA}, {0x1F400, 0x1F64F}, {0x1F680, 0x1F6FF},
+	{0x1F7E0, 0x1F7F0}, {0x1F900, 0x1FAFF},
+}
+
+// IsEmoji reports whether s is exactly one emoji grapheme cluster, such as
+// "👍", "❤️", "👍🏽", "🇳🇱", "1️⃣" or "👩‍💻". Text around the emoji, or a
+// second emoji, makes it fail.
+func IsEmoji(s string) bool {
+	if s == "" || len(s) > MaxLength || !utf8.ValidString(s) {
+		return false
+	}
+
+	runes := []rune(s)
+
+	// Flags and keycaps never take part in ZWJ sequences
+	if n, ok := flag(runes); ok {
+		return n == len(runes)
+	}
+	if n, ok := keycap(runes); ok {
+		return n == len(runes)
+	}
+
+	pos := 0
+	for component := 0; component < maxZWJComponents; component++ {
+		n, ok := element(runes[pos:])
+		if !ok {
+			return false
+		}
+		pos += n
+
+		if pos == len(runes) {
+			return true
+		}
+		if runes[pos] != zwj {
+			return false
+		}
+		pos++
+	}
+
+	return false
+}
+
+// Normalize drops emoji presentation selectors, so "❤" and "❤️" compare
+// equal. Clients differ on whether they send the selector.
+func Normalize(s string) string {
+	return strings.ReplaceAll(s, string(variationSelect), "")
+}
+
+// element matches one pictograph with its optional presentation selector
+// or skin tone, or a tag sequence such as the flag of Scotland.
+func element(runes []rune) (int, bool) {
+	if len(runes) == 0 || !isPictographic(runes[0]) {
+		return 0, false
+	}
+
+	if runes[0] == blackFlag && len(runes) > 1 && isTag(runes[1]) {
+		return tagSequence(runes)
+	}
+
+	n := 1
+	if n < len(runes) && runes[n] == variationSelect {
+		n++
+	} else if n < len(runes) && runes[n] == textSelect {
+		// Explicitly asks for the non-emoji glyph
+		return 0, false
+	} else if n < len(runes) && isSkinTone(runes[n]) {
+		n++
+	}
+
+	return n, true
+}
+
+func tagSequence(runes []rune) (int, bool) {
+	n := 1
+	for n < len(runes) && isTag(runes[n]) {
+		n++
+	}
+	if n < len(runes) && runes[n] == tagCancel {
+		return n + 1, true
+	}
+	return 0, false
+}
+
+func flag(runes []rune) (int, bool) {
+	if len(runes) >= 2 && isRegional(runes[0]) && isRegional(runes[1]) {
+		return 2, true
+	}
+	return 0, false
+}
+
+func keycap(runes []rune) (int, bool) {
+	if len(runes) < 2 || !(runes[0] >= '0' && runes[0] <= '9' || runes[0] == '#' || runes[0] == '*') {
+		return 0, false
+	}
+
+	n := 1
+	if runes[n] == variationSelect {
+		n++
+	}
+	if n < len(runes) && runes[n] == combiningKeycap {
+		return n + 1, true
+	}
+	return 0, false
+}
+
+func isPictographic(r rune) bool {
+	for _, span := range pictographic {
+		if r >= span[0] && r <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegional(r rune) bool {
+	return r >= firstRegional && r <= lastRegional
+}
+
+func isSkinTone(r rune) bool {
+	return r >= firstSkinTone && r <= lastSkinTone
+}
+
+func isTag(r rune) bool {
+	return r >= firstTag && r <= lastTag
+}
@@ -0,0 +1,94 @@
+package emoji
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want bool
+	}{
+		// Single code points
+		{"thumbs up", "👍", true},
+		{"heart without selector", "❤", true},
+		{"heart with selector", "❤\uFE0F", true},
+		{"copyright with selector", "©\uFE0F", true},
+		{"face", "\U0001F602", true},
+
+		// Skin tones
+		{"thumbs up with skin tone", "\U0001F44D\U0001F3FD", true},
+		{"skin tone alone", "\U0001F3FB", false},
+		{"two skin tones", "\U0001F44D\U0001F3FD\U0001F3FD", false},
+
+		// ZWJ sequences
+		{"technologist", "\U0001F469\u200D\U0001F4BB", true},
+		{"technologist with skin tone", "\U0001F469\U0001F3FD\u200D\U0001F4BB", true},
+		{"family", "\U0001F468\u200D\U0001F469\u200D\U0001F467\u200D\U0001F466", true},
+		{"rainbow flag", "\U0001F3F3\uFE0F\u200D\U0001F308", true},
+		{"leading joiner", "\u200D\U0001F44D", false},
+		{"trailing joiner", "\U0001F44D\u200D", false},
+		{"joiner to text", "\U0001F469\u200Da", false},
+		{"too many components", strings.Repeat("\U0001F600\u200D", 10) + "\U0001F600", false},
+
+		// Flags
+		{"country flag", "\U0001F1F3\U0001F1F1", true},
+		{"lone regional indicator", "\U0001F1F3", false},
+		{"flag and a stray indicator", "\U0001F1F3\U0001F1F1\U0001F1E7", false},
+		{"two flags", "\U0001F1F3\U0001F1F1\U0001F1E7\U0001F1EA", false},
+		{"subdivision flag", "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", true},
+		{"tags without cancel", "\U0001F3F4\U000E0067\U000E0062\U000E0073", false},
+		{"black flag", "\U0001F3F4", true},
+
+		// Keycaps
+		{"keycap", "1\uFE0F\u20E3", true},
+		{"keycap without selector", "#\u20E3", true},
+		{"asterisk keycap", "*\uFE0F\u20E3", true},
+		{"digit alone", "1", false},
+		{"digit with selector only", "1\uFE0F", false},
+		{"letter keycap", "a\u20E3", false},
+
+		// Text presentation
+		{"heart as text", "❤\uFE0E", false},
+		{"thumbs up as text", "\U0001F44D\uFE0E", false},
+		{"symbol that is not an emoji", "★", false},
+
+		// More than one emoji, or text
+		{"two emoji", "\U0001F44D\U0001F44D", false},
+		{"emoji and text", "\U0001F44D ok", false},
+		{"text and emoji", "ok\U0001F44D", false},
+		{"trailing space", "\U0001F44D ", false},
+		{"letter", "a", false},
+		{"empty", "", false},
+		{"invalid UTF-8", "\xff", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsEmoji(tt.s); got != tt.want {
+				t.Errorf("IsEmoji(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"❤\uFE0F", "❤"},
+		{"❤", "❤"},
+		{"1\uFE0F\u20E3", "1\u20E3"},
+		{"\U0001F3F3\uFE0F\u200D\U0001F308", "\U0001F3F3\u200D\U0001F308"},
+		{"\U0001F44D\U0001F3FD", "\U0001F44D\U0001F3FD"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.s); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Package formatting parses WhatsApp-style markup (*bold*, _italic_,
+// ~strike~, `code`, ```blocks```, "> " quotes and lists) into plain text
+// plus entity spans, so every client renders a message the same way.
+//
+// Offsets and lengths count UTF-16 code units, as JavaScript, Java and
+// Swift (NSString) strings do, and point into the plain text.
+//
+// Markup that does not pair up is left as typed:
+//   - An opening marker must start the text or follow a character that is
+//     not a letter or digit, and be followed by a non-space; a closing
+//     marker mirrors that. So snake_case_names and 2*3*4 stay as they are.
+//   - A marker next to the same marker, as in **this**, is never markup.
+//   - The first closing marker that qualifies ends the span, and a span
+//     cannot be empty or cross a line break.
+//   - Bold, italic and strikethrough nest inside each other. Nothing is
+//     parsed inside code or links, though markers around a link count.
+//   - Quote and list markers only count at the start of a line. Quote
+//     markers are removed; list markers stay in the text. Consecutive
+//     lines of the same kind share one entity.
+package formatting
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type Type string
+
+const (
+	Bold          Type = "bold"
+	Italic        Type = "italic"
+	Strikethrough Type = "strikethrough"
+	Code          Type = "code" // Inline, between single backticks
+	Pre           Type = "pre"  // Block, between triple backticks
+	Quote         Type = "quote"
+	BulletList    Type = "bullet_list"
+	NumberedList  Type = "numbered_list"
+)
+
+type Entity struct {
+	Type   Type
+	Offset int
+	Length int
+}
+
+var inlineMarkers = map[rune]Type{
+	'*': Bold,
+	'_': Italic,
+	'~': Strikethrough,
+	'`': Code,
+}
+
+const fence = "```"
+
+type parser struct {
+	out       []rune
+	pos       int // UTF-16 length of out
+	entities  []Entity
+	lastBlock int // Index of the latest quote or list entity, or -1
+}
+
+// Parse returns text without its markup and the entities describing it,
+// outermost first. Text without markup comes back unchanged with no
+// entities.
+func Parse(text string) (string, []Entity) {
+	runes := []rune(text)
+	p := &parser{out: make([]rune, 0, len(runes)), lastBlock: -1}
+
+	// Code blocks come first since nothing inside them is markup, and they
+	// may span lines
+	segmentStart := 0
+	for i := 0; i < len(runes); {
+		if !isFence(runes, i) || !canOpen(runes, i) {
+			i++
+			continue
+		}
+
+		end := findFence(runes, i+len(fence))
+		if end < 0 {
+			break
+		}
+
+		content := trimNewlines(runes[i+len(fence) : end])
+		if len(content) == 0 {
+			i += len(fence)
+			continue
+		}
+
+		p.text(runes[segmentStart:i], isLineStart(runes, segmentStart))
+		start := p.pos
+		p.emit(content...)
+		p.add(Pre, start)
+
+		i = end + len(fence)
+		segmentStart = i
+	}
+	p.text(runes[segmentStart:], isLineStart(runes, segmentStart))
+
+	if len(p.entities) == 0 {
+		return text, nil
+	}
+
+	// Inner spans were added before the ones around them; reversing first
+	// keeps outer spans ahead when two cover the same text
+	slices.Reverse(p.entities)
+	sort.SliceStable(p.entities, func(i, j int) bool {
+		if p.entities[i].Offset != p.entities[j].Offset {
+			return p.entities[i].Offset < p.entities[j].Offset
+		}
+		return p.entities[i].Length > p.entities[j].Length
+	})
+
+	return string(p.out), p.entities
+}
+
+// Strip returns text without its markup, for indexing and previews.
+func Strip(text string) string {
+	plain, _ := Parse(text)
+	return plain
+}
+
+// text handles a stretch between code blocks line by line. lineStart says
+// whether its first line begins a line of the message.
+func (p *parser) text(runes []rune, lineStart bool) {
+	first := true
+	lineBegin := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && runes[i] != '\n' {
+			continue
+		}
+		p.line(runes[lineBegin:i], lineStart || !first)
+		if i < len(runes) {
+			p.emit('\n')
+		}
+		first = false
+		lineBegin = i + 1
+	}
+}
+
+func (p *parser) line(runes []rune, lineStart bool) {
+	if !lineStart {
+		p.inline(runes)
+		return
+	}
+
+	start := p.pos
+	switch {
+	case hasPrefix(runes, "> "):
+		p.inline(runes[2:])
+		p.block(Quote, start)
+	case hasPrefix(runes, "* ") || hasPrefix(runes, "- "):
+		p.emit(runes[:2]...)
+		p.inline(runes[2:])
+		p.block(BulletList, start)
+	case numberedPrefix(runes) > 0:
+		n := numberedPrefix(runes)
+		p.emit(runes[:n]...)
+		p.inline(runes[n:])
+		p.block(NumberedList, start)
+	default:
+		p.inline(runes)
+	}
+}
+
+func (p *parser) inline(runes []rune) {
+	// Once a marker finds no closer, none later on the line will either;
+	// remembering that keeps long lines of stray markers linear
+	unmatched := make(map[rune]bool)
+
+	for i := 0; i < len(runes); i++ {
+		if n := linkLength(runes, i); n > 0 {
+			p.emit(runes[i : i+n]...)
+			i += n - 1
+			continue
+		}
+
+		entityType, ok := inlineMarkers[runes[i]]
+		if !ok || unmatched[runes[i]] || !canOpen(runes, i) {
+			p.emit(runes[i])
+			continue
+		}
+
+		end := findClose(runes, i)
+		if end < 0 {
+			if !isDoubled(runes, i) {
+				unmatched[runes[i]] = true
+			}
+			p.emit(runes[i])
+			continue
+		}
+
+		start := p.pos
+		if entityType == Code {
+			p.emit(runes[i+1 : end]...)
+		} else {
+			p.inline(runes[i+1 : end])
+		}
+		p.add(entityType, start)
+		i = end
+	}
+}
+
+// block adds a quote or list entity, extending the one on the line above
+// when it is the same kind.
+func (p *parser) block(entityType Type, start int) {
+	if p.lastBlock >= 0 {
+		last := &p.entities[p.lastBlock]
+		if last.Type == entityType && last.Offset+last.Length+1 == start {
+			last.Length = p.pos - last.Offset
+			return
+		}
+	}
+
+	p.add(entityType, start)
+	p.lastBlock = len(p.entities) - 1
+}
+
+func (p *parser) add(entityType Type, start int) {
+	p.entities = append(p.entities, Entity{Type: entityType, Offset: start, Length: p.pos - start})
+}
+
+func (p *parser) emit(runes ...rune) {
+	for _, r := range runes {
+		p.out = append(p.out, r)
+		p.pos += utf16Len(r)
+	}
+}
+
+// findClose finds the marker closing the one at open on the same line.
+func findClose(runes []rune, open int) int {
+	marker := runes[open]
+	if isDoubled(runes, open) {
+		return -1
+	}
+
+	for i := open + 2; i < len(runes); i++ {
+		if runes[i] == '\n' {
+			return -1
+		}
+		if n := linkLength(runes, i); n > 0 {
+			i += n - 1
+			continue
+		}
+		if runes[i] == marker && canClose(runes, i) && !isDoubled(runes, i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isDoubled reports whether the marker at i touches another of its kind.
+func isDoubled(runes []rune, i int) bool {
+	return i > 0 && runes[i-1] == runes[i] || i+1 < len(runes) && runes[i+1] == runes[i]
+}
+
+func findFence(runes []rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if isFence(runes, i) && (i+len(fence) == len(runes) || !isWordChar(runes[i+len(fence)])) {
+			return i
+		}
+	}
+	return -1
+}
+
+func canOpen(runes []rune, i int) bool {
+	if i > 0 && isWordChar(runes[i-1]) {
+		return false
+	}
+	return i+1 < len(runes) && !unicode.IsSpace(runes[i+1])
+}
+
+func canClose(runes []rune, i int) bool {
+	if unicode.IsSpace(runes[i-1]) {
+		return false
+	}
+	return i+1 == len(runes) || !isWordChar(runes[i+1])
+}
+
+// linkLength returns the length of an http(s) link starting at i, or 0.
+// Trailing punctuation and markers are left off so they can still close a
+// span around the link.
+func linkLength(runes []rune, i int) int {
+	if runes[i] != 'h' && runes[i] != 'H' || i > 0 && isWordChar(runes[i-1]) {
+		return 0
+	}
+	scheme := strings.ToLower(string(runes[i:min(i+len("https://"), len(runes))]))
+	if !strings.HasPrefix(scheme, "http://") && scheme != "https://" {
+		return 0
+	}
+
+	end := i
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	for end > i && strings.ContainsRune(".,;:!?'\")]}*_~`", runes[end-1]) {
+		end--
+	}
+	return end - i
+}
+
+func isFence(runes []rune, i int) bool {
+	return hasPrefix(runes[i:], fence)
+}
+
+func isLineStart(runes []rune, i int) bool {
+	return i == 0 || runes[i-1] == '\n'
+}
+
+// numberedPrefix returns the length of a "1. " style marker, or 0.
+func numberedPrefix(runes []rune) int {
+	digits := 0
+	for digits < len(runes) && digits < 3 && runes[digits] >= '0' && runes[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || !hasPrefix(runes[digits:], ". ") {
+		return 0
+	}
+	return digits + 2
+}
+
+// trimNewlines drops one line break after an opening fence and one before
+// the closing fence, so ```\ncode\n``` holds just "code".
+func trimNewlines(runes []rune) []rune {
+	if len(runes) > 0 && runes[0] == '\n' {
+		runes = runes[1:]
+	}
+	if len(runes) > 0 && runes[len(runes)-1] == '\n' {
+		runes = runes[:len(runes)-1]
+	}
+	return runes
+}
+
+func hasPrefix(runes []rune, prefix string) bool {
+	i := 0
+	for _, r := range prefix {
+		if i >= len(runes) || runes[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,142 @@
+package formatting
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		plain    string
+		entities []Entity
+	}{
+		// Each marker on its own
+		{"bold", "*bold*", "bold", []Entity{{Bold, 0, 4}}},
+		{"italic", "_it_", "it", []Entity{{Italic, 0, 2}}},
+		{"strikethrough", "~gone~", "gone", []Entity{{Strikethrough, 0, 4}}},
+		{"inline code", "run `go test`", "run go test", []Entity{{Code, 4, 7}}},
+		{"code block", "```\nfmt.Println()\n```", "fmt.Println()", []Entity{{Pre, 0, 13}}},
+		{"quote", "> quoted", "quoted", []Entity{{Quote, 0, 6}}},
+		{"bullet with star", "* item", "* item", []Entity{{BulletList, 0, 6}}},
+		{"bullet with dash", "- item", "- item", []Entity{{BulletList, 0, 6}}},
+		{"numbered", "1. first", "1. first", []Entity{{NumberedList, 0, 8}}},
+		{"several spans", "_a_ and *b*", "a and b", []Entity{{Italic, 0, 1}, {Bold, 6, 1}}},
+
+		// Nesting and doubled markers
+		{"nested", "*_x_*", "x", []Entity{{Bold, 0, 1}, {Italic, 0, 1}}},
+		{"nested inside text", "*a _b_ c*", "a b c", []Entity{{Bold, 0, 5}, {Italic, 2, 1}}},
+		{"nothing inside code", "`*not bold*`", "*not bold*", []Entity{{Code, 0, 10}}},
+		{"inline inside quote", "> *b*", "b", []Entity{{Quote, 0, 1}, {Bold, 0, 1}}},
+		{"doubled markers", "**x**", "**x**", nil},
+		{"doubled closer", "*x**", "*x**", nil},
+		{"three levels", "~_*x*_~", "x", []Entity{{Strikethrough, 0, 1}, {Italic, 0, 1}, {Bold, 0, 1}}},
+		{"siblings inside a span", "*a ~b~ _c_*", "a b c", []Entity{{Bold, 0, 5}, {Strikethrough, 2, 1}, {Italic, 4, 1}}},
+		{"crossing spans", "*a _b* c_", "a _b c_", []Entity{{Bold, 0, 4}}},
+		{"crossing spans reversed", "_a *b_ c*", "a *b c*", []Entity{{Italic, 0, 4}}},
+
+		// Markers inside words
+		{"snake case", "snake_case_name", "snake_case_name", nil},
+		{"multiplication", "2*3*4", "2*3*4", nil},
+		{"spaced markers", "a * b * c", "a * b * c", nil},
+
+		// Punctuation around markers
+		{"in parentheses", "(*bold*)", "(bold)", []Entity{{Bold, 1, 4}}},
+		{"before a full stop", "*bold*.", "bold.", []Entity{{Bold, 0, 4}}},
+		{"in quotation marks", `"_quoted_"`, `"quoted"`, []Entity{{Italic, 1, 6}}},
+		{"around a comma", "*a*,*b*", "a,b", []Entity{{Bold, 0, 1}, {Bold, 2, 1}}},
+		{"after inverted exclamation", "¡*hola*!", "¡hola!", []Entity{{Bold, 1, 4}}},
+
+		// Links
+		{"markers inside a link", "https://example.com/*path*/x", "https://example.com/*path*/x", nil},
+		{"tildes inside a link", "http://example.com/~user~", "http://example.com/~user~", nil},
+		{"underscores inside a link", "see https://example.com/a_b_c ok", "see https://example.com/a_b_c ok", nil},
+		{"upper-case scheme", "HTTPS://EXAMPLE.COM/*a*", "HTTPS://EXAMPLE.COM/*a*", nil},
+		{"bold link", "*https://example.com*", "https://example.com", []Entity{{Bold, 0, 19}}},
+		{"link ending a span", "_see https://example.com/a_", "see https://example.com/a", []Entity{{Italic, 0, 25}}},
+		{"marker in a link does not close", "*go https://x.com/a*b now*", "go https://x.com/a*b now", []Entity{{Bold, 0, 24}}},
+		{"span after a link", "https://x.com/*a* *b*", "https://x.com/*a* b", []Entity{{Bold, 18, 1}}},
+
+		// Spans that do not close
+		{"unclosed", "*bold", "*bold", nil},
+		{"empty span", "**", "**", nil},
+		{"across a newline", "*a\nb*", "*a\nb*", nil},
+		{"unclosed then closed", "*a _b_", "*a b", []Entity{{Italic, 3, 1}}},
+
+		// Code block fences
+		{"fence on one line", "```code```", "code", []Entity{{Pre, 0, 4}}},
+		{"fence with trailing newline", "```\ncode\n```\n", "code\n", []Entity{{Pre, 0, 4}}},
+		{"fence mid-line", "x ```a\nb``` y", "x a\nb y", []Entity{{Pre, 2, 3}}},
+		{"fence keeps markup", "```*a*```", "*a*", []Entity{{Pre, 0, 3}}},
+		{"unclosed fence", "```code", "```code", nil},
+		{"empty fence", "``````", "``````", nil},
+
+		// Offsets count UTF-16 code units
+		{"after emoji", "😀 *hi*", "😀 hi", []Entity{{Bold, 3, 2}}},
+		{"around emoji", "*😀*", "😀", []Entity{{Bold, 0, 2}}},
+		{"after two emoji", "👍🏽 _x_", "👍🏽 x", []Entity{{Italic, 5, 1}}},
+		{"after BMP characters", "é *b*", "é b", []Entity{{Bold, 2, 1}}},
+		{"emoji in nested spans", "*😀 _👍🏽_*", "😀 👍🏽", []Entity{{Bold, 0, 7}, {Italic, 3, 4}}},
+		{"emoji before a nested span", "_a 😀 *b*_", "a 😀 b", []Entity{{Italic, 0, 6}, {Bold, 5, 1}}},
+		{"emoji outside and inside", "😀 *a _👍🏽 b_*", "😀 a 👍🏽 b", []Entity{{Bold, 3, 8}, {Italic, 5, 6}}},
+		{"emoji in a quote", "> 😀 *x*", "😀 x", []Entity{{Quote, 0, 4}, {Bold, 3, 1}}},
+
+		// Quote and list lines
+		{"quote lines merge", "> a\n> b", "a\nb", []Entity{{Quote, 0, 3}}},
+		{"bullet lines merge", "* a\n- b", "* a\n- b", []Entity{{BulletList, 0, 7}}},
+		{"numbered lines merge", "1. a\n2. b", "1. a\n2. b", []Entity{{NumberedList, 0, 9}}},
+		{"different blocks stay apart", "> a\n* b", "a\n* b", []Entity{{Quote, 0, 1}, {BulletList, 2, 3}}},
+		{"plain line splits blocks", "> a\nb\n> c", "a\nb\nc", []Entity{{Quote, 0, 1}, {Quote, 4, 1}}},
+		{"quote marker mid-line", "a > b", "a > b", nil},
+		{"decimal is not a list", "1.5 litres", "1.5 litres", nil},
+		{"block after code block", "```x```\n> q", "x\nq", []Entity{{Pre, 0, 1}, {Quote, 2, 1}}},
+		{"spans in a quote", "> *a* and _b_", "a and b", []Entity{{Quote, 0, 7}, {Bold, 0, 1}, {Italic, 6, 1}}},
+		{"span on a later quote line", "> a\n> *b*", "a\nb", []Entity{{Quote, 0, 3}, {Bold, 2, 1}}},
+		{"span in a bullet", "* item with _it_", "* item with it", []Entity{{BulletList, 0, 14}, {Italic, 12, 2}}},
+		{"code in a bullet", "- `code` item", "- code item", []Entity{{BulletList, 0, 11}, {Code, 2, 4}}},
+		{"span in a numbered item", "1. *first*", "1. first", []Entity{{NumberedList, 0, 8}, {Bold, 3, 5}}},
+
+		// No markup at all
+		{"plain text", "hello world", "hello world", nil},
+		{"empty", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, entities := Parse(tt.text)
+			if plain != tt.plain {
+				t.Errorf("Parse(%q) text = %q, want %q", tt.text, plain, tt.plain)
+			}
+			if !slices.Equal(entities, tt.entities) {
+				t.Errorf("Parse(%q) entities = %v, want %v", tt.text, entities, tt.entities)
+			}
+			if tt.entities == nil && entities != nil {
+				t.Errorf("Parse(%q) entities = %v, want nil", tt.text, entities)
+			}
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"*Hello* _there_", "Hello there"},
+		{"run `*x*` now", "run *x* now"},
+		{"```\nfunc main() {}\n```", "func main() {}"},
+		{"```*a*```", "*a*"},
+		{"> *quoted* text", "quoted text"},
+		{"> a\n> b", "a\nb"},
+		{"```\ncode\n```\n> q", "code\nq"},
+		{"* item", "* item"},
+		{"plain", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := Strip(tt.text); got != tt.want {
+			t.Errorf("Strip(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
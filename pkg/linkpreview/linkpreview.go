@@ -0,0 +1,343 @@
+// Package linkpreview fetches web pages and extracts the OpenGraph and
+// Twitter card metadata used to render link previews.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+var (
+	ErrBlockedAddress   = errors.New("address is not allowed")
+	ErrUnsupportedURL   = errors.New("only http and https links can be previewed")
+	ErrUnsupportedType  = errors.New("unsupported content type")
+	ErrResponseTooLarge = errors.New("response is too large")
+)
+
+// Page is the metadata found on a page. ImageURL is absolute.
+type Page struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher loads pages and preview images. HTTPFetcher is the production
+// implementation; tests can supply their own or an HTTPFetcher with
+// AllowPrivateNetworks set to reach a local server.
+type Fetcher interface {
+	FetchPage(ctx context.Context, rawURL string) (*Page, error)
+	FetchImage(ctx context.Context, rawURL string) ([]byte, error)
+}
+
+type Options struct {
+	Timeout      time.Duration // Per request, including redirects
+	MaxPageSize  int64         // Bytes of HTML read; metadata lives in <head>
+	MaxImageSize int64
+	MaxRedirects int
+	UserAgent    string
+
+	// AllowPrivateNetworks disables the loopback/private address and port
+	// checks. Never enable it in production.
+	AllowPrivateNetworks bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Timeout:      5 * time.Second,
+		MaxPageSize:  512 << 10,
+		MaxImageSize: 5 << 20,
+		MaxRedirects: 5,
+		UserAgent:    "bro-chat-linkpreview/1.0",
+	}
+}
+
+type HTTPFetcher struct {
+	client *http.Client
+	opts   Options
+}
+
+func NewHTTPFetcher(opts Options) *HTTPFetcher {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivateNetworks {
+		// Checked after DNS resolution, so redirects and rebinding tricks
+		// cannot reach internal hosts either
+		dialer.Control = blockPrivateAddresses
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+
+	return &HTTPFetcher{client: client, opts: opts}
+}
+
+func (f *HTTPFetcher) FetchPage(ctx context.Context, rawURL string) (*Page, error) {
+	resp, err := f.get(ctx, rawURL, "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "html") {
+		return nil, ErrUnsupportedType
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.opts.MaxPageSize), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	page := parse(body)
+	page.URL = resp.Request.URL.String()
+
+	if page.ImageURL != "" {
+		page.ImageURL = resolve(resp.Request.URL, page.ImageURL)
+	}
+	if page.SiteName == "" {
+		page.SiteName = resp.Request.URL.Hostname()
+	}
+
+	return page, nil
+}
+
+func (f *HTTPFetcher) FetchImage(ctx context.Context, rawURL string) ([]byte, error) {
+	resp, err := f.get(ctx, rawURL, "image/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, ErrUnsupportedType
+	}
+	if resp.ContentLength > f.opts.MaxImageSize {
+		return nil, ErrResponseTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.opts.MaxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > f.opts.MaxImageSize {
+		return nil, ErrResponseTooLarge
+	}
+
+	return data, nil
+}
+
+func (f *HTTPFetcher) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedURL
+	}
+	if u.Hostname() == "" {
+		return ErrUnsupportedURL
+	}
+	return nil
+}
+
+// blockPrivateAddresses refuses connections to anything but public unicast
+// addresses on the standard web ports.
+func blockPrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if port != "80" && port != "443" {
+		return ErrBlockedAddress
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+var nonPublicNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, can reach IPv4 internals
+)
+
+// IsPublicIP reports whether ip is a globally routable unicast address.
+func IsPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// parse reads <head> metadata, preferring OpenGraph, then Twitter cards,
+// then the plain <title> and description.
+func parse(r io.Reader) *Page {
+	meta := map[string]string{}
+	var title string
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		if tokenType == html.EndTagToken && token.Data == "head" || tokenType == html.StartTagToken && token.Data == "body" {
+			break
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		switch token.Data {
+		case "title":
+			if title == "" && tokenizer.Next() == html.TextToken {
+				title = string(tokenizer.Text())
+			}
+		case "meta":
+			var key, content string
+			for _, attr := range token.Attr {
+				switch strings.ToLower(attr.Key) {
+				case "property", "name":
+					key = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if key != "" && content != "" {
+				if _, seen := meta[key]; !seen {
+					meta[key] = content
+				}
+			}
+		}
+	}
+
+	return &Page{
+		Title:       truncate(first(meta["og:title"], meta["twitter:title"], title), maxTitleLength),
+		Description: truncate(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		ImageURL:    first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]),
+		SiteName:    truncate(meta["og:site_name"], maxTitleLength),
+	}
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func truncate(value string, limit int) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	runes := []rune(value)
+	return string(runes[:limit-1]) + "…"
+}
+
+func resolve(base *url.URL, ref string) string {
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,274 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFetcher(modify func(*Options)) *HTTPFetcher {
+	opts := DefaultOptions()
+	opts.AllowPrivateNetworks = true
+	if modify != nil {
+		modify(&opts)
+	}
+	return NewHTTPFetcher(opts)
+}
+
+func serveHTML(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestFetchPageMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     string
+		want     Page // URL is not compared; SiteName "host" means the server's host
+		imageRel bool // ImageURL is relative to the server
+	}{
+		{
+			name: "OpenGraph",
+			head: `<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:image" content="/images/cover.png">
+				<meta property="og:site_name" content="Example">
+				<meta name="twitter:title" content="Twitter title">
+				<title>Page title</title>`,
+			want:     Page{Title: "OG title", Description: "OG description", ImageURL: "/images/cover.png", SiteName: "Example"},
+			imageRel: true,
+		},
+		{
+			name: "Twitter card",
+			head: `<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta name="twitter:image" content="https://cdn.example.com/card.jpg">
+				<title>Page title</title>`,
+			want: Page{Title: "Twitter title", Description: "Twitter description", ImageURL: "https://cdn.example.com/card.jpg", SiteName: "host"},
+		},
+		{
+			name: "plain title and description",
+			head: `<title>  Page
+				title </title>
+				<meta name="description" content="Described">`,
+			want: Page{Title: "Page title", Description: "Described", SiteName: "host"},
+		},
+		{
+			name: "secure image preferred",
+			head: `<meta property="og:image" content="http://example.com/a.png">
+				<meta property="og:image:secure_url" content="https://example.com/a.png">`,
+			want: Page{ImageURL: "https://example.com/a.png", SiteName: "host"},
+		},
+		{
+			name: "metadata in body is ignored",
+			head: `</head><body><meta property="og:title" content="Too late">`,
+			want: Page{SiteName: "host"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveHTML("<html><head>" + tt.head + "</head><body></body></html>")
+			defer server.Close()
+
+			page, err := newTestFetcher(nil).FetchPage(context.Background(), server.URL+"/article")
+			if err != nil {
+				t.Fatalf("FetchPage: %v", err)
+			}
+
+			want := tt.want
+			want.URL = server.URL + "/article"
+			if want.SiteName == "host" {
+				want.SiteName = "127.0.0.1"
+			}
+			if tt.imageRel {
+				want.ImageURL = server.URL + want.ImageURL
+			}
+			if *page != want {
+				t.Errorf("page = %+v, want %+v", *page, want)
+			}
+		})
+	}
+}
+
+func TestFetchPageTruncatesLongText(t *testing.T) {
+	server := serveHTML(`<head><title>` + strings.Repeat("a", maxTitleLength+50) + `</title></head>`)
+	defer server.Close()
+
+	page, err := newTestFetcher(nil).FetchPage(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("FetchPage: %v", err)
+	}
+	if got := len([]rune(page.Title)); got != maxTitleLength {
+		t.Errorf("title has %d characters, want %d", got, maxTitleLength)
+	}
+	if !strings.HasSuffix(page.Title, "…") {
+		t.Errorf("title %q does not end with an ellipsis", page.Title)
+	}
+}
+
+func TestFetchPageSizeCap(t *testing.T) {
+	padding := strings.Repeat("<!-- padding -->", 100)
+	server := serveHTML(`<head>` + padding + `<title>Past the cap</title></head>`)
+	defer server.Close()
+
+	fetcher := newTestFetcher(func(opts *Options) { opts.MaxPageSize = int64(len(padding)) })
+	page, err := fetcher.FetchPage(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("FetchPage: %v", err)
+	}
+	if page.Title != "" {
+		t.Errorf("read past the size cap, got title %q", page.Title)
+	}
+}
+
+func TestFetchImageSizeCap(t *testing.T) {
+	image := strings.Repeat("x", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		if r.URL.Path == "/chunked" {
+			// No Content-Length, so only reading tells the size
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(image))
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/sized", "/chunked"} {
+		fetcher := newTestFetcher(func(opts *Options) { opts.MaxImageSize = int64(len(image)) - 1 })
+		if _, err := fetcher.FetchImage(context.Background(), server.URL+path); !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("%s: err = %v, want %v", path, err, ErrResponseTooLarge)
+		}
+
+		fetcher = newTestFetcher(func(opts *Options) { opts.MaxImageSize = int64(len(image)) })
+		data, err := fetcher.FetchImage(context.Background(), server.URL+path)
+		if err != nil {
+			t.Fatalf("%s: FetchImage: %v", path, err)
+		}
+		if string(data) != image {
+			t.Errorf("%s: got %d bytes, want %d", path, len(data), len(image))
+		}
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher(func(opts *Options) { opts.Timeout = 50 * time.Millisecond })
+
+	started := time.Now()
+	_, err := fetcher.FetchPage(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("FetchPage succeeded, want a timeout")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("gave up after %v, want about 50ms", elapsed)
+	}
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := serveHTML(`<head><title>Internal</title></head>`)
+	defer server.Close()
+
+	fetcher := NewHTTPFetcher(DefaultOptions())
+	if _, err := fetcher.FetchPage(context.Background(), server.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("FetchPage: err = %v, want %v", err, ErrBlockedAddress)
+	}
+	if _, err := fetcher.FetchImage(context.Background(), server.URL+"/image.png"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("FetchImage: err = %v, want %v", err, ErrBlockedAddress)
+	}
+}
+
+// The dial check also covers redirects and DNS answers, which a local test
+// server cannot produce, so it is checked on its own too.
+func TestBlockPrivateAddresses(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:80", "10.0.0.1:443", "[::1]:443", "169.254.169.254:80", "93.184.216.34:8080"} {
+		if err := blockPrivateAddresses("tcp", address, nil); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("blockPrivateAddresses(%q) = %v, want %v", address, err, ErrBlockedAddress)
+		}
+	}
+	if err := blockPrivateAddresses("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("blockPrivateAddresses(public) = %v, want nil", err)
+	}
+}
+
+func TestFetchRejects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/missing":
+			http.NotFound(w, r)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher(nil)
+	tests := []struct {
+		url  string
+		want error // Nil when any error will do
+	}{
+		{"ftp://example.com/file", ErrUnsupportedURL},
+		{"http:///no-host", ErrUnsupportedURL},
+		{server.URL + "/json", ErrUnsupportedType},
+		{server.URL + "/missing", nil},
+		{server.URL + "/loop", nil},
+	}
+
+	for _, tt := range tests {
+		_, err := fetcher.FetchPage(context.Background(), tt.url)
+		if err == nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("FetchPage(%q): err = %v, want %v", tt.url, err, tt.want)
+		}
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -20,6 +22,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const maxLinkImagePixels = 40_000_000
+
 type FileUploadService struct {
 	uploadDir    string
 	thumbnailDir string
@@ -64,8 +68,23 @@ func (s *FileUploadService) UploadFile(file *multipart.FileHeader, userID primit
 		return nil, fmt.Errorf("file too large: %d bytes, max allowed: %d bytes", file.Size, s.maxFileSize)
 	}
 
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return s.SaveFile(file.Filename, src, userID)
+}
+
+// SaveFile stores a file read from src under the given original name, as
+// UploadFile does for form uploads. Files over the size limit are rejected
+// once the limit is reached.
+func (s *FileUploadService) SaveFile(originalName string, src io.Reader, userID primitive.ObjectID) (*UploadResult, error) {
+	originalName = filepath.Base(originalName)
+
 	// Get file extension and determine type
-	ext := strings.ToLower(filepath.Ext(file.Filename))
+	ext := strings.ToLower(filepath.Ext(originalName))
 	mediaType := s.getMediaType(ext)
 	if mediaType == "" {
 		return nil, fmt.Errorf("unsupported file type: %s", ext)
@@ -73,30 +92,29 @@ func (s *FileUploadService) UploadFile(file *multipart.FileHeader, userID primit
 
 	// Generate unique filename
 	timestamp := time.Now().Unix()
-	fileName := fmt.Sprintf("%s_%d_%s", userID.Hex(), timestamp, file.Filename)
+	fileName := fmt.Sprintf("%s_%d_%s", userID.Hex(), timestamp, originalName)
 	filePath := filepath.Join(s.uploadDir, fileName)
 
-	// Save file
-	src, err := file.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer src.Close()
-
 	dst, err := os.Create(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer dst.Close()
 
-	if _, err = io.Copy(dst, src); err != nil {
+	size, err := io.Copy(dst, io.LimitReader(src, s.maxFileSize+1))
+	if err != nil {
+		os.Remove(filePath)
 		return nil, err
 	}
+	if size > s.maxFileSize {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("file too large, max allowed: %d bytes", s.maxFileSize)
+	}
 
 	result := &UploadResult{
 		FileName:  fileName,
 		FileURL:   fmt.Sprintf("/uploads/%s", fileName),
-		FileSize:  file.Size,
+		FileSize:  size,
 		MediaType: mediaType,
 	}
 
@@ -200,6 +218,43 @@ func (s *FileUploadService) createThumbnail(originalPath string, result *UploadR
 	return nil
 }
 
+// SaveLinkThumbnail stores a thumbnail of a link preview image under a name
+// derived from key, so refetching the same link overwrites the old copy.
+func (s *FileUploadService) SaveLinkThumbnail(key string, data []byte) (string, error) {
+	// Check the size before decoding so a small file cannot expand into a
+	// huge bitmap
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if config.Width*config.Height > maxLinkImagePixels {
+		return "", fmt.Errorf("image too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	thumbnail := resize.Thumbnail(400, 400, img, resize.Lanczos3)
+
+	sum := sha1.Sum([]byte(key))
+	thumbnailName := fmt.Sprintf("link_%x.jpg", sum)
+	thumbnailPath := filepath.Join(s.thumbnailDir, thumbnailName)
+
+	thumbnailFile, err := os.Create(thumbnailPath)
+	if err != nil {
+		return "", err
+	}
+	defer thumbnailFile.Close()
+
+	if err := jpeg.Encode(thumbnailFile, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("/uploads/thumbnails/%s", thumbnailName), nil
+}
+
 func (s *FileUploadService) processVideo(filePath string, result *UploadResult) error {
 	// For video processing, you would typically use ffmpeg
 	// This is a simplified version - in production, use ffmpeg-go or similar
@@ -240,6 +295,30 @@ func (s *FileUploadService) DeleteFile(fileName string) error {
 	return nil
 }
 
+// DeleteByURL removes a previously uploaded file given its public URL.
+// URLs that do not point into the uploads directory are ignored.
+func (s *FileUploadService) DeleteByURL(fileURL string) error {
+	if !strings.HasPrefix(fileURL, "/uploads/") {
+		return nil
+	}
+	return s.DeleteFile(filepath.Base(fileURL))
+}
+
+// LocalPath resolves the public URL of an uploaded file to its path on
+// disk, reporting false when the URL is external or the file is gone.
+func (s *FileUploadService) LocalPath(fileURL string) (string, bool) {
+	if !strings.HasPrefix(fileURL, "/uploads/") {
+		return "", false
+	}
+
+	filePath := filepath.Join(s.uploadDir, filepath.Base(fileURL))
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	return filePath, true
+}
+
 // Utility functions for file validation
 func (s *FileUploadService) IsValidImageType(ext string) bool {
 	for _, allowedExt := range s.allowedTypes["image"] {
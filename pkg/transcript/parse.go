@@ -0,0 +1,293 @@
+package transcript
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateOrder is the order of day, month and year in a transcript's dates,
+// which depends on the exporting phone's locale.
+type DateOrder string
+
+const (
+	DayMonthYear DateOrder = "dmy"
+	MonthDayYear DateOrder = "mdy"
+	YearMonthDay DateOrder = "ymd"
+)
+
+const maxLineLength = 1 << 20
+
+// sameTimeStep spaces out messages whose headers show the same time, which
+// is common as most exports leave out seconds. They keep the order they
+// were written in when sorted by time. A millisecond is the finest step
+// that survives being stored.
+const sameTimeStep = time.Millisecond
+
+var (
+	ErrNoMessages       = errors.New("no messages found in transcript")
+	ErrTooManyMessages  = errors.New("transcript has too many messages")
+	ErrInvalidDateOrder = errors.New("invalid date order")
+)
+
+// iOS exports wrap the timestamp in brackets; Android separates it from the
+// message with a dash. Dates use "/", "." or "-" and may have a two-digit
+// year; times may have seconds and a 12-hour suffix such as "PM" or "p. m.".
+var (
+	bracketHeader = regexp.MustCompile(`^\[(\d{1,4})[./-](\d{1,2})[./-](\d{1,4}),? (\d{1,2})[:.](\d{2})(?:[:.](\d{2}))? *([AaPp]\.? ?[Mm]\.?)?\] (.*)$`)
+	dashHeader    = regexp.MustCompile(`^(\d{1,4})[./-](\d{1,2})[./-](\d{1,4}),? (\d{1,2})[:.](\d{2})(?:[:.](\d{2}))? *([AaPp]\.? ?[Mm]\.?)? [-–] (.*)$`)
+
+	attachedPattern     = regexp.MustCompile(`<attached: ([^>]+)>`)
+	fileAttachedPattern = regexp.MustCompile(`^(.+?) \(file attached\)$`)
+)
+
+// Message is one parsed message. Text has the markers below taken out and
+// reported in their own fields.
+type Message struct {
+	Time         time.Time // Header time; see sameTimeStep
+	Sender       string    // Empty for system notices
+	Text         string
+	Attachment   string // File name of bundled media
+	MediaOmitted bool   // Media was left out of the export
+	Edited       bool
+	Forwarded    bool
+	Deleted      bool
+}
+
+type Options struct {
+	DateOrder   DateOrder      // Detected from the dates when empty
+	Location    *time.Location // Time zone of the exporting phone; UTC when nil
+	MaxMessages int            // No limit when zero
+}
+
+type Transcript struct {
+	Messages  []Message
+	DateOrder DateOrder // The order used, whether given or detected
+}
+
+// rawMessage holds a header's fields until the date order is known.
+type rawMessage struct {
+	date     [3]string
+	clock    [3]string
+	meridiem string
+	body     []string
+}
+
+// Parse reads a WhatsApp transcript. Lines that do not start with a
+// timestamp continue the message before them; anything before the first
+// timestamp is ignored.
+func Parse(r io.Reader, opts Options) (*Transcript, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
+
+	var raws []*rawMessage
+	for scanner.Scan() {
+		line := cleanLine(scanner.Text())
+
+		match := bracketHeader.FindStringSubmatch(line)
+		if match == nil {
+			match = dashHeader.FindStringSubmatch(line)
+		}
+
+		if match == nil {
+			if len(raws) > 0 {
+				last := raws[len(raws)-1]
+				last.body = append(last.body, line)
+			}
+			continue
+		}
+
+		if opts.MaxMessages > 0 && len(raws) >= opts.MaxMessages {
+			return nil, ErrTooManyMessages
+		}
+
+		raws = append(raws, &rawMessage{
+			date:     [3]string{match[1], match[2], match[3]},
+			clock:    [3]string{match[4], match[5], match[6]},
+			meridiem: match[7],
+			body:     []string{match[8]},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(raws) == 0 {
+		return nil, ErrNoMessages
+	}
+
+	order := opts.DateOrder
+	if order == "" {
+		order = detectDateOrder(raws)
+	}
+
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	transcript := &Transcript{
+		Messages:  make([]Message, 0, len(raws)),
+		DateOrder: order,
+	}
+	var previous time.Time
+	sameTime := 0
+	for i, raw := range raws {
+		timestamp, err := raw.time(order, location)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+
+		if i > 0 && timestamp.Equal(previous) {
+			sameTime++
+		} else {
+			previous, sameTime = timestamp, 0
+		}
+
+		msg := parseBody(timestamp.Add(time.Duration(sameTime)*sameTimeStep), raw.body)
+		transcript.Messages = append(transcript.Messages, msg)
+	}
+
+	return transcript, nil
+}
+
+// cleanLine drops the direction marks iOS scatters through exports and
+// turns the no-break spaces some locales put before "PM" into plain ones.
+func cleanLine(line string) string {
+	line = strings.TrimPrefix(line, "\uFEFF")
+	line = strings.ReplaceAll(line, "\u200E", "")
+	line = strings.ReplaceAll(line, "\u200F", "")
+	line = strings.ReplaceAll(line, "\u202F", " ")
+	line = strings.ReplaceAll(line, "\u00A0", " ")
+	return strings.TrimRight(line, "\r")
+}
+
+// detectDateOrder picks the order the dates allow. A four-digit first field
+// means year first, a first field over 12 rules out month first and a
+// second field over 12 rules out day first. When every date fits both, day
+// first is assumed as most locales use it.
+func detectDateOrder(raws []*rawMessage) DateOrder {
+	dayFirst, monthFirst := true, true
+	for _, raw := range raws {
+		if len(raw.date[0]) == 4 {
+			return YearMonthDay
+		}
+		first, _ := strconv.Atoi(raw.date[0])
+		second, _ := strconv.Atoi(raw.date[1])
+		if first > 12 {
+			monthFirst = false
+		}
+		if second > 12 {
+			dayFirst = false
+		}
+	}
+
+	if monthFirst && !dayFirst {
+		return MonthDayYear
+	}
+	return DayMonthYear
+}
+
+func (raw *rawMessage) time(order DateOrder, location *time.Location) (time.Time, error) {
+	var day, month, year string
+	switch order {
+	case DayMonthYear:
+		day, month, year = raw.date[0], raw.date[1], raw.date[2]
+	case MonthDayYear:
+		month, day, year = raw.date[0], raw.date[1], raw.date[2]
+	case YearMonthDay:
+		year, month, day = raw.date[0], raw.date[1], raw.date[2]
+	default:
+		return time.Time{}, ErrInvalidDateOrder
+	}
+
+	d, _ := strconv.Atoi(day)
+	mo, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+	if len(year) <= 2 {
+		y += 2000
+	}
+
+	h, _ := strconv.Atoi(raw.clock[0])
+	mi, _ := strconv.Atoi(raw.clock[1])
+	s, _ := strconv.Atoi(raw.clock[2])
+
+	if raw.meridiem != "" {
+		if h < 1 || h > 12 {
+			return time.Time{}, fmt.Errorf("invalid 12-hour time %s:%s", raw.clock[0], raw.clock[1])
+		}
+		pm := strings.ContainsAny(raw.meridiem[:1], "Pp")
+		switch {
+		case pm && h != 12:
+			h += 12
+		case !pm && h == 12:
+			h = 0
+		}
+	}
+
+	if mo < 1 || mo > 12 || d < 1 || d > 31 || h > 23 || mi > 59 || s > 59 {
+		return time.Time{}, fmt.Errorf("invalid date or time %s", strings.Join(raw.date[:], "/"))
+	}
+
+	t := time.Date(y, time.Month(mo), d, h, mi, s, 0, location)
+	if t.Day() != d {
+		return time.Time{}, fmt.Errorf("invalid date %s", strings.Join(raw.date[:], "/"))
+	}
+	return t, nil
+}
+
+// parseBody splits the sender from the text and lifts out the markers.
+func parseBody(timestamp time.Time, body []string) Message {
+	msg := Message{Time: timestamp}
+
+	first := body[0]
+	if sender, text, ok := strings.Cut(first, ": "); ok {
+		msg.Sender = strings.TrimSpace(sender)
+		first = text
+	} else if strings.HasSuffix(first, ":") && len(body) > 1 {
+		// Sender followed by a message that starts on the next line
+		msg.Sender = strings.TrimSpace(strings.TrimSuffix(first, ":"))
+		first = ""
+	}
+
+	if match := fileAttachedPattern.FindStringSubmatch(first); match != nil {
+		msg.Attachment = match[1]
+		first = ""
+	}
+
+	lines := append([]string{first}, body[1:]...)
+	text := strings.TrimSpace(strings.Join(lines, "\n"))
+
+	if match := attachedPattern.FindStringSubmatch(text); match != nil {
+		msg.Attachment = match[1]
+		text = strings.TrimSpace(strings.Replace(text, match[0], "", 1))
+	}
+
+	if strings.HasPrefix(text, ForwardedMarker) {
+		msg.Forwarded = true
+		text = strings.TrimSpace(strings.TrimPrefix(text, ForwardedMarker))
+	}
+
+	if strings.HasPrefix(text, MediaOmitted) {
+		msg.MediaOmitted = true
+		text = strings.TrimSpace(strings.TrimPrefix(text, MediaOmitted))
+	}
+
+	if strings.HasSuffix(text, EditedMarker) {
+		msg.Edited = true
+		text = strings.TrimSpace(strings.TrimSuffix(text, EditedMarker))
+	}
+
+	if msg.Sender != "" && (text == DeletedMessage || text == YouDeletedMessage) {
+		msg.Deleted = true
+		text = ""
+	}
+
+	msg.Text = text
+	return msg
+}
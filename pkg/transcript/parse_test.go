@@ -0,0 +1,284 @@
+package transcript
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const androidExport = `12/31/23, 9:41 PM - Messages and calls are end-to-end encrypted. No one outside of this chat can read them.
+12/31/23, 9:41 PM - Alice: Happy new year!
+12/31/23, 9:41 PM - Bob: <Media omitted>
+12/31/23, 9:42 PM - Alice: IMG-20231231-WA0001.jpg (file attached)
+look at this
+12/31/23, 11:59 PM - Bob: This message was deleted
+1/1/24, 12:00 AM - Alice: First line
+second line <This message was edited>
+1/1/24, 12:05 AM - Bob added Carol
+`
+
+const iosExport = "\uFEFF[31/12/2023, 21:41:05] Alice: Happy new year!\r\n" +
+	"[31/12/2023, 21:41:05] Bob: \u200E<attached: 00000002-PHOTO-2023-12-31-21-41-05.jpg>\r\n" +
+	"[31/12/2023, 21:41:30] Alice: \u200E<Forwarded> Check this out\r\n" +
+	"[31/12/2023, 21:42:00] Bob:\r\n" +
+	"Starts on the next line\r\n" +
+	"[31/12/2023, 21:43:00] Alice: \u200EYou deleted this message\r\n" +
+	"[1/1/2024, 9:15:00 AM] Bob: Morning\r\n"
+
+func TestParseAndroid(t *testing.T) {
+	parsed, err := Parse(strings.NewReader(androidExport), Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if parsed.DateOrder != MonthDayYear {
+		t.Errorf("DateOrder = %q, want %q", parsed.DateOrder, MonthDayYear)
+	}
+
+	minute := time.Date(2023, 12, 31, 21, 41, 0, 0, time.UTC)
+	want := []Message{
+		{Time: minute, Text: "Messages and calls are end-to-end encrypted. No one outside of this chat can read them."},
+		{Time: minute.Add(sameTimeStep), Sender: "Alice", Text: "Happy new year!"},
+		{Time: minute.Add(2 * sameTimeStep), Sender: "Bob", MediaOmitted: true},
+		{Time: minute.Add(time.Minute), Sender: "Alice", Text: "look at this", Attachment: "IMG-20231231-WA0001.jpg"},
+		{Time: time.Date(2023, 12, 31, 23, 59, 0, 0, time.UTC), Sender: "Bob", Deleted: true},
+		{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Sender: "Alice", Text: "First line\nsecond line", Edited: true},
+		{Time: time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC), Text: "Bob added Carol"},
+	}
+	checkMessages(t, parsed.Messages, want)
+}
+
+func TestParseIOS(t *testing.T) {
+	parsed, err := Parse(strings.NewReader(iosExport), Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if parsed.DateOrder != DayMonthYear {
+		t.Errorf("DateOrder = %q, want %q", parsed.DateOrder, DayMonthYear)
+	}
+
+	second := time.Date(2023, 12, 31, 21, 41, 5, 0, time.UTC)
+	want := []Message{
+		{Time: second, Sender: "Alice", Text: "Happy new year!"},
+		{Time: second.Add(sameTimeStep), Sender: "Bob", Attachment: "00000002-PHOTO-2023-12-31-21-41-05.jpg"},
+		{Time: time.Date(2023, 12, 31, 21, 41, 30, 0, time.UTC), Sender: "Alice", Text: "Check this out", Forwarded: true},
+		{Time: time.Date(2023, 12, 31, 21, 42, 0, 0, time.UTC), Sender: "Bob", Text: "Starts on the next line"},
+		{Time: time.Date(2023, 12, 31, 21, 43, 0, 0, time.UTC), Sender: "Alice", Deleted: true},
+		{Time: time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC), Sender: "Bob", Text: "Morning"},
+	}
+	checkMessages(t, parsed.Messages, want)
+}
+
+func TestParseDateOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		order DateOrder // Given to Parse
+		want  DateOrder
+		first time.Time
+	}{
+		{
+			name:  "ambiguous defaults to day first",
+			input: "01/02/2023, 10:00 - Alice: hi\n03/04/2023, 10:00 - Bob: hi\n",
+			want:  DayMonthYear,
+			first: time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "ambiguous with explicit order",
+			input: "01/02/2023, 10:00 - Alice: hi\n03/04/2023, 10:00 - Bob: hi\n",
+			order: MonthDayYear,
+			want:  MonthDayYear,
+			first: time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "later date settles month first",
+			input: "01/02/2023, 10:00 - Alice: hi\n01/25/2023, 10:00 - Bob: hi\n",
+			want:  MonthDayYear,
+			first: time.Date(2023, 1, 2, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "later date settles day first",
+			input: "01/02/2023, 10:00 - Alice: hi\n25/01/2023, 10:00 - Bob: hi\n",
+			want:  DayMonthYear,
+			first: time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "four-digit year first",
+			input: "[2023-02-01, 10:00:00] Alice: hi\n",
+			want:  YearMonthDay,
+			first: time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "dotted date with two-digit year",
+			input: "01.02.23, 10:00 - Alice: hi\n",
+			want:  DayMonthYear,
+			first: time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "twelve AM is midnight",
+			input: "[1/2/23, 12:30:00 AM] Alice: hi\n",
+			want:  DayMonthYear,
+			first: time.Date(2023, 2, 1, 0, 30, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := Parse(strings.NewReader(tt.input), Options{DateOrder: tt.order})
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if parsed.DateOrder != tt.want {
+				t.Errorf("DateOrder = %q, want %q", parsed.DateOrder, tt.want)
+			}
+			if got := parsed.Messages[0].Time; !got.Equal(tt.first) {
+				t.Errorf("first message at %v, want %v", got, tt.first)
+			}
+		})
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	parsed, err := Parse(strings.NewReader("[01/02/2023, 10:00:00] Alice: hi\n"), Options{Location: location})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := time.Date(2023, 2, 1, 8, 0, 0, 0, time.UTC)
+	if got := parsed.Messages[0].Time; !got.Equal(want) {
+		t.Errorf("Time = %v, want %v", got, want)
+	}
+}
+
+func TestParseSameTimeKeepsOrder(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("01/02/2023, 10:00 - Alice: one\n")
+	b.WriteString("01/02/2023, 10:00 - Bob: two\n")
+	b.WriteString("01/02/2023, 10:00 - Alice: three\n")
+	b.WriteString("01/02/2023, 10:01 - Bob: four\n")
+	b.WriteString("01/02/2023, 10:01 - Alice: five\n")
+
+	parsed, err := Parse(strings.NewReader(b.String()), Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	for i := 1; i < len(parsed.Messages); i++ {
+		if !parsed.Messages[i].Time.After(parsed.Messages[i-1].Time) {
+			t.Errorf("message %d at %v is not after message %d at %v", i+1, parsed.Messages[i].Time, i, parsed.Messages[i-1].Time)
+		}
+	}
+
+	// The offset starts over with each new header time
+	want := time.Date(2023, 2, 1, 10, 1, 0, 0, time.UTC)
+	if got := parsed.Messages[3].Time; !got.Equal(want) {
+		t.Errorf("fourth message at %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		want  error
+	}{
+		{
+			name:  "no headers",
+			input: "just some text\nand more\n",
+			want:  ErrNoMessages,
+		},
+		{
+			name:  "too many messages",
+			input: "01/02/2023, 10:00 - Alice: one\n01/02/2023, 10:00 - Bob: two\n",
+			opts:  Options{MaxMessages: 1},
+			want:  ErrTooManyMessages,
+		},
+		{
+			name:  "unknown date order",
+			input: "01/02/2023, 10:00 - Alice: one\n",
+			opts:  Options{DateOrder: "ydm"},
+			want:  ErrInvalidDateOrder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	invalid := []string{
+		"31/02/2023, 10:00 - Alice: no such day\n",
+		"[01/02/2023, 13:00:00 PM] Alice: bad hour\n",
+		"01/13/2023, 10:00 - Alice: hi\n13/01/2023, 10:00 - Bob: hi\n",
+	}
+	for _, input := range invalid {
+		if _, err := Parse(strings.NewReader(input), Options{}); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestParseIgnoresPreamble(t *testing.T) {
+	parsed, err := Parse(strings.NewReader("not a message\n01/02/2023, 10:00 - Alice: hi\n"), Options{})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	checkMessages(t, parsed.Messages, []Message{
+		{Time: time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC), Sender: "Alice", Text: "hi"},
+	})
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	lines := []Line{
+		{Time: time.Date(2023, 2, 1, 10, 0, 5, 0, time.UTC), Sender: "Alice", Text: "hi\r\nthere"},
+		{Time: time.Date(2023, 2, 1, 10, 1, 0, 0, time.UTC), Text: "Alice added Bob"},
+		{Time: time.Date(2023, 2, 1, 10, 2, 0, 0, time.UTC), Sender: "Bob", Text: Attached("00000001-photo.jpg")},
+	}
+
+	var b strings.Builder
+	w := NewWriter(&b)
+	for _, line := range lines {
+		if err := w.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	parsed, err := Parse(strings.NewReader(b.String()), Options{DateOrder: DayMonthYear})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	checkMessages(t, parsed.Messages, []Message{
+		{Time: lines[0].Time, Sender: "Alice", Text: "hi\nthere"},
+		{Time: lines[1].Time, Text: "Alice added Bob"},
+		{Time: lines[2].Time, Sender: "Bob", Attachment: "00000001-photo.jpg"},
+	})
+}
+
+func checkMessages(t *testing.T, got, want []Message) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) {
+			t.Errorf("message %d: Time = %v, want %v", i+1, got[i].Time, want[i].Time)
+		}
+		g, w := got[i], want[i]
+		g.Time, w.Time = time.Time{}, time.Time{}
+		if g != w {
+			t.Errorf("message %d = %+v, want %+v", i+1, g, w)
+		}
+	}
+}
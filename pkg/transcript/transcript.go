@@ -0,0 +1,69 @@
+// Package transcript reads and writes chat transcripts in the plain-text
+// layout used by WhatsApp's "Export chat": one "[date, time] Name: text"
+// line per message, with the rest of a multi-line message on the lines
+// after it.
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	DateLayout = "02/01/2006"
+	TimeLayout = "15:04:05"
+)
+
+// Markers WhatsApp puts in place of, or after, message text.
+const (
+	MediaOmitted      = "<Media omitted>"
+	EditedMarker      = "<This message was edited>"
+	ForwardedMarker   = "<Forwarded>"
+	DeletedMessage    = "This message was deleted"
+	YouDeletedMessage = "You deleted this message"
+)
+
+// Attached is the marker for a file bundled next to the transcript.
+func Attached(fileName string) string {
+	return fmt.Sprintf("<attached: %s>", fileName)
+}
+
+// Line is one message. Sender is empty for system notices, which are
+// written without a name.
+type Line struct {
+	Time   time.Time
+	Sender string
+	Text   string
+}
+
+type Writer struct {
+	w *bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Write adds a line in the time zone of line.Time. Line breaks in the text
+// are normalised to "\n".
+func (tw *Writer) Write(line Line) error {
+	text := strings.ReplaceAll(line.Text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	fmt.Fprintf(tw.w, "[%s, %s] ", line.Time.Format(DateLayout), line.Time.Format(TimeLayout))
+	if line.Sender != "" {
+		tw.w.WriteString(line.Sender)
+		tw.w.WriteString(": ")
+	}
+	tw.w.WriteString(text)
+	_, err := tw.w.WriteString("\n")
+	return err
+}
+
+// Flush writes any buffered lines to the underlying writer.
+func (tw *Writer) Flush() error {
+	return tw.w.Flush()
+}
@@ -0,0 +1,291 @@
+// Package vcard reads and writes the subset of vCard 3.0 and 4.0 (RFC 2426,
+// RFC 6350) needed for contact cards: names, organisation, phones and emails.
+package vcard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	Version3 = "3.0"
+	Version4 = "4.0"
+)
+
+type Card struct {
+	FormattedName string
+	FamilyName    string
+	GivenName     string
+	Organization  string
+	Phones        []Field
+	Emails        []Field
+}
+
+// Field is a typed value such as a phone number or e-mail address.
+type Field struct {
+	Value string
+	Type  string // e.g. "cell", "work", "home"; empty when not given
+}
+
+var ErrNoCards = errors.New("no vCards found")
+
+// maxTypeLength bounds a field type; real ones are short words.
+const maxTypeLength = 32
+
+// IsValidType reports whether t can be written as a TYPE parameter: a
+// token of ASCII letters, digits and hyphens.
+func IsValidType(t string) bool {
+	if t == "" || len(t) > maxTypeLength {
+		return false
+	}
+	for _, r := range t {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads every vCard in r. Versions 2.1, 3.0 and 4.0 are accepted;
+// properties other than the ones in Card are ignored.
+func Parse(r io.Reader) ([]Card, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []Card
+	var current *Card
+	for _, line := range lines {
+		name, params, value, ok := splitLine(line)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "BEGIN":
+			if strings.EqualFold(value, "VCARD") {
+				current = &Card{}
+			}
+			continue
+		case "END":
+			if strings.EqualFold(value, "VCARD") && current != nil {
+				cards = append(cards, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch name {
+		case "FN":
+			current.FormattedName = unescape(value)
+		case "N":
+			parts := splitStructured(value)
+			if len(parts) > 0 {
+				current.FamilyName = parts[0]
+			}
+			if len(parts) > 1 {
+				current.GivenName = parts[1]
+			}
+		case "ORG":
+			if parts := splitStructured(value); len(parts) > 0 {
+				current.Organization = parts[0]
+			}
+		case "TEL":
+			// vCard 4.0 may carry the number as a tel: URI
+			number := strings.TrimPrefix(unescape(value), "tel:")
+			if number != "" {
+				current.Phones = append(current.Phones, Field{Value: number, Type: typeParam(params)})
+			}
+		case "EMAIL":
+			if email := unescape(value); email != "" {
+				current.Emails = append(current.Emails, Field{Value: email, Type: typeParam(params)})
+			}
+		}
+	}
+
+	if len(cards) == 0 {
+		return nil, ErrNoCards
+	}
+
+	return cards, nil
+}
+
+// Encode writes the cards in the given version (Version3 or Version4).
+func Encode(w io.Writer, cards []Card, version string) error {
+	if version != Version3 && version != Version4 {
+		return fmt.Errorf("unsupported vCard version %q", version)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, card := range cards {
+		writeLine(bw, "BEGIN:VCARD")
+		writeLine(bw, "VERSION:"+version)
+		writeLine(bw, "FN:"+escape(card.displayName()))
+		writeLine(bw, "N:"+escape(card.FamilyName)+";"+escape(card.GivenName)+";;;")
+		if card.Organization != "" {
+			writeLine(bw, "ORG:"+escape(card.Organization))
+		}
+
+		for _, phone := range card.Phones {
+			if version == Version4 {
+				writeLine(bw, "TEL"+typeSuffix(phone.Type)+";VALUE=uri:tel:"+uriValue(phone.Value))
+			} else {
+				writeLine(bw, "TEL"+typeSuffix(phone.Type)+":"+escape(phone.Value))
+			}
+		}
+		for _, email := range card.Emails {
+			writeLine(bw, "EMAIL"+typeSuffix(email.Type)+":"+escape(email.Value))
+		}
+
+		writeLine(bw, "END:VCARD")
+	}
+
+	return bw.Flush()
+}
+
+func (c Card) displayName() string {
+	if c.FormattedName != "" {
+		return c.FormattedName
+	}
+	return strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+}
+
+// unfold joins continuation lines (those starting with a space or tab).
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// splitLine breaks "group.NAME;PARAM=x:value" into its parts. The property
+// name is upper-cased and any group prefix dropped.
+func splitLine(line string) (string, []string, string, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", nil, "", false
+	}
+
+	head := strings.Split(line[:colon], ";")
+	name := strings.ToUpper(head[0])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+
+	return name, head[1:], line[colon+1:], true
+}
+
+// typeParam reads TYPE=cell, TYPE="work,voice" and vCard 2.1 bare types.
+// Types that are not tokens are skipped.
+func typeParam(params []string) string {
+	for _, param := range params {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			value = key
+		} else if !strings.EqualFold(key, "TYPE") {
+			continue
+		}
+
+		for _, t := range strings.Split(strings.Trim(value, `"`), ",") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if IsValidType(t) && t != "pref" && t != "voice" && t != "internet" {
+				return t
+			}
+		}
+	}
+	return ""
+}
+
+// typeSuffix leaves out types that are not tokens, since anything else
+// could end the parameter and start another property.
+func typeSuffix(fieldType string) string {
+	if !IsValidType(fieldType) {
+		return ""
+	}
+	return ";TYPE=" + strings.ToLower(fieldType)
+}
+
+func splitStructured(value string) []string {
+	var parts []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			current.WriteString(unescaper.Replace(`\` + string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			parts = append(parts, strings.TrimSpace(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, strings.TrimSpace(current.String()))
+}
+
+var unescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\:`, ":", `\\`, `\`)
+
+func unescape(value string) string {
+	return strings.TrimSpace(unescaper.Replace(value))
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\r", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// uriValue drops control characters from a URI value, which is written
+// without escaping, so a line break cannot start a new property.
+func uriValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// writeLine folds lines longer than 75 octets as the RFCs require, taking
+// care not to split multi-byte characters.
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // Continuation lines start with a space
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,186 @@
+package vcard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := "BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"FN:Jane Doe\r\n" +
+		"N:Doe;Jane;;;\r\n" +
+		"ORG:Acme\\, Inc.;Sales\r\n" +
+		"item1.TEL;TYPE=\"cell,voice\":+1 555 0100\r\n" +
+		"TEL;TYPE=work,pref:+1 555\r\n" +
+		" 0101\r\n" +
+		"EMAIL;TYPE=INTERNET;TYPE=home:jane@example.com\r\n" +
+		"NOTE:ignored\r\n" +
+		"END:VCARD\r\n" +
+		"BEGIN:VCARD\r\n" +
+		"VERSION:4.0\r\n" +
+		"FN:John\r\n" +
+		"TEL;VALUE=uri;TYPE=\"my phone\":tel:+15550102\r\n" +
+		"END:VCARD\r\n"
+
+	cards, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	want := []Card{
+		{
+			FormattedName: "Jane Doe",
+			FamilyName:    "Doe",
+			GivenName:     "Jane",
+			Organization:  "Acme, Inc.",
+			Phones:        []Field{{Value: "+1 555 0100", Type: "cell"}, {Value: "+1 5550101", Type: "work"}},
+			Emails:        []Field{{Value: "jane@example.com", Type: "home"}},
+		},
+		{
+			// A type that is not a token is left out
+			FormattedName: "John",
+			Phones:        []Field{{Value: "+15550102"}},
+		},
+	}
+	if !reflect.DeepEqual(cards, want) {
+		t.Errorf("Parse =\n%+v\nwant\n%+v", cards, want)
+	}
+}
+
+func TestParseNoCards(t *testing.T) {
+	if _, err := Parse(strings.NewReader("FN:Nobody\r\n")); err != ErrNoCards {
+		t.Errorf("err = %v, want %v", err, ErrNoCards)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		card      Card
+		want      Card    // Zero when the card comes back unchanged
+		uriPhones []Field // Phones from the 4.0 URI form, when they differ
+	}{
+		{
+			name: "plain",
+			card: Card{
+				FormattedName: "Jane Doe",
+				FamilyName:    "Doe",
+				GivenName:     "Jane",
+				Organization:  "Acme",
+				Phones:        []Field{{Value: "+15550100", Type: "cell"}},
+				Emails:        []Field{{Value: "jane@example.com", Type: "work"}},
+			},
+		},
+		{
+			name: "special characters",
+			card: Card{
+				FormattedName: `Doe, Jane; "JD" \ Esq.`,
+				FamilyName:    "O;Brien",
+				GivenName:     "Jane,Ann",
+				Organization:  "Line one\nLine two",
+				Phones:        []Field{{Value: "+15550100"}},
+			},
+		},
+		{
+			name: "long name is folded",
+			card: Card{
+				FormattedName: strings.Repeat("Ünïcödé ", 20),
+				Emails:        []Field{{Value: "a@example.com"}},
+			},
+			want: Card{
+				FormattedName: strings.TrimSpace(strings.Repeat("Ünïcödé ", 20)),
+				Emails:        []Field{{Value: "a@example.com"}},
+			},
+		},
+		{
+			name: "line breaks cannot add properties",
+			card: Card{
+				FormattedName: "Eve\r\nEMAIL:evil@example.com",
+				Phones:        []Field{{Value: "+15550100\r\nEMAIL:evil@example.com", Type: "cell\r\nEMAIL:evil@example.com"}},
+			},
+			want: Card{
+				FormattedName: "Eve\nEMAIL:evil@example.com",
+				Phones:        []Field{{Value: "+15550100\nEMAIL:evil@example.com"}},
+			},
+			// The URI form has no escapes, so line breaks are removed
+			uriPhones: []Field{{Value: "+15550100EMAIL:evil@example.com"}},
+		},
+		{
+			name: "type that is not a token is dropped",
+			card: Card{
+				FormattedName: "Jane",
+				Emails:        []Field{{Value: "jane@example.com", Type: "home:x"}},
+			},
+			want: Card{
+				FormattedName: "Jane",
+				Emails:        []Field{{Value: "jane@example.com"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, version := range []string{Version3, Version4} {
+			t.Run(tt.name+" "+version, func(t *testing.T) {
+				var b strings.Builder
+				if err := Encode(&b, []Card{tt.card}, version); err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+					if len(line) > 75 || strings.ContainsAny(line, "\r\n") {
+						t.Errorf("bad line %q", line)
+					}
+				}
+
+				cards, err := Parse(strings.NewReader(b.String()))
+				if err != nil {
+					t.Fatalf("Parse: %v\n%s", err, b.String())
+				}
+
+				want := tt.want
+				if reflect.DeepEqual(want, Card{}) {
+					want = tt.card
+				}
+				if version == Version4 && tt.uriPhones != nil {
+					want.Phones = tt.uriPhones
+				}
+
+				if len(cards) != 1 || !reflect.DeepEqual(cards[0], want) {
+					t.Errorf("round trip =\n%+v\nwant\n%+v\nencoded:\n%s", cards, want, b.String())
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeUnsupportedVersion(t *testing.T) {
+	if err := Encode(&strings.Builder{}, []Card{{FormattedName: "Jane"}}, "2.1"); err == nil {
+		t.Error("Encode succeeded, want an error")
+	}
+}
+
+func TestIsValidType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"cell", true},
+		{"WORK", true},
+		{"x-custom-1", true},
+		{"", false},
+		{"my phone", false},
+		{"cell;pref", false},
+		{"cell:x", false},
+		{"cell\r\nFN:x", false},
+		{"zuhause", true},
+		{"über", false},
+		{strings.Repeat("a", 33), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidType(tt.value); got != tt.want {
+			t.Errorf("IsValidType(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
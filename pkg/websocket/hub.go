@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"bro-chat/internal/domain/entities"
@@ -19,12 +21,45 @@ var Upgrader = websocket.Upgrader{
 }
 
 type Hub struct {
+	// mu guards the client maps, which the Run loop, client goroutines and
+	// usecases sending events all touch. A client's Send channel is only
+	// closed under the write lock, so sending under the read lock is safe.
+	mu          sync.RWMutex
 	Clients     map[*Client]bool
-	UserClients map[primitive.ObjectID]*Client
+	UserClients map[primitive.ObjectID]map[*Client]bool // A user can be connected from several devices
 	ChatClients map[primitive.ObjectID]map[*Client]bool // Chat-specific client mapping
 	Broadcast   chan []byte
 	Register    chan *Client
 	Unregister  chan *Client
+
+	liveLocationHandler LiveLocationHandler
+	draftHandler        DraftHandler
+	receiptHandler      ReceiptHandler
+	syncHandler         SyncHandler
+}
+
+// LiveLocationHandler checks and stores a position update streamed by a
+// client. The hub only fans out updates the handler accepts.
+type LiveLocationHandler interface {
+	UpdateLiveLocation(userID primitive.ObjectID, update *LocationUpdatePayload) error
+}
+
+// DraftHandler stores a draft sent over the socket. The hub then syncs it
+// to the user's other connections.
+type DraftHandler interface {
+	SyncDraft(userID primitive.ObjectID, update *DraftPayload) error
+}
+
+// ReceiptHandler records that a client received messages.
+type ReceiptHandler interface {
+	MarkDelivered(userID primitive.ObjectID, messageIDs []primitive.ObjectID) error
+}
+
+// SyncHandler streams what a user missed while offline. send queues one
+// event on the connection that asked and reports false once it cannot take
+// more.
+type SyncHandler interface {
+	SyncMissed(userID primitive.ObjectID, req *SyncRequestPayload, send func(WSMessage) bool) (*SyncCompletePayload, error)
 }
 
 type Client struct {
@@ -34,6 +69,8 @@ type Client struct {
 	UserID   primitive.ObjectID
 	Username string
 	IsOnline bool
+
+	syncing atomic.Bool // A sync is replaying missed events
 }
 
 type WSMessage struct {
@@ -50,6 +87,29 @@ const (
 	WSMessageReaction WSMessageType = "message_reaction"
 	WSMessageDeleted  WSMessageType = "message_deleted"
 	WSMessageEdited   WSMessageType = "message_edited"
+	WSMessageUpdated  WSMessageType = "message_updated"
+	WSMessageExpired  WSMessageType = "message_expired"
+	WSMessagePinned   WSMessageType = "message_pinned"
+	WSMessageUnpinned WSMessageType = "message_unpinned"
+	WSThreadReply     WSMessageType = "thread_reply"
+	WSMention         WSMessageType = "mention"
+	WSPollUpdated     WSMessageType = "poll_updated"
+
+	// Sent by clients to acknowledge delivery
+	WSMessageDelivered WSMessageType = "message_delivered"
+
+	// Offline sync: clients send "sync" after connecting and get the missed
+	// events followed by "sync_complete"
+	WSSync         WSMessageType = "sync"
+	WSSyncComplete WSMessageType = "sync_complete"
+
+	// Live location events
+	WSLocationUpdate    WSMessageType = "location_update"
+	WSLiveLocationEnded WSMessageType = "live_location_ended"
+
+	// Draft events: clients send draft_update, other devices get draft_updated
+	WSDraftUpdate  WSMessageType = "draft_update"
+	WSDraftUpdated WSMessageType = "draft_updated"
 
 	// Typing events
 	WSTypingStart WSMessageType = "typing_start"
@@ -64,6 +124,7 @@ const (
 	// Chat events
 	WSChatCreated WSMessageType = "chat_created"
 	WSChatUpdated WSMessageType = "chat_updated"
+	WSChatCleared WSMessageType = "chat_cleared" // To the user's own devices only
 
 	// File upload events
 	WSFileUploadProgress WSMessageType = "file_upload_progress"
@@ -92,9 +153,10 @@ const (
 
 // Payload structures
 type NewMessagePayload struct {
-	Message    *entities.Message  `json:"message"`
-	ChatID     primitive.ObjectID `json:"chatId"`
-	SenderName string             `json:"senderName"`
+	Message         *entities.Message  `json:"message"`
+	ChatID          primitive.ObjectID `json:"chatId"`
+	SenderName      string             `json:"senderName"`
+	ClientMessageID string             `json:"clientMessageId,omitempty"` // Echoed from the send request
 }
 
 type MessageStatusPayload struct {
@@ -102,9 +164,14 @@ type MessageStatusPayload struct {
 	ChatID    primitive.ObjectID     `json:"chatId"`
 	Status    entities.MessageStatus `json:"status"`
 	UserID    primitive.ObjectID     `json:"userId"`
+	Aggregate bool                   `json:"aggregate,omitempty"` // Status across all recipients, sent to the sender
 	Timestamp time.Time              `json:"timestamp"`
 }
 
+type DeliveryAckPayload struct {
+	MessageIDs []primitive.ObjectID `json:"messageIds"`
+}
+
 type MessageReactionPayload struct {
 	MessageID primitive.ObjectID    `json:"messageId"`
 	ChatID    primitive.ObjectID    `json:"chatId"`
@@ -115,6 +182,117 @@ type MessageReactionPayload struct {
 	Timestamp time.Time             `json:"timestamp"`
 }
 
+type MessageDeletedPayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	DeletedBy primitive.ObjectID `json:"deletedBy"`
+	Content   string             `json:"content"`
+	DeletedAt time.Time          `json:"deletedAt"`
+}
+
+type MessageEditedPayload struct {
+	MessageID primitive.ObjectID   `json:"messageId"`
+	ChatID    primitive.ObjectID   `json:"chatId"`
+	Content   string               `json:"content"`
+	Mentions  []primitive.ObjectID `json:"mentions,omitempty"`
+	Revision  int                  `json:"revision"`
+	EditedAt  time.Time            `json:"editedAt"`
+}
+
+type MessageUpdatedPayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	Message   *entities.Message  `json:"message"`
+}
+
+type MessageExpiredPayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	ExpiredAt time.Time          `json:"expiredAt"`
+}
+
+type MessagePinnedPayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	PinnedBy  primitive.ObjectID `json:"pinnedBy"`
+	PinnedAt  time.Time          `json:"pinnedAt"`
+	ExpiresAt *time.Time         `json:"expiresAt,omitempty"`
+}
+
+type MessageUnpinnedPayload struct {
+	MessageID  primitive.ObjectID `json:"messageId"`
+	ChatID     primitive.ObjectID `json:"chatId"`
+	UnpinnedBy primitive.ObjectID `json:"unpinnedBy,omitempty"` // Empty when the pin expired
+}
+
+type MentionPayload struct {
+	MessageID  primitive.ObjectID `json:"messageId"`
+	ChatID     primitive.ObjectID `json:"chatId"`
+	SenderID   primitive.ObjectID `json:"senderId"`
+	SenderName string             `json:"senderName"`
+	Content    string             `json:"content"`
+}
+
+type ThreadReplyPayload struct {
+	RootID      primitive.ObjectID `json:"rootId"`
+	ChatID      primitive.ObjectID `json:"chatId"`
+	MessageID   primitive.ObjectID `json:"messageId"`
+	SenderID    primitive.ObjectID `json:"senderId"`
+	UnreadCount int                `json:"unreadCount"`
+}
+
+type LocationUpdatePayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	SenderID  primitive.ObjectID `json:"senderId"`
+	Latitude  float64            `json:"latitude"`
+	Longitude float64            `json:"longitude"`
+	Accuracy  float64            `json:"accuracy,omitempty"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+type LiveLocationEndedPayload struct {
+	MessageID primitive.ObjectID `json:"messageId"`
+	ChatID    primitive.ObjectID `json:"chatId"`
+	EndedAt   time.Time          `json:"endedAt"`
+}
+
+type DraftPayload struct {
+	ChatID    primitive.ObjectID  `json:"chatId"`
+	Content   string              `json:"content"`
+	ReplyToID *primitive.ObjectID `json:"replyToId,omitempty"`
+	Cleared   bool                `json:"cleared,omitempty"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+}
+
+// ChatClearedPayload tells a user's devices to drop the chat's messages up
+// to ClearedAt, apart from the kept ones.
+type ChatClearedPayload struct {
+	ChatID         primitive.ObjectID   `json:"chatId"`
+	ClearedAt      time.Time            `json:"clearedAt"`
+	KeptMessageIDs []primitive.ObjectID `json:"keptMessageIds,omitempty"`
+}
+
+// SyncRequestPayload says where the client's copy ends: a token from an
+// earlier sync_complete, the last message seen in each chat, or both.
+type SyncRequestPayload struct {
+	SyncToken string             `json:"syncToken,omitempty"`
+	Chats     []ChatSyncPosition `json:"chats,omitempty"`
+}
+
+type ChatSyncPosition struct {
+	ChatID        primitive.ObjectID `json:"chatId"`
+	LastMessageID primitive.ObjectID `json:"lastMessageId"`
+}
+
+// SyncCompletePayload ends a sync. When HasMore is set the client sends
+// another sync with the token to fetch the rest.
+type SyncCompletePayload struct {
+	SyncToken string `json:"syncToken"`
+	Events    int    `json:"events"`
+	HasMore   bool   `json:"hasMore"`
+}
+
 type TypingPayload struct {
 	ChatID   primitive.ObjectID `json:"chatId"`
 	UserID   primitive.ObjectID `json:"userId"`
@@ -167,13 +345,13 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 32 << 10 // Room for drafts synced over the socket
 )
 
 func NewHub() *Hub {
 	return &Hub{
 		Clients:     make(map[*Client]bool),
-		UserClients: make(map[primitive.ObjectID]*Client),
+		UserClients: make(map[primitive.ObjectID]map[*Client]bool),
 		ChatClients: make(map[primitive.ObjectID]map[*Client]bool),
 		Broadcast:   make(chan []byte),
 		Register:    make(chan *Client),
@@ -186,52 +364,55 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
+			h.mu.Lock()
 			h.Clients[client] = true
-			h.UserClients[client.UserID] = client
+			firstConnection := len(h.UserClients[client.UserID]) == 0
+			if firstConnection {
+				h.UserClients[client.UserID] = make(map[*Client]bool)
+			}
+			h.UserClients[client.UserID][client] = true
 			client.IsOnline = true
+			total := len(h.Clients)
+			h.mu.Unlock()
 
 			log.Printf("✅ WebSocket: User %s (%s) connected (Total: %d)",
-				client.Username, client.UserID.Hex(), len(h.Clients))
+				client.Username, client.UserID.Hex(), total)
 
-			// Broadcast user online status
-			h.BroadcastUserStatus(client.UserID, client.Username, true)
+			// Broadcast user online status when their first device connects
+			if firstConnection {
+				h.BroadcastUserStatus(client.UserID, client.Username, true)
+			}
 
 		case client := <-h.Unregister:
-			if _, ok := h.Clients[client]; ok {
-				delete(h.Clients, client)
-				delete(h.UserClients, client.UserID)
-
-				// Remove from all chat rooms
-				for chatID, chatClients := range h.ChatClients {
-					if _, exists := chatClients[client]; exists {
-						delete(chatClients, client)
-						if len(chatClients) == 0 {
-							delete(h.ChatClients, chatID)
-						}
-					}
-				}
-
-				close(client.Send)
-				client.IsOnline = false
+			h.mu.Lock()
+			registered := h.removeClient(client)
+			lastConnection := len(h.UserClients[client.UserID]) == 0
+			total := len(h.Clients)
+			h.mu.Unlock()
 
+			if registered {
 				log.Printf("❌ WebSocket: User %s (%s) disconnected (Total: %d)",
-					client.Username, client.UserID.Hex(), len(h.Clients))
+					client.Username, client.UserID.Hex(), total)
 
-				// Broadcast user offline status
-				h.BroadcastUserStatus(client.UserID, client.Username, false)
+				// Broadcast user offline status once their last device is gone
+				if lastConnection {
+					h.BroadcastUserStatus(client.UserID, client.Username, false)
+				}
 			}
 
 		case message := <-h.Broadcast:
+			var slow []*Client
+			h.mu.RLock()
 			log.Printf("📢 WebSocket: Broadcasting message to %d clients", len(h.Clients))
 			for client := range h.Clients {
 				select {
 				case client.Send <- message:
 				default:
-					close(client.Send)
-					delete(h.Clients, client)
-					delete(h.UserClients, client.UserID)
+					slow = append(slow, client)
 				}
 			}
+			h.mu.RUnlock()
+			h.dropClients(slow)
 		}
 	}
 }
@@ -250,6 +431,22 @@ func (c *Client) handleMessage(msg WSMessage) {
 		c.handleLeaveChat(msg.Payload)
 	case WSPing:
 		c.handlePing()
+	case WSLocationUpdate:
+		c.handleLocationUpdate(msg.Payload)
+	case WSDraftUpdate:
+		c.handleDraftUpdate(msg.Payload)
+	case WSMessageDelivered:
+		c.handleDeliveryAck(msg.Payload)
+	case WSSync:
+		// A long sync would hold up reading pongs, so it runs on its own
+		if c.syncing.CompareAndSwap(false, true) {
+			go func() {
+				defer c.syncing.Store(false)
+				c.handleSync(msg.Payload)
+			}()
+		} else {
+			c.sendError("Sync already in progress")
+		}
 	default:
 		log.Printf("❓ WebSocket: Unknown message type: %s", msg.Type)
 	}
@@ -287,10 +484,12 @@ func (c *Client) handleJoinChat(payload interface{}) {
 	}
 
 	// Add client to chat room
+	c.Hub.mu.Lock()
 	if c.Hub.ChatClients[chatData.ChatID] == nil {
 		c.Hub.ChatClients[chatData.ChatID] = make(map[*Client]bool)
 	}
 	c.Hub.ChatClients[chatData.ChatID][c] = true
+	c.Hub.mu.Unlock()
 
 	log.Printf("👥 User %s joined chat %s", c.Username, chatData.ChatID.Hex())
 }
@@ -303,16 +502,152 @@ func (c *Client) handleLeaveChat(payload interface{}) {
 	}
 
 	// Remove client from chat room
+	c.Hub.mu.Lock()
 	if chatClients, exists := c.Hub.ChatClients[chatData.ChatID]; exists {
 		delete(chatClients, c)
 		if len(chatClients) == 0 {
 			delete(c.Hub.ChatClients, chatData.ChatID)
 		}
 	}
+	c.Hub.mu.Unlock()
 
 	log.Printf("👤 User %s left chat %s", c.Username, chatData.ChatID.Hex())
 }
 
+func (c *Client) handleLocationUpdate(payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var update LocationUpdatePayload
+	if err := json.Unmarshal(data, &update); err != nil {
+		return
+	}
+
+	if c.Hub.liveLocationHandler == nil {
+		return
+	}
+
+	// The handler fills in the chat and sender from the stored session
+	if err := c.Hub.liveLocationHandler.UpdateLiveLocation(c.UserID, &update); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+
+	c.Hub.BroadcastToChat(update.ChatID, c.UserID, WSMessage{
+		Type:    string(WSLocationUpdate),
+		Payload: update,
+	})
+}
+
+func (c *Client) handleDraftUpdate(payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var update DraftPayload
+	if err := json.Unmarshal(data, &update); err != nil {
+		return
+	}
+
+	if c.Hub.draftHandler == nil {
+		return
+	}
+
+	// The handler sets Cleared and UpdatedAt from what was stored
+	if err := c.Hub.draftHandler.SyncDraft(c.UserID, &update); err != nil {
+		c.sendError(err.Error())
+		return
+	}
+
+	c.Hub.NotifyDraftUpdated(c.UserID, &update, c)
+}
+
+func (c *Client) handleDeliveryAck(payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var ack DeliveryAckPayload
+	if err := json.Unmarshal(data, &ack); err != nil || len(ack.MessageIDs) == 0 {
+		return
+	}
+
+	if c.Hub.receiptHandler == nil {
+		return
+	}
+
+	if err := c.Hub.receiptHandler.MarkDelivered(c.UserID, ack.MessageIDs); err != nil {
+		c.sendError(err.Error())
+	}
+}
+
+// handleSync replays missed events. The connection can go away while they
+// are being queued, in which case queue fails and the sync stops.
+func (c *Client) handleSync(payload interface{}) {
+	data, _ := json.Marshal(payload)
+	var req SyncRequestPayload
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.sendError("Invalid sync request")
+		return
+	}
+
+	if c.Hub.syncHandler == nil {
+		return
+	}
+
+	complete, err := c.Hub.syncHandler.SyncMissed(c.UserID, &req, c.queue)
+	if err != nil {
+		c.sendError(err.Error())
+		return
+	}
+
+	c.queue(WSMessage{
+		Type:    string(WSSyncComplete),
+		Payload: complete,
+	})
+}
+
+// queue adds a message to the send buffer, waiting while it is more than
+// half full so that broadcasts never find it full and drop the connection.
+func (c *Client) queue(message WSMessage) bool {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return false
+	}
+
+	deadline := time.Now().Add(writeWait)
+	for len(c.Send) >= cap(c.Send)/2 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return c.trySend(data)
+}
+
+// trySend adds data to the send buffer without waiting. It holds the hub's
+// read lock so the channel cannot be closed by an unregister in between,
+// and fails once the client is gone.
+func (c *Client) trySend(data []byte) bool {
+	c.Hub.mu.RLock()
+	defer c.Hub.mu.RUnlock()
+
+	if !c.Hub.Clients[c] {
+		return false
+	}
+
+	select {
+	case c.Send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) sendError(message string) {
+	errMsg := WSMessage{
+		Type:    string(WSError),
+		Payload: map[string]interface{}{"error": message},
+	}
+
+	data, _ := json.Marshal(errMsg)
+	// Dropped if the channel is full
+	c.trySend(data)
+}
+
 func (c *Client) handlePing() {
 	pongMsg := WSMessage{
 		Type:    string(WSPong),
@@ -320,22 +655,51 @@ func (c *Client) handlePing() {
 	}
 
 	data, _ := json.Marshal(pongMsg)
-	select {
-	case c.Send <- data:
-	default:
-		// Channel is full, ignore
-	}
+	// Dropped if the channel is full
+	c.trySend(data)
+}
+
+// SetLiveLocationHandler plugs in the component that validates live
+// location updates sent over the socket.
+func (h *Hub) SetLiveLocationHandler(handler LiveLocationHandler) {
+	h.liveLocationHandler = handler
+}
+
+// SetDraftHandler plugs in the component that stores drafts sent over the
+// socket.
+func (h *Hub) SetDraftHandler(handler DraftHandler) {
+	h.draftHandler = handler
+}
+
+// SetReceiptHandler plugs in the component that records delivery
+// acknowledgements.
+func (h *Hub) SetReceiptHandler(handler ReceiptHandler) {
+	h.receiptHandler = handler
+}
+
+// SetSyncHandler plugs in the component that replays missed events to
+// reconnecting clients.
+func (h *Hub) SetSyncHandler(handler SyncHandler) {
+	h.syncHandler = handler
 }
 
 // Broadcasting methods
 func (h *Hub) BroadcastNewMessage(message *entities.Message, senderName string) {
 	payload := NewMessagePayload{
-		Message:    message,
-		ChatID:     message.ChatID,
-		SenderName: senderName,
+		Message:         message,
+		ChatID:          message.ChatID,
+		SenderName:      senderName,
+		ClientMessageID: message.ClientMessageID,
+	}
+
+	// Clients that send with a client message ID also get their own
+	// messages back, to confirm the optimistic copy on every device
+	exclude := message.SenderID
+	if message.ClientMessageID != "" {
+		exclude = primitive.NilObjectID
 	}
 
-	h.BroadcastToChat(message.ChatID, message.SenderID, WSMessage{
+	h.BroadcastToChat(message.ChatID, exclude, WSMessage{
 		Type:    string(WSNewMessage),
 		Payload: payload,
 	})
@@ -356,6 +720,23 @@ func (h *Hub) BroadcastMessageStatus(messageID, chatID, userID primitive.ObjectI
 	})
 }
 
+// NotifyAggregateStatus tells the sender that every recipient has now
+// received or read their message.
+func (h *Hub) NotifyAggregateStatus(senderID, messageID, chatID primitive.ObjectID, status entities.MessageStatus) {
+	payload := MessageStatusPayload{
+		MessageID: messageID,
+		ChatID:    chatID,
+		Status:    status,
+		Aggregate: true,
+		Timestamp: time.Now(),
+	}
+
+	h.SendToUser(senderID, WSMessage{
+		Type:    string(WSMessageStatus),
+		Payload: payload,
+	})
+}
+
 func (h *Hub) BroadcastMessageReaction(messageID, chatID, userID primitive.ObjectID, username string, reaction entities.ReactionType, action string) {
 	payload := MessageReactionPayload{
 		MessageID: messageID,
@@ -373,6 +754,163 @@ func (h *Hub) BroadcastMessageReaction(messageID, chatID, userID primitive.Objec
 	})
 }
 
+func (h *Hub) BroadcastMessageDeleted(messageID, chatID, deletedBy primitive.ObjectID) {
+	payload := MessageDeletedPayload{
+		MessageID: messageID,
+		ChatID:    chatID,
+		DeletedBy: deletedBy,
+		Content:   entities.DeletedMessagePlaceholder,
+		DeletedAt: time.Now(),
+	}
+
+	h.BroadcastToChat(chatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessageDeleted),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastMessageEdited(message *entities.Message) {
+	payload := MessageEditedPayload{
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		Content:   message.Content,
+		Mentions:  message.Mentions,
+		Revision:  message.EditCount,
+		EditedAt:  message.UpdatedAt,
+	}
+
+	h.BroadcastToChat(message.ChatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessageEdited),
+		Payload: payload,
+	})
+}
+
+// BroadcastMessageUpdated sends the full message after server-side changes
+// such as a link preview being attached.
+func (h *Hub) BroadcastMessageUpdated(message *entities.Message) {
+	payload := MessageUpdatedPayload{
+		MessageID: message.ID,
+		ChatID:    message.ChatID,
+		Message:   message,
+	}
+
+	h.BroadcastToChat(message.ChatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessageUpdated),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastMessageExpired(messageID, chatID primitive.ObjectID) {
+	payload := MessageExpiredPayload{
+		MessageID: messageID,
+		ChatID:    chatID,
+		ExpiredAt: time.Now(),
+	}
+
+	h.BroadcastToChat(chatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessageExpired),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastMessagePinned(pin *entities.PinnedMessage) {
+	payload := MessagePinnedPayload{
+		MessageID: pin.MessageID,
+		ChatID:    pin.ChatID,
+		PinnedBy:  pin.PinnedBy,
+		PinnedAt:  pin.PinnedAt,
+		ExpiresAt: pin.ExpiresAt,
+	}
+
+	h.BroadcastToChat(pin.ChatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessagePinned),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastMessageUnpinned(messageID, chatID, unpinnedBy primitive.ObjectID) {
+	payload := MessageUnpinnedPayload{
+		MessageID:  messageID,
+		ChatID:     chatID,
+		UnpinnedBy: unpinnedBy,
+	}
+
+	h.BroadcastToChat(chatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSMessageUnpinned),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastLiveLocationEnded(messageID, chatID primitive.ObjectID, endedAt time.Time) {
+	payload := LiveLocationEndedPayload{
+		MessageID: messageID,
+		ChatID:    chatID,
+		EndedAt:   endedAt,
+	}
+
+	h.BroadcastToChat(chatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSLiveLocationEnded),
+		Payload: payload,
+	})
+}
+
+func (h *Hub) BroadcastPollUpdated(results *entities.PollResults) {
+	h.BroadcastToChat(results.ChatID, primitive.NilObjectID, WSMessage{
+		Type:    string(WSPollUpdated),
+		Payload: results,
+	})
+}
+
+// NotifyMention is sent to the mentioned user directly, so it reaches them
+// even in chats they have muted.
+func (h *Hub) NotifyMention(userID primitive.ObjectID, message *entities.Message, senderName string) {
+	payload := MentionPayload{
+		MessageID:  message.ID,
+		ChatID:     message.ChatID,
+		SenderID:   message.SenderID,
+		SenderName: senderName,
+		Content:    message.Content,
+	}
+
+	h.SendToUser(userID, WSMessage{
+		Type:    string(WSMention),
+		Payload: payload,
+	})
+}
+
+// NotifyThreadReply tells a thread participant about a reply they haven't read.
+func (h *Hub) NotifyThreadReply(subscription *entities.ThreadSubscription, message *entities.Message) {
+	payload := ThreadReplyPayload{
+		RootID:      subscription.RootID,
+		ChatID:      message.ChatID,
+		MessageID:   message.ID,
+		SenderID:    message.SenderID,
+		UnreadCount: subscription.UnreadCount,
+	}
+
+	h.SendToUser(subscription.UserID, WSMessage{
+		Type:    string(WSThreadReply),
+		Payload: payload,
+	})
+}
+
+// NotifyDraftUpdated syncs a draft to the user's devices, skipping the
+// connection it came from, if any.
+func (h *Hub) NotifyDraftUpdated(userID primitive.ObjectID, draft *DraftPayload, except *Client) {
+	h.sendToUserExcept(userID, except, WSMessage{
+		Type:    string(WSDraftUpdated),
+		Payload: draft,
+	})
+}
+
+// NotifyChatCleared syncs a cleared chat to the user's devices.
+func (h *Hub) NotifyChatCleared(userID primitive.ObjectID, payload *ChatClearedPayload) {
+	h.SendToUser(userID, WSMessage{
+		Type:    string(WSChatCleared),
+		Payload: payload,
+	})
+}
+
 func (h *Hub) BroadcastUserStatus(userID primitive.ObjectID, username string, isOnline bool) {
 	payload := UserStatusPayload{
 		UserID:   userID,
@@ -406,40 +944,95 @@ func (h *Hub) BroadcastToChat(chatID, excludeUserID primitive.ObjectID, message
 	}
 
 	broadcastCount := 0
+	var slow []*Client
 
 	// Broadcast to all clients in the specific chat
-	if chatClients, exists := h.ChatClients[chatID]; exists {
-		for client := range chatClients {
-			if excludeUserID != primitive.NilObjectID && client.UserID == excludeUserID {
-				continue
-			}
+	h.mu.RLock()
+	for client := range h.ChatClients[chatID] {
+		if excludeUserID != primitive.NilObjectID && client.UserID == excludeUserID {
+			continue
+		}
 
-			select {
-			case client.Send <- data:
-				broadcastCount++
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-				delete(h.UserClients, client.UserID)
-				delete(chatClients, client)
-			}
+		select {
+		case client.Send <- data:
+			broadcastCount++
+		default:
+			slow = append(slow, client)
 		}
 	}
+	h.mu.RUnlock()
+	h.dropClients(slow)
 
 	log.Printf("📤 WebSocket: Message sent to %d clients in chat %s", broadcastCount, chatID.Hex())
 }
 
+// SendToUser delivers a message to every device the user is connected from.
 func (h *Hub) SendToUser(userID primitive.ObjectID, message WSMessage) {
-	if client, ok := h.UserClients[userID]; ok {
-		data, _ := json.Marshal(message)
+	h.sendToUserExcept(userID, nil, message)
+}
+
+// sendToUserExcept skips one connection, usually the one that caused the
+// event.
+func (h *Hub) sendToUserExcept(userID primitive.ObjectID, except *Client, message WSMessage) {
+	data, _ := json.Marshal(message)
+
+	var slow []*Client
+	h.mu.RLock()
+	for client := range h.UserClients[userID] {
+		if client == except {
+			continue
+		}
+
 		select {
 		case client.Send <- data:
 		default:
-			close(client.Send)
-			delete(h.Clients, client)
-			delete(h.UserClients, userID)
+			slow = append(slow, client)
 		}
 	}
+	h.mu.RUnlock()
+	h.dropClients(slow)
+}
+
+// dropClients disconnects clients whose send buffer is full.
+func (h *Hub) dropClients(clients []*Client) {
+	if len(clients) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, client := range clients {
+		h.removeClient(client)
+	}
+}
+
+// removeClient takes a client out of every map and closes its send
+// channel, reporting false if it was already gone. The caller holds the
+// write lock.
+func (h *Hub) removeClient(client *Client) bool {
+	if _, ok := h.Clients[client]; !ok {
+		return false
+	}
+	delete(h.Clients, client)
+
+	clients := h.UserClients[client.UserID]
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.UserClients, client.UserID)
+	}
+
+	for chatID, chatClients := range h.ChatClients {
+		if _, exists := chatClients[client]; exists {
+			delete(chatClients, client)
+			if len(chatClients) == 0 {
+				delete(h.ChatClients, chatID)
+			}
+		}
+	}
+
+	close(client.Send)
+	client.IsOnline = false
+	return true
 }
 
 func (h *Hub) NotifyFileUploadProgress(userID primitive.ObjectID, uploadID string, chatID primitive.ObjectID, fileName string, progress int) {